@@ -0,0 +1,47 @@
+package zapfilter
+
+import (
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// AtomicFilter is a filter that can be replaced at runtime, safely for concurrent use,
+// i.e., to change the rules of a running program from an admin endpoint.
+type AtomicFilter struct {
+	value atomic.Value // holds an atomicFilterValue
+}
+
+// atomicFilterValue wraps the filter, as atomic.Value requires a consistent concrete
+// type and doesn't accept nil.
+type atomicFilterValue struct {
+	filter FilterFunc
+}
+
+// NewAtomicFilter returns an AtomicFilter initialized with filter.
+func NewAtomicFilter(filter FilterFunc) *AtomicFilter {
+	var f AtomicFilter
+	f.Store(filter)
+	return &f
+}
+
+// Store replaces the filter; a nil filter drops every entry.
+func (f *AtomicFilter) Store(filter FilterFunc) {
+	if filter == nil {
+		filter = alwaysFalseFilter
+	}
+	f.value.Store(atomicFilterValue{filter: filter})
+}
+
+// Load returns the current filter.
+func (f *AtomicFilter) Load() FilterFunc {
+	if value, ok := f.value.Load().(atomicFilterValue); ok {
+		return value.filter
+	}
+	return alwaysFalseFilter
+}
+
+// Filter is a FilterFunc calling the current filter.
+func (f *AtomicFilter) Filter(entry zapcore.Entry, fields []zapcore.Field) bool {
+	return f.Load()(entry, fields)
+}
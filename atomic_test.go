@@ -0,0 +1,45 @@
+package zapfilter_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"moul.io/zapfilter"
+	"moul.io/zapfilter/zapfiltertest"
+)
+
+func TestAtomicFilter(t *testing.T) {
+	t.Parallel()
+
+	info := zapfiltertest.Entry(zapcore.InfoLevel, "api", "hello")
+	filter := zapfilter.NewAtomicFilter(zapfilter.MustParseRules("info:*"))
+	zapfiltertest.AssertPasses(t, filter.Filter, info)
+
+	filter.Store(zapfilter.MustParseRules("warn+:*"))
+	zapfiltertest.AssertDrops(t, filter.Filter, info)
+	require.Equal(t, `ParseRules("warn+:*")`, zapfilter.Describe(filter.Load()))
+
+	filter.Store(nil)
+	zapfiltertest.AssertDrops(t, filter.Filter, info)
+
+	var zero zapfilter.AtomicFilter
+	zapfiltertest.AssertDrops(t, zero.Filter, info)
+
+	// concurrent use
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				filter.Filter(info, []zapcore.Field{})
+			}
+		}()
+	}
+	for j := 0; j < 100; j++ {
+		filter.Store(zapfilter.AllowAll())
+	}
+	wg.Wait()
+}
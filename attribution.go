@@ -0,0 +1,92 @@
+package zapfilter
+
+import (
+	"fmt"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// WithAttribution appends a string field, using the given key, to the written entries
+// containing the name of the NamedFilter that let them pass, i.e., filtered_by="rule2".
+//
+// Entries passing without going through a NamedFilter are left untouched.
+func WithAttribution(key string) Option {
+	return func(core *filteringCore) {
+		core.attributionKey = key
+		core.explain = explainerOf(core.filter)
+	}
+}
+
+// NamedFilter names a filter so the decisions it takes can be attributed to it, see
+// WithAttribution.
+func NamedFilter(name string, filter FilterFunc) FilterFunc {
+	info := filterInfo{
+		desc:          describeCall("NamedFilter", fmt.Sprintf("%q", name), Describe(filter)),
+		levels:        levelsOf(filter),
+		ignoresFields: ignoresFields(filter),
+		name:          name,
+		children:      []FilterFunc{filter},
+	}
+	return describe(info, filter)
+}
+
+// explainFunc is a filter also returning the name of the NamedFilter that matched.
+type explainFunc func(zapcore.Entry, []zapcore.Field) (bool, string)
+
+// explainerOf walks the composition of a filter once to build its explainFunc.
+//
+// The explainFunc evaluates each underlying filter at most once, exactly like the
+// original filter would, so stateful filters are not affected.
+func explainerOf(filter FilterFunc) explainFunc {
+	info := infoOf(filter)
+	switch {
+	case info == nil:
+		// opaque filter
+	case info.name != "":
+		name := info.name
+		return func(entry zapcore.Entry, fields []zapcore.Field) (bool, string) {
+			if filter(entry, fields) {
+				return true, name
+			}
+			return false, ""
+		}
+	case info.op == opAny:
+		children := explainersOf(info.children)
+		return func(entry zapcore.Entry, fields []zapcore.Field) (bool, string) {
+			for _, child := range children {
+				if passed, name := child(entry, fields); passed {
+					return true, name
+				}
+			}
+			return false, ""
+		}
+	case info.op == opAll:
+		children := explainersOf(info.children)
+		return func(entry zapcore.Entry, fields []zapcore.Field) (bool, string) {
+			var matched string
+			for _, child := range children {
+				passed, name := child(entry, fields)
+				if !passed {
+					return false, ""
+				}
+				if matched == "" {
+					matched = name
+				}
+			}
+			return len(children) > 0, matched
+		}
+	}
+	return func(entry zapcore.Entry, fields []zapcore.Field) (bool, string) {
+		return filter(entry, fields), ""
+	}
+}
+
+func explainersOf(filters []FilterFunc) []explainFunc {
+	explainers := make([]explainFunc, 0, len(filters))
+	for _, filter := range filters {
+		if filter != nil {
+			explainers = append(explainers, explainerOf(filter))
+		}
+	}
+	return explainers
+}
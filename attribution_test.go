@@ -0,0 +1,71 @@
+package zapfilter_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"moul.io/zapfilter"
+)
+
+func TestWithAttribution(t *testing.T) {
+	t.Parallel()
+
+	filter := zapfilter.Any(
+		zapfilter.NamedFilter("rule1", zapfilter.MustParseRules("debug:foo")),
+		zapfilter.NamedFilter("rule2", zapfilter.MustParseRules("error:*")),
+		zapfilter.MustParseRules("info:bar"),
+	)
+	next, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(zapfilter.NewFilteringCore(next, filter, zapfilter.WithAttribution("filtered_by")))
+
+	logger.Named("foo").Debug("a")
+	logger.Named("foo").Info("b")
+	logger.Named("foo").Error("c")
+	logger.Named("bar").Info("d")
+	logger.With(zap.String("lorem", "ipsum")).Named("foo").Error("e")
+
+	type result struct {
+		message string
+		context []zapcore.Field
+	}
+	got := []result{}
+	for _, log := range logs.All() {
+		got = append(got, result{log.Message, log.Context})
+	}
+	require.Equal(t, []result{
+		{"a", []zapcore.Field{zap.String("filtered_by", "rule1")}},
+		{"c", []zapcore.Field{zap.String("filtered_by", "rule2")}},
+		{"d", []zapcore.Field{}},
+		{"e", []zapcore.Field{zap.String("lorem", "ipsum"), zap.String("filtered_by", "rule2")}},
+	}, got)
+}
+
+func TestWithAttribution_disabled(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(zapfilter.NewFilteringCore(next, zapfilter.NamedFilter("rule1", zapfilter.MinimumLevel(zapcore.InfoLevel))))
+
+	logger.Debug("a")
+	logger.Info("b")
+	require.Equal(t, 1, logs.Len())
+	require.Empty(t, logs.All()[0].Context)
+}
+
+func TestWithAttribution_statefulFiltersEvaluatedOnce(t *testing.T) {
+	t.Parallel()
+
+	limiter := zapfilter.NewGlobalRateLimiter(0, 2)
+	filter := zapfilter.All(zapfilter.NamedFilter("limited", limiter.Filter), zapfilter.MinimumLevel(zapcore.InfoLevel))
+	next, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(zapfilter.NewFilteringCore(next, filter, zapfilter.WithAttribution("filtered_by")))
+
+	for i := 0; i < 3; i++ {
+		logger.Info("a")
+	}
+	require.Equal(t, 2, logs.Len())
+	require.Equal(t, uint64(1), limiter.Dropped())
+}
@@ -0,0 +1,160 @@
+package zapfilter_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"moul.io/zapfilter"
+)
+
+const benchmarkRules = "debug:api.*,-api.noisy info:db.*,http warn+:* error:<root>"
+
+func BenchmarkParseRules(b *testing.B) {
+	for _, rules := range []string{"info:*", "debug:mypkg.*", benchmarkRules} {
+		rules := rules
+		b.Run(rules, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				if _, err := zapfilter.ParseRules(rules); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkQuickRules(b *testing.B) {
+	// a core per request: build the filter, log a few entries, discard it
+	parsers := []struct {
+		name  string
+		parse func(string) (zapfilter.FilterFunc, error)
+	}{
+		{"ParseRules", zapfilter.ParseRules},
+		{"QuickRules", zapfilter.QuickRules},
+	}
+	entries := []zapcore.Entry{
+		{Level: zapcore.DebugLevel, LoggerName: "api.users"},
+		{Level: zapcore.InfoLevel, LoggerName: "api.users"},
+		{Level: zapcore.DebugLevel, LoggerName: "api.noisy"},
+		{Level: zapcore.ErrorLevel, LoggerName: "db"},
+	}
+	for _, parser := range parsers {
+		parser := parser
+		b.Run(parser.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				filter, err := parser.parse(benchmarkRules)
+				if err != nil {
+					b.Fatal(err)
+				}
+				for _, entry := range entries {
+					filter(entry, nil)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkByNamespaces(b *testing.B) {
+	b.Run("hot", func(b *testing.B) {
+		filter := zapfilter.ByNamespaces("api.*,-api.noisy,db")
+		entry := zapcore.Entry{LoggerName: "api.users"}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			filter(entry, nil)
+		}
+	})
+	b.Run("cold", func(b *testing.B) {
+		entry := zapcore.Entry{LoggerName: "api.users"}
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			// a new filter has an empty cache
+			zapfilter.ByNamespaces("api.*,-api.noisy,db")(entry, nil)
+		}
+	})
+	constructors := []struct {
+		name string
+		new  func(string) zapfilter.FilterFunc
+	}{
+		{"cached", zapfilter.ByNamespaces},
+		{"no-cache", zapfilter.ByNamespacesNoCache},
+	}
+	for _, constructor := range constructors {
+		constructor := constructor
+		b.Run("high-cardinality/"+constructor.name, func(b *testing.B) {
+			// every namespace is new, the cache keeps growing
+			filter := constructor.new("api.*,-api.noisy,db")
+			names := make([]string, b.N)
+			for i := range names {
+				names[i] = fmt.Sprintf("api.request%d", i)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				filter(zapcore.Entry{LoggerName: names[i]}, nil)
+			}
+		})
+	}
+}
+
+func BenchmarkWrite(b *testing.B) {
+	next := zapcore.NewCore(zapcore.NewJSONEncoder(zapcore.EncoderConfig{}), zapcore.AddSync(ioutil.Discard), zapcore.DebugLevel)
+	cores := []struct {
+		name string
+		core zapcore.Core
+	}{
+		{"raw", next},
+		{"filtered", zapfilter.NewFilteringCore(next, zapfilter.MustParseRules(benchmarkRules))},
+	}
+	for _, tc := range cores {
+		logger := zap.New(tc.core).Named("api").Named("users")
+		b.Run(tc.name+"/passed", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				logger.Debug("hello", zap.Int("i", i))
+			}
+		})
+		noisy := zap.New(tc.core).Named("api").Named("noisy")
+		b.Run(tc.name+"/dropped", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				noisy.Debug("hello", zap.Int("i", i))
+			}
+		})
+		contextual := logger.With(zap.String("request", "42"))
+		b.Run(tc.name+"/with", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				contextual.Debug("hello", zap.Int("i", i))
+			}
+		})
+	}
+}
+
+func BenchmarkExactLevels(b *testing.B) {
+	filters := []struct {
+		name   string
+		filter zapfilter.FilterFunc
+	}{
+		{"set", zapfilter.ExactLevels(zapcore.DebugLevel, zapcore.WarnLevel, zapcore.ErrorLevel)},
+		{"chained", zapfilter.Any(
+			zapfilter.ExactLevel(zapcore.DebugLevel),
+			zapfilter.ExactLevel(zapcore.WarnLevel),
+			zapfilter.ExactLevel(zapcore.ErrorLevel),
+		)},
+	}
+	entry := zapcore.Entry{Level: zapcore.ErrorLevel}
+	for _, tc := range filters {
+		filter := tc.filter
+		b.Run(tc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				filter(entry, nil)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package zapfilter
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// Builder composes filters from left to right, so policies read like sentences:
+//
+//   filter := zapfilter.Level(zapcore.ErrorLevel).
+//       AndAny(zapfilter.ByNamespaces("api.*"), zapfilter.ByMessage("panic")).
+//       Filter()
+//
+// is All(MinimumLevel(error), Any(ByNamespaces("api.*"), ByMessage("panic"))).
+//
+// A Builder is a value: each method returns a new Builder and leaves the receiver
+// untouched, so a common base can be shared. The zero value matches every entry.
+type Builder struct {
+	filter FilterFunc
+}
+
+// When starts a Builder from a filter.
+func When(filter FilterFunc) Builder {
+	return Builder{filter: filter}
+}
+
+// Level starts a Builder matching the entries whose level is greater than or equal to
+// level, see MinimumLevel.
+func Level(level zapcore.Level) Builder {
+	return When(MinimumLevel(level))
+}
+
+// And requires every filter to match, in addition to the previous conditions.
+func (b Builder) And(filters ...FilterFunc) Builder {
+	return Builder{filter: All(append([]FilterFunc{b.Filter()}, filters...)...)}
+}
+
+// AndAny requires at least one of the filters to match, in addition to the previous
+// conditions.
+func (b Builder) AndAny(filters ...FilterFunc) Builder {
+	return Builder{filter: All(b.Filter(), Any(filters...))}
+}
+
+// Or matches the entries matching the previous conditions, or every filter.
+func (b Builder) Or(filters ...FilterFunc) Builder {
+	return Builder{filter: Any(b.Filter(), All(filters...))}
+}
+
+// OrAny matches the entries matching the previous conditions, or any of the filters.
+func (b Builder) OrAny(filters ...FilterFunc) Builder {
+	return Builder{filter: Any(append([]FilterFunc{b.Filter()}, filters...)...)}
+}
+
+// Filter returns the composed filter.
+func (b Builder) Filter() FilterFunc {
+	if b.filter == nil {
+		return AllowAll()
+	}
+	return b.filter
+}
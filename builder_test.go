@@ -0,0 +1,50 @@
+package zapfilter_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"moul.io/zapfilter"
+	"moul.io/zapfilter/zapfiltertest"
+)
+
+func TestBuilder(t *testing.T) {
+	t.Parallel()
+
+	apiError := zapfiltertest.Entry(zapcore.ErrorLevel, "api.users", "oops")
+	apiInfo := zapfiltertest.Entry(zapcore.InfoLevel, "api.users", "oops")
+	dbPanic := zapfiltertest.Entry(zapcore.ErrorLevel, "db", "recovered from panic")
+	dbError := zapfiltertest.Entry(zapcore.ErrorLevel, "db", "oops")
+	dbInfoPanic := zapfiltertest.Entry(zapcore.InfoLevel, "db", "recovered from panic")
+
+	filter := zapfilter.Level(zapcore.ErrorLevel).
+		AndAny(zapfilter.ByNamespaces("api.*"), zapfilter.ByMessage("panic")).
+		Filter()
+	zapfiltertest.AssertPasses(t, filter, apiError, dbPanic)
+	zapfiltertest.AssertDrops(t, filter, apiInfo, dbError, dbInfoPanic)
+	require.Equal(t,
+		`All(MinimumLevel(error), Any(ByNamespaces("api.*"), ByMessage("panic")))`,
+		zapfilter.Describe(filter),
+	)
+
+	// each method returns a new builder
+	base := zapfilter.When(zapfilter.ByNamespaces("db"))
+	errors := base.And(zapfilter.MinimumLevel(zapcore.ErrorLevel)).Filter()
+	panics := base.And(zapfilter.ByMessage("panic")).Or(zapfilter.ByNamespaces("api.*"), zapfilter.ExactLevel(zapcore.InfoLevel)).Filter()
+	zapfiltertest.AssertPasses(t, base.Filter(), dbPanic, dbError, dbInfoPanic)
+	zapfiltertest.AssertPasses(t, errors, dbPanic, dbError)
+	zapfiltertest.AssertDrops(t, errors, dbInfoPanic, apiError)
+	zapfiltertest.AssertPasses(t, panics, dbPanic, dbInfoPanic, apiInfo)
+	zapfiltertest.AssertDrops(t, panics, dbError, apiError)
+
+	anyOf := zapfilter.Level(zapcore.FatalLevel).OrAny(zapfilter.ByNamespaces("api.*"), zapfilter.ByMessage("panic")).Filter()
+	zapfiltertest.AssertPasses(t, anyOf, apiInfo, dbInfoPanic)
+	zapfiltertest.AssertDrops(t, anyOf, dbError)
+
+	// the zero value matches every entry
+	var zero zapfilter.Builder
+	require.True(t, zapfilter.IsAlwaysTrue(zero.Filter()))
+	require.True(t, zapfilter.IsAlwaysTrue(zero.Or(zapfilter.ByMessage("panic")).Filter()))
+	zapfiltertest.AssertDrops(t, zero.And(zapfilter.ByMessage("panic")).Filter(), dbError)
+}
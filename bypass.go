@@ -0,0 +1,33 @@
+package zapfilter
+
+import (
+	"sync/atomic"
+)
+
+// Bypasser is implemented by the filtering cores, see SetBypass.
+type Bypasser interface {
+	SetBypass(bypass bool)
+}
+
+// SetBypass makes the core pass every entry to the next core, without calling the
+// filter, until it is called again with false, i.e., to open the floodgates during an
+// incident without reconfiguring the rules:
+//
+//   core := zapfilter.NewFilteringCore(next, filter)
+//   core.(zapfilter.Bypasser).SetBypass(true)
+//
+// The bypass is shared by the cores derived with With and Clone. As the filter isn't
+// called, stateful filters, i.e., rate limiters and samplers, don't update their state
+// nor their counters while bypassed, and WithCounters doesn't count the entries.
+func (core *filteringCore) SetBypass(bypass bool) {
+	var value uint32
+	if bypass {
+		value = 1
+	}
+	atomic.StoreUint32(core.bypass, value)
+}
+
+// bypassed returns true if the filtering is bypassed, see SetBypass.
+func (core *filteringCore) bypassed() bool {
+	return atomic.LoadUint32(core.bypass) == 1
+}
@@ -0,0 +1,52 @@
+package zapfilter_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"moul.io/zapfilter"
+)
+
+func TestSetBypass(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	limiter := zapfilter.NewGlobalRateLimiter(1, 1)
+	filter := zapfilter.All(zapfilter.MustParseRules("info+:*"), limiter.Filter)
+	core := zapfilter.NewFilteringCore(next, filter, zapfilter.WithCounters())
+	logger := zap.New(core).Named("foo").With(zap.String("key", "value"))
+
+	logger.Debug("debug-1")
+	logger.Info("info-1")
+	logger.Info("info-2") // rate limited
+
+	core.(zapfilter.Bypasser).SetBypass(true)
+	require.True(t, core.Enabled(zapcore.DebugLevel))
+	logger.Debug("debug-2")
+	logger.Info("info-3")
+	logger.Info("info-4")
+
+	core.(zapfilter.Bypasser).SetBypass(false)
+	logger.Debug("debug-3")
+	logger.Info("info-5") // still rate limited
+
+	gotLogs := []string{}
+	for _, log := range logs.All() {
+		gotLogs = append(gotLogs, log.Message)
+	}
+	require.Equal(t, []string{"info-1", "debug-2", "info-3", "info-4"}, gotLogs)
+
+	// the filter wasn't called while bypassed
+	require.Equal(t, zapfilter.FilterStats{Passed: 1, Dropped: 2}, limiter.Stats())
+	require.Equal(t, zapfilter.FilterStats{Passed: 1, Dropped: 4}, core.(zapfilter.CountersReader).Counters().Total())
+
+	// without counters, the levels the filter never enables are disabled again
+	core = zapfilter.NewFilteringCore(next, filter)
+	core.(zapfilter.Bypasser).SetBypass(true)
+	require.True(t, core.Enabled(zapcore.DebugLevel))
+	core.(zapfilter.Bypasser).SetBypass(false)
+	require.False(t, core.Enabled(zapcore.DebugLevel))
+}
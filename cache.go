@@ -0,0 +1,158 @@
+package zapfilter
+
+import (
+	"container/list"
+	"sync"
+)
+
+// defaultMaxKeys is the number of keys a stateful filter remembers before evicting
+// the least recently used ones, when its constructor isn't given a maximum.
+const defaultMaxKeys = 10000
+
+// lruCache is a bounded map evicting its least recently used keys.
+//
+// It is not concurrency-safe; callers are expected to hold their own lock.
+type lruCache struct {
+	max   int
+	order *list.List // front is the most recently used
+	items map[interface{}]*list.Element
+}
+
+type lruItem struct {
+	key   interface{}
+	value interface{}
+}
+
+func newLRUCache(max int) *lruCache {
+	if max <= 0 {
+		max = defaultMaxKeys
+	}
+	return &lruCache{
+		max:   max,
+		order: list.New(),
+		items: make(map[interface{}]*list.Element),
+	}
+}
+
+// get returns the value stored for key and marks it as recently used.
+func (c *lruCache) get(key interface{}) (interface{}, bool) {
+	elem, found := c.items[key]
+	if !found {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*lruItem).value, true
+}
+
+// set stores the value for key, evicting the least recently used key if needed.
+func (c *lruCache) set(key, value interface{}) {
+	if elem, found := c.items[key]; found {
+		elem.Value.(*lruItem).value = value
+		c.order.MoveToFront(elem)
+		return
+	}
+	if c.order.Len() >= c.max {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*lruItem).key)
+	}
+	c.items[key] = c.order.PushFront(&lruItem{key: key, value: value})
+}
+
+// len returns the number of stored keys.
+func (c *lruCache) len() int {
+	return c.order.Len()
+}
+
+// boundedCache is a concurrency-safe lruCache, shared by the stateful filters so their
+// memory stays bounded whatever the number of keys, i.e., under dynamic or adversarial
+// namespaces and messages.
+type boundedCache struct {
+	mutex sync.Mutex
+	max   int
+	cache *lruCache
+}
+
+// newBoundedCache returns a cache of up to max keys, defaultMaxKeys if max <= 0.
+func newBoundedCache(max int) *boundedCache {
+	if max <= 0 {
+		max = defaultMaxKeys
+	}
+	return &boundedCache{max: max, cache: newLRUCache(max)}
+}
+
+// update atomically replaces the value stored for key with the value returned by fn,
+// called with the current value, if any.
+func (c *boundedCache) update(key interface{}, fn func(value interface{}, found bool) interface{}) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	value, found := c.cache.get(key)
+	c.cache.set(key, fn(value, found))
+}
+
+// len returns the number of stored keys.
+func (c *boundedCache) len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.cache.len()
+}
+
+// reset forgets every key.
+func (c *boundedCache) reset() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.cache = newLRUCache(c.max)
+}
+
+// namespaceCache is a concurrency-safe map of the decision taken for each namespace.
+//
+// It is on the hot path of every namespace filter, so a hit only takes a read lock and
+// never allocates. Its memory is bounded like boundedCache, but once full, it evicts an
+// arbitrary namespace rather than tracking the least recently used one: a namespace
+// evicted by mistake only costs a new match.
+type namespaceCache struct {
+	mutex     sync.RWMutex
+	max       int
+	decisions map[string]bool
+}
+
+// newNamespaceCache returns a cache of up to max namespaces, defaultMaxKeys if max <= 0.
+func newNamespaceCache(max int) *namespaceCache {
+	if max <= 0 {
+		max = defaultMaxKeys
+	}
+	return &namespaceCache{max: max, decisions: make(map[string]bool)}
+}
+
+// get returns the decision stored for namespace.
+func (c *namespaceCache) get(namespace string) (decision bool, found bool) {
+	c.mutex.RLock()
+	decision, found = c.decisions[namespace]
+	c.mutex.RUnlock()
+	return decision, found
+}
+
+// set stores the decision for namespace, evicting another namespace if needed.
+func (c *namespaceCache) set(namespace string, decision bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if _, found := c.decisions[namespace]; !found && len(c.decisions) >= c.max {
+		for evicted := range c.decisions {
+			delete(c.decisions, evicted)
+			break
+		}
+	}
+	c.decisions[namespace] = decision
+}
+
+// len returns the number of stored namespaces.
+func (c *namespaceCache) len() int {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return len(c.decisions)
+}
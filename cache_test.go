@@ -0,0 +1,131 @@
+package zapfilter_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"moul.io/zapfilter"
+	"moul.io/zapfilter/zapfiltertest"
+)
+
+func TestStatefulFilters_maxKeys(t *testing.T) {
+	t.Parallel()
+
+	const maxKeys = 3
+	cases := []struct {
+		name   string
+		filter func() zapfilter.FilterFunc
+		first  []bool // decisions for the first occurrences of a key
+	}{
+		{"Once", func() zapfilter.FilterFunc { return zapfilter.OnceWithMaxKeys(nil, 0, maxKeys) }, []bool{true, false}},
+		{"Debounce", func() zapfilter.FilterFunc { return zapfilter.DebounceWithMaxKeys(time.Hour, maxKeys) }, []bool{true, false}},
+		{"MinOccurrences", func() zapfilter.FilterFunc { return zapfilter.MinOccurrencesWithMaxKeys(2, time.Hour, maxKeys) }, []bool{false, true}},
+		{"Hysteresis", func() zapfilter.FilterFunc { return zapfilter.HysteresisWithMaxKeys(2, 2, time.Hour, maxKeys) }, []bool{false, true}},
+		{"OnceLimiter", func() zapfilter.FilterFunc { return zapfilter.NewOnceLimiterWithMaxKeys(nil, 0, maxKeys).Filter }, []bool{true, false}},
+		{"Debouncer", func() zapfilter.FilterFunc { return zapfilter.NewDebouncerWithMaxKeys(time.Hour, maxKeys).Filter }, []bool{true, false}},
+		{"MinOccurrencesLimiter", func() zapfilter.FilterFunc {
+			return zapfilter.NewMinOccurrencesLimiterWithMaxKeys(2, time.Hour, maxKeys).Filter
+		}, []bool{false, true}},
+		{"HysteresisLimiter", func() zapfilter.FilterFunc {
+			return zapfilter.NewHysteresisLimiterWithMaxKeys(2, 2, time.Hour, maxKeys).Filter
+		}, []bool{false, true}},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			filter := tc.filter()
+			key := zapfiltertest.Entry(zapcore.InfoLevel, "", "key")
+			require.Equal(t, tc.first, zapfiltertest.Record(filter, []zapcore.Entry{key, key}))
+
+			// up to maxKeys keys are remembered
+			for i := 0; i < maxKeys-1; i++ {
+				filter(zapfiltertest.Entry(zapcore.InfoLevel, "", fmt.Sprint(i)), []zapcore.Field{})
+			}
+			require.Equal(t, tc.first[1:], zapfiltertest.Record(filter, []zapcore.Entry{key}))
+
+			// a huge key space evicts the least recently seen keys, so the key is new again
+			for i := 0; i < 100000; i++ {
+				filter(zapfiltertest.Entry(zapcore.InfoLevel, "", fmt.Sprint(i)), []zapcore.Field{})
+			}
+			require.Equal(t, tc.first, zapfiltertest.Record(filter, []zapcore.Entry{key, key}))
+		})
+	}
+
+	// maxKeys <= 0 means the default
+	once := zapfilter.NewOnceLimiterWithMaxKeys(nil, 0, 0)
+	key := zapfiltertest.Entry(zapcore.InfoLevel, "", "key")
+	require.Equal(t, []bool{true, false}, zapfiltertest.Record(once.Filter, []zapcore.Entry{key, key}))
+}
+
+func TestKeyedFilters_boundedCache(t *testing.T) {
+	t.Parallel()
+
+	byMessage := func(i int) zapcore.Entry {
+		return zapfiltertest.Entry(zapcore.InfoLevel, "api", fmt.Sprint(i))
+	}
+	once := zapfilter.NewOnceLimiter(nil)
+	minOccurrences := zapfilter.NewMinOccurrencesLimiter(2, time.Hour)
+	debouncer := zapfilter.NewDebouncer(time.Hour)
+	hysteresis := zapfilter.NewHysteresisLimiter(2, 2, time.Hour)
+	cases := []struct {
+		name     string
+		filter   zapfilter.FilterFunc
+		cacheLen func() int
+	}{
+		{"Once", once.Filter, once.CacheLen},
+		{"MinOccurrences", minOccurrences.Filter, minOccurrences.CacheLen},
+		{"Debounce", debouncer.Filter, debouncer.CacheLen},
+		{"Hysteresis", hysteresis.Filter, hysteresis.CacheLen},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			for i := 0; i < 2*zapfilter.DefaultMaxKeys; i++ {
+				tc.filter(byMessage(i), []zapcore.Field{})
+			}
+			require.Equal(t, zapfilter.DefaultMaxKeys, tc.cacheLen())
+		})
+	}
+
+	shared := zapfilter.ByNamespacesShared("api.*,-api.1")
+	for i := 0; i < zapfilter.SharedCacheSize+1000; i++ {
+		shared(zapfiltertest.Entry(zapcore.InfoLevel, fmt.Sprintf("api.%d", i), "hello"), []zapcore.Field{})
+	}
+	require.LessOrEqual(t, zapfilter.SharedCacheLen(), zapfilter.SharedCacheSize)
+}
+
+func TestNamespaceCache(t *testing.T) {
+	t.Parallel()
+
+	const max = 100
+	cache := zapfilter.NewNamespaceCache(max)
+	_, found := cache.Get("api")
+	require.False(t, found)
+
+	for i := 0; i < 10*max; i++ {
+		cache.Set(fmt.Sprintf("api.%d", i), i%2 == 0)
+		require.LessOrEqual(t, cache.Len(), max)
+	}
+	require.Equal(t, max, cache.Len())
+
+	// the most recent namespace is never the one evicted
+	decision, found := cache.Get(fmt.Sprintf("api.%d", 10*max-1))
+	require.True(t, found)
+	require.False(t, decision)
+
+	// updating a namespace evicts nothing
+	cache.Set(fmt.Sprintf("api.%d", 10*max-1), true)
+	require.Equal(t, max, cache.Len())
+	decision, _ = cache.Get(fmt.Sprintf("api.%d", 10*max-1))
+	require.True(t, decision)
+
+	// max <= 0 means the default
+	require.Equal(t, 0, zapfilter.NewNamespaceCache(0).Len())
+}
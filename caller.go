@@ -0,0 +1,39 @@
+package zapfilter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// ByCallerLine filters entries logged from the lines minLine to maxLine (inclusive) of a
+// file, i.e., to bisect a noisy file.
+//
+// The file matches the end of the caller path on a path boundary, so "server.go" and
+// "api/server.go" both match "/src/app/api/server.go". Entries without caller (see
+// zap.AddCaller) never match.
+func ByCallerLine(file string, minLine, maxLine int) FilterFunc {
+	info := filterInfo{desc: describeCall("ByCallerLine", fmt.Sprintf("%q", file), strconv.Itoa(minLine), strconv.Itoa(maxLine)), ignoresFields: true}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		caller := entry.Caller
+		if !caller.Defined || caller.Line < minLine || caller.Line > maxLine {
+			return false
+		}
+		return caller.File == file || strings.HasSuffix(caller.File, "/"+file)
+	})
+}
+
+// HasStacktrace filters entries carrying a stacktrace, see zap.AddStacktrace, i.e., to
+// route the errors that captured their stack.
+//
+// zap captures the stacktrace once the cores have been checked, so Check always passes.
+func HasStacktrace() FilterFunc {
+	info := filterInfo{desc: describeCall("HasStacktrace"), levels: &allLevelSet, ignoresFields: true}
+	return describe(info, hasStacktrace)
+}
+
+func hasStacktrace(entry zapcore.Entry, fields []zapcore.Field) bool {
+	return isCheckPhase(fields) || entry.Stack != ""
+}
@@ -0,0 +1,49 @@
+package zapfilter_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"moul.io/zapfilter"
+)
+
+func TestByCallerLine(t *testing.T) {
+	t.Parallel()
+
+	entryAt := func(file string, line int) zapcore.Entry {
+		return zapcore.Entry{Caller: zapcore.NewEntryCaller(0, file, line, true)}
+	}
+	filter := zapfilter.ByCallerLine("api/server.go", 10, 20)
+
+	cases := []struct {
+		name     string
+		entry    zapcore.Entry
+		expected bool
+	}{
+		{"first-line", entryAt("/src/app/api/server.go", 10), true},
+		{"last-line", entryAt("/src/app/api/server.go", 20), true},
+		{"relative", entryAt("api/server.go", 15), true},
+		{"before", entryAt("/src/app/api/server.go", 9), false},
+		{"after", entryAt("/src/app/api/server.go", 21), false},
+		{"other-file", entryAt("/src/app/api/client.go", 15), false},
+		{"partial-name", entryAt("/src/app/myapi/server.go", 15), false},
+		{"undefined", zapcore.Entry{Caller: zapcore.EntryCaller{File: "api/server.go", Line: 15}}, false},
+	}
+	for _, tc := range cases {
+		require.Equal(t, tc.expected, filter(tc.entry, nil), tc.name)
+	}
+	require.Equal(t, `ByCallerLine("api/server.go", 10, 20)`, zapfilter.Describe(filter))
+}
+
+func TestHasStacktrace(t *testing.T) {
+	t.Parallel()
+
+	filter := zapfilter.HasStacktrace()
+	entry := zapcore.Entry{Level: zapcore.ErrorLevel}
+	require.True(t, filter(entry, nil)) // the stacktrace is captured after Check
+	require.False(t, filter(entry, []zapcore.Field{}))
+	entry.Stack = "goroutine 1 [running]:"
+	require.True(t, filter(entry, []zapcore.Field{}))
+	require.Equal(t, "HasStacktrace()", zapfilter.Describe(filter))
+}
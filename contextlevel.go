@@ -0,0 +1,50 @@
+package zapfilter
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// contextLevelKey is the key of the fields created by ContextLevel.
+const contextLevelKey = "zapfilter.context-level"
+
+// ContextLevel returns a field carrying a minimum level for ByContextLevel.
+//
+// It is meant to be attached to a logger with With, i.e., to enable debug logs for a
+// single request:
+//
+//   logger := logger.With(zapfilter.ContextLevel(zapcore.DebugLevel))
+//
+// The field is never encoded.
+func ContextLevel(level zapcore.Level) zapcore.Field {
+	return zapcore.Field{Key: contextLevelKey, Type: zapcore.SkipType, Integer: int64(level)}
+}
+
+// ByContextLevel matches entries whose level is greater than or equal to the level
+// carried by a ContextLevel field, and never matches entries without one.
+//
+// It is meant to be combined with other filters using Any, so a request can carry its
+// own verbosity:
+//
+//   filter := zapfilter.Any(zapfilter.MustParseRules("info+:*"), zapfilter.ByContextLevel())
+//
+// Fields are only known when the entry is written, so Check always passes. When With
+// is called several times, the most recent ContextLevel wins; a ContextLevel passed
+// to the logging call itself wins over the ones added with With.
+func ByContextLevel() FilterFunc {
+	info := filterInfo{desc: describeCall("ByContextLevel")}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		if isCheckPhase(fields) {
+			return true
+		}
+		for i := len(fields) - 1; i >= 0; i-- {
+			if isContextLevel(fields[i]) {
+				return entry.Level >= zapcore.Level(fields[i].Integer)
+			}
+		}
+		return false
+	})
+}
+
+func isContextLevel(field zapcore.Field) bool {
+	return field.Type == zapcore.SkipType && field.Key == contextLevelKey
+}
@@ -0,0 +1,61 @@
+package zapfilter_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"moul.io/zapfilter"
+)
+
+func TestByContextLevel(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	filter := zapfilter.Any(zapfilter.MustParseRules("warn+:*"), zapfilter.ByContextLevel())
+	logger := zap.New(zapfilter.NewFilteringCore(next, filter))
+
+	logger.Debug("a")
+	logger.Info("b")
+	logger.Warn("c")
+
+	verbose := logger.With(zapfilter.ContextLevel(zapcore.DebugLevel), zap.String("lorem", "ipsum"))
+	verbose.Debug("d")
+	verbose.Info("e")
+
+	// the most recent ContextLevel wins
+	lessVerbose := verbose.Named("foo").With(zapfilter.ContextLevel(zapcore.InfoLevel))
+	lessVerbose.Debug("f")
+	lessVerbose.Info("g")
+
+	// the field of the logging call wins
+	logger.Debug("h", zapfilter.ContextLevel(zapcore.DebugLevel))
+	verbose.Debug("i", zapfilter.ContextLevel(zapcore.ErrorLevel))
+
+	// the original logger is untouched
+	logger.Info("j")
+
+	gotLogs := []string{}
+	for _, log := range logs.All() {
+		gotLogs = append(gotLogs, log.Message)
+	}
+	require.Equal(t, []string{"c", "d", "e", "g", "h"}, gotLogs)
+	require.Equal(t, map[string]interface{}{"lorem": "ipsum"}, logs.All()[1].ContextMap())
+}
+
+func ExampleByContextLevel() {
+	core := zap.NewExample().Core()
+	filter := zapfilter.Any(zapfilter.MustParseRules("info+:*"), zapfilter.ByContextLevel())
+	logger := zap.New(zapfilter.NewFilteringCore(core, filter))
+	defer logger.Sync()
+
+	logger.Debug("hello city!")
+	logger.With(zapfilter.ContextLevel(zapcore.DebugLevel)).Debug("hello region!")
+	logger.Info("hello planet!")
+
+	// Output:
+	// {"level":"debug","msg":"hello region!"}
+	// {"level":"info","msg":"hello planet!"}
+}
@@ -0,0 +1,84 @@
+package zapfilter
+
+import (
+	"math"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// WithCounters counts the decisions of the core per level, without any metrics library,
+// i.e., to periodically dump "dropped 10k debug, passed 300 error":
+//
+//   core := zapfilter.NewFilteringCore(next, filter, zapfilter.WithCounters())
+//   counters := core.(zapfilter.CountersReader).Counters()
+//
+// The counters are atomic, and shared by the cores derived with With and Clone. Entries
+// dropped by Check are counted as dropped; the others are counted by Write, once
+// decided. Internal entries are not counted.
+//
+// Counting disables the level short-circuit of Enabled: a logger skips the entries of
+// the levels its core reports as disabled without ever calling Check, so they could not
+// be counted. With counters, Enabled only asks the next core, and each entry of a level
+// the filter never passes, i.e., debug with "info+:*", costs a Check call, on top of the
+// fields its caller builds, before being dropped: only enable counters where that cost
+// is acceptable.
+func WithCounters() Option {
+	return func(core *filteringCore) {
+		core.counters = &levelCounters{}
+	}
+}
+
+// CountersReader is implemented by the filtering cores, see WithCounters.
+type CountersReader interface {
+	Counters() Counters
+}
+
+// Counters is a snapshot of the decisions of a filtering core.
+type Counters struct {
+	Levels map[zapcore.Level]FilterStats // only the levels with decisions are present
+}
+
+// Total returns the decisions for every level.
+func (c Counters) Total() FilterStats {
+	var total FilterStats
+	for _, stats := range c.Levels {
+		total.Passed += stats.Passed
+		total.Dropped += stats.Dropped
+	}
+	return total
+}
+
+// levelCounters holds the passed and dropped counters of each possible level.
+type levelCounters struct {
+	passed  [math.MaxUint8 + 1]uint64 // indexed by uint8(level)
+	dropped [math.MaxUint8 + 1]uint64
+}
+
+func (c *levelCounters) add(level zapcore.Level, passed bool) {
+	if passed {
+		atomic.AddUint64(&c.passed[uint8(level)], 1)
+	} else {
+		atomic.AddUint64(&c.dropped[uint8(level)], 1)
+	}
+}
+
+func (c *levelCounters) snapshot() Counters {
+	counters := Counters{Levels: map[zapcore.Level]FilterStats{}}
+	for i := range c.passed {
+		stats := FilterStats{Passed: atomic.LoadUint64(&c.passed[i]), Dropped: atomic.LoadUint64(&c.dropped[i])}
+		if stats != (FilterStats{}) {
+			counters.Levels[zapcore.Level(int8(uint8(i)))] = stats
+		}
+	}
+	return counters
+}
+
+// Counters returns the decisions taken by the core, and the cores sharing its
+// counters, since its creation; it is empty without WithCounters.
+func (core *filteringCore) Counters() Counters {
+	if core.counters == nil {
+		return Counters{Levels: map[zapcore.Level]FilterStats{}}
+	}
+	return core.counters.snapshot()
+}
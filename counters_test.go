@@ -0,0 +1,62 @@
+package zapfilter_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"moul.io/zapfilter"
+)
+
+func TestWithCounters(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	filter := zapfilter.All(
+		zapfilter.MustParseRules("info+:*"),
+		func(entry zapcore.Entry, fields []zapcore.Field) bool {
+			for _, field := range fields {
+				if field.Key == "secret" {
+					return false
+				}
+			}
+			return true
+		},
+	)
+	core := zapfilter.NewFilteringCore(next, filter, zapfilter.WithCounters())
+	logger := zap.New(core)
+
+	for i := 0; i < 10; i++ {
+		logger.Debug("dropped by Check")
+	}
+	for i := 0; i < 3; i++ {
+		logger.Info("passed")
+		logger.Info("dropped by Write", zap.String("secret", "true"))
+	}
+	logger.Named("foo").With(zap.String("key", "value")).Error("passed")
+	logger.With(zapfilter.Internal()).Debug("not counted")
+	require.Equal(t, 5, logs.Len())
+
+	// debug is never enabled by the filter, but reported as enabled to count its drops
+	require.True(t, core.Enabled(zapcore.DebugLevel))
+	require.False(t, zapfilter.NewFilteringCore(next, filter).Enabled(zapcore.DebugLevel))
+
+	counters := core.(zapfilter.CountersReader).Counters()
+	require.Equal(t, map[zapcore.Level]zapfilter.FilterStats{
+		zapcore.DebugLevel: {Dropped: 10},
+		zapcore.InfoLevel:  {Passed: 3, Dropped: 3},
+		zapcore.ErrorLevel: {Passed: 1},
+	}, counters.Levels)
+	require.Equal(t, zapfilter.FilterStats{Passed: 4, Dropped: 13}, counters.Total())
+
+	// custom levels are counted too
+	require.NoError(t, core.Write(zapcore.Entry{Level: zapcore.Level(-2)}, nil))
+	require.Equal(t, zapfilter.FilterStats{Dropped: 1}, core.(zapfilter.CountersReader).Counters().Levels[zapcore.Level(-2)])
+
+	// without WithCounters, nothing is counted
+	core = zapfilter.NewFilteringCore(next, filter)
+	zap.New(core).Info("passed")
+	require.Empty(t, core.(zapfilter.CountersReader).Counters().Levels)
+}
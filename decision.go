@@ -0,0 +1,44 @@
+package zapfilter
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// decisionKey is the key of the fields created by DecisionField.
+const decisionKey = "zapfilter.decision"
+
+// DecisionField returns a field carrying the decision of a filtering core, so the next
+// cores of the chain, or custom encoders, can tell how an entry was admitted.
+//
+// The field is never encoded; use DecisionOf to read it from the fields received by
+// Write. The decision travels with the fields rather than the CheckedEntry, which is
+// pooled by zap and must not be annotated.
+func DecisionField(passed bool) zapcore.Field {
+	field := zapcore.Field{Key: decisionKey, Type: zapcore.SkipType}
+	if passed {
+		field.Integer = 1
+	}
+	return field
+}
+
+// DecisionOf returns the decision carried by the last DecisionField of fields, if any.
+func DecisionOf(fields []zapcore.Field) (passed bool, found bool) {
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i].Type == zapcore.SkipType && fields[i].Key == decisionKey {
+			return fields[i].Integer == 1, true
+		}
+	}
+	return false, false
+}
+
+// WithDecisionField appends a DecisionField to the entries written to the next core.
+//
+//   core := zapfilter.NewFilteringCore(next, filter, zapfilter.WithDecisionField())
+//
+// Only admitted entries are written, so the decision is always true; the next core
+// can use DecisionOf to distinguish them from the entries of other paths.
+func WithDecisionField() Option {
+	return func(core *filteringCore) {
+		core.decisionField = true
+	}
+}
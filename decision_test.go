@@ -0,0 +1,40 @@
+package zapfilter_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"moul.io/zapfilter"
+)
+
+func TestDecisionField(t *testing.T) {
+	t.Parallel()
+
+	_, found := zapfilter.DecisionOf(nil)
+	require.False(t, found)
+
+	passed, found := zapfilter.DecisionOf([]zapcore.Field{zapfilter.DecisionField(true), zap.String("foo", "bar")})
+	require.True(t, found)
+	require.True(t, passed)
+
+	passed, found = zapfilter.DecisionOf([]zapcore.Field{zapfilter.DecisionField(true), zapfilter.DecisionField(false)})
+	require.True(t, found)
+	require.False(t, passed)
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	core := zapfilter.NewFilteringCore(next, zapfilter.MustParseRules("info+:*"), zapfilter.WithDecisionField())
+	logger := zap.New(core)
+	logger.Debug("a")
+	logger.Info("b", zap.String("foo", "bar"))
+
+	require.Equal(t, 1, logs.Len())
+	entry := logs.All()[0]
+	passed, found = zapfilter.DecisionOf(entry.Context)
+	require.True(t, found)
+	require.True(t, passed)
+	// never encoded
+	require.Equal(t, map[string]interface{}{"foo": "bar"}, entry.ContextMap())
+}
@@ -0,0 +1,194 @@
+package zapfilter
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"unsafe"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Describe returns a human-readable description of a filter built by this package,
+// i.e., `MinimumLevel(info)` or `ByNamespaces("api.*")`.
+//
+// Custom filters are opaque and are described as "custom".
+func Describe(filter FilterFunc) string {
+	if filter == nil {
+		return "<nil>"
+	}
+	if info := infoOf(filter); info != nil {
+		return info.desc
+	}
+	if value, isConstant := constantOf(filter); isConstant {
+		if value {
+			return "AllowAll()"
+		}
+		return "DenyAll()"
+	}
+	return "custom"
+}
+
+// EntrySummary returns a compact description of an entry, i.e.,
+// `level=info logger=api.worker msg="hello"`, to log filtering decisions consistently.
+//
+// The root logger is rendered as "<root>", like in ParseRules.
+func EntrySummary(entry zapcore.Entry) string {
+	logger := entry.LoggerName
+	if logger == "" {
+		logger = rootPattern
+	}
+	return fmt.Sprintf("level=%s logger=%s msg=%q", entry.Level, logger, entry.Message)
+}
+
+// filterInfo holds what is known about a filter built by this package.
+type filterInfo struct {
+	desc     string
+	levels   *LevelSet // superset of the levels that can pass, nil if unknown
+	constant *bool     // set if the filter is AllowAll or DenyAll in disguise
+
+	// set if the filter never reads the fields, besides telling the Check phase with
+	// isCheckPhase, so the cores can skip merging the fields added with With
+	ignoresFields bool
+
+	// composition, used to explain decisions
+	name     string // set by NamedFilter
+	op       filterOp
+	children []FilterFunc
+}
+
+type filterOp int
+
+const (
+	opLeaf filterOp = iota
+	opAny
+	opAll
+)
+
+// describedFilter is a filter built by this package, along with its filterInfo.
+//
+// Described filters are the method values of its Filter method: they all share the
+// code pointer of the method value wrapper, and carry the *describedFilter they are
+// bound to, which infoOf retrieves.
+type describedFilter struct {
+	info   filterInfo
+	filter FilterFunc
+}
+
+// Filter calls the described filter.
+func (d *describedFilter) Filter(entry zapcore.Entry, fields []zapcore.Field) bool {
+	return d.filter(entry, fields)
+}
+
+// boundMethod is the layout of a method value bound to a pointer receiver, see
+// https://golang.org/s/go11func.
+type boundMethod struct {
+	code     uintptr
+	receiver *describedFilter
+}
+
+// describe wraps filter so its filterInfo can be retrieved with infoOf.
+func describe(info filterInfo, filter FilterFunc) FilterFunc {
+	if value, isConstant := sentinelOf(filter); isConstant {
+		info.constant = &value
+		if info.levels == nil {
+			info.levels = &LevelSet{}
+			if value {
+				info.levels = &allLevelSet
+			}
+		}
+	}
+	return (&describedFilter{info: info, filter: filter}).Filter
+}
+
+var describedCode = reflect.ValueOf((&describedFilter{}).Filter).Pointer()
+
+// infoOf returns the filterInfo of a filter built with describe, or nil.
+func infoOf(filter FilterFunc) *filterInfo {
+	if filter == nil || reflect.ValueOf(filter).Pointer() != describedCode {
+		return nil
+	}
+	method := *(**boundMethod)(unsafe.Pointer(&filter))
+	return &method.receiver.info
+}
+
+var (
+	alwaysTrueCode  = reflect.ValueOf(alwaysTrueFilter).Pointer()
+	alwaysFalseCode = reflect.ValueOf(alwaysFalseFilter).Pointer()
+)
+
+// constantOf identifies AllowAll and DenyAll, even when wrapped with describe.
+func constantOf(filter FilterFunc) (value bool, isConstant bool) {
+	if info := infoOf(filter); info != nil {
+		if info.constant != nil {
+			return *info.constant, true
+		}
+		return false, false
+	}
+	return sentinelOf(filter)
+}
+
+// sentinelOf identifies the unwrapped alwaysTrueFilter and alwaysFalseFilter.
+func sentinelOf(filter FilterFunc) (value bool, isSentinel bool) {
+	if filter == nil {
+		return false, false
+	}
+	switch reflect.ValueOf(filter).Pointer() {
+	case alwaysTrueCode:
+		return true, true
+	case alwaysFalseCode:
+		return false, true
+	}
+	return false, false
+}
+
+// ignoresFields returns true if filter is known to never read the fields of the entries.
+func ignoresFields(filter FilterFunc) bool {
+	if _, isConstant := constantOf(filter); isConstant {
+		return true
+	}
+	info := infoOf(filter)
+	return info != nil && info.ignoresFields
+}
+
+// allIgnoreFields returns true if every filter is known to never read the fields.
+func allIgnoreFields(filters []FilterFunc) bool {
+	for _, filter := range filters {
+		if !ignoresFields(filter) {
+			return false
+		}
+	}
+	return true
+}
+
+// describeCall formats a constructor call with already formatted arguments.
+func describeCall(name string, args ...string) string {
+	return name + "(" + strings.Join(args, ", ") + ")"
+}
+
+// describeFilters describes a list of filters, skipping nil ones.
+func describeFilters(filters []FilterFunc) []string {
+	descs := make([]string, 0, len(filters))
+	for _, filter := range filters {
+		if filter != nil {
+			descs = append(descs, Describe(filter))
+		}
+	}
+	return descs
+}
+
+// describeLimits formats levels with their limits, sorted by level.
+func describeLimits(limits map[zapcore.Level]int) string {
+	levels := make([]zapcore.Level, 0, len(limits))
+	for level := range limits {
+		levels = append(levels, level)
+	}
+	sort.Slice(levels, func(i, j int) bool { return levels[i] < levels[j] })
+
+	parts := make([]string, len(levels))
+	for i, level := range levels {
+		parts[i] = fmt.Sprintf("%s=%d", level, limits[level])
+	}
+	return strings.Join(parts, ",")
+}
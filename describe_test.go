@@ -0,0 +1,86 @@
+package zapfilter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"moul.io/zapfilter"
+)
+
+func TestDescribe(t *testing.T) {
+	t.Parallel()
+
+	custom := func(entry zapcore.Entry, fields []zapcore.Field) bool { return true }
+	cases := []struct {
+		name     string
+		filter   zapfilter.FilterFunc
+		expected string
+	}{
+		{"nil", nil, "<nil>"},
+		{"custom", custom, "custom"},
+		{"minimum-level", zapfilter.MinimumLevel(zapcore.InfoLevel), "MinimumLevel(info)"},
+		{"exact-level", zapfilter.ExactLevel(zapcore.WarnLevel), "ExactLevel(warn)"},
+		{"by-namespaces", zapfilter.ByNamespaces("api.*"), `ByNamespaces("api.*")`},
+		{"by-namespaces-wildcard", zapfilter.ByNamespaces("*"), `ByNamespaces("*")`},
+		{"by-levels", mustByLevels("info,warn"), `ByLevels("info,warn")`},
+		{"reverse", zapfilter.Reverse(custom), "Reverse(custom)"},
+		{
+			"nested",
+			zapfilter.Any(zapfilter.All(zapfilter.MinimumLevel(zapcore.ErrorLevel), nil), zapfilter.ByNamespaces("a,b")),
+			`Any(MinimumLevel(error), ByNamespaces("a,b"))`,
+		},
+		{"parse-rules", zapfilter.MustParseRules("info:api.* error:*"), `ParseRules("info:api.* error:*")`},
+		{"parse-rules-empty", zapfilter.MustParseRules(""), `ParseRules("")`},
+		{
+			"rate-limit-by-level",
+			zapfilter.RateLimitByLevel(map[zapcore.Level]int{zapcore.InfoLevel: 10, zapcore.DebugLevel: 50}),
+			"RateLimitByLevel(debug=50,info=10)",
+		},
+		{"allow-all", zapfilter.AllowAll(), "AllowAll()"},
+		{"deny-all", zapfilter.DenyAll(), "DenyAll()"},
+		{"global-rate-limit", zapfilter.GlobalRateLimit(100, 10), "GlobalRateLimit(100, 10)"},
+		{"min-occurrences", zapfilter.MinOccurrences(3, time.Minute), "MinOccurrences(3, 1m0s)"},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.expected, zapfilter.Describe(tc.filter))
+		})
+	}
+}
+
+func TestDescribe_preservesBehavior(t *testing.T) {
+	t.Parallel()
+
+	filter := zapfilter.MinimumLevel(zapcore.InfoLevel)
+	skip := []zapcore.Field{{Type: zapcore.SkipType}}
+	require.True(t, filter(zapcore.Entry{Level: zapcore.InfoLevel}, skip))
+	require.False(t, filter(zapcore.Entry{Level: zapcore.DebugLevel}, skip))
+}
+
+func mustByLevels(pattern string) zapfilter.FilterFunc {
+	filter, err := zapfilter.ByLevels(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return filter
+}
+
+func TestEntrySummary(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		entry    zapcore.Entry
+		expected string
+	}{
+		{zapcore.Entry{Level: zapcore.InfoLevel, LoggerName: "api.worker", Message: "hello"}, `level=info logger=api.worker msg="hello"`},
+		{zapcore.Entry{Level: zapcore.ErrorLevel, Message: `say "hi"` + "\n"}, `level=error logger=<root> msg="say \"hi\"\n"`},
+		{zapcore.Entry{Level: zapcore.Level(-2), LoggerName: "db"}, `level=Level(-2) logger=db msg=""`},
+	}
+	for _, tc := range cases {
+		require.Equal(t, tc.expected, zapfilter.EntrySummary(tc.entry))
+	}
+}
@@ -0,0 +1,52 @@
+package zapfilter
+
+// The bounds of the caches, and the number of keys they hold, for the tests of the
+// zapfilter_test package.
+const (
+	DefaultMaxKeys  = defaultMaxKeys
+	SharedCacheSize = sharedCacheSize
+)
+
+// SharedCacheLen returns the number of keys held by the cache of ByNamespacesShared.
+func SharedCacheLen() int {
+	return sharedCache.len()
+}
+
+// CacheLen returns the number of keys remembered by o.
+func (o *OnceLimiter) CacheLen() int {
+	return o.seen.len()
+}
+
+// CacheLen returns the number of entries tracked by l.
+func (l *MinOccurrencesLimiter) CacheLen() int {
+	return l.seen.len()
+}
+
+// CacheLen returns the number of entries tracked by b.
+func (b *Debouncer) CacheLen() int {
+	return b.lastPassed.len()
+}
+
+// CacheLen returns the number of entries tracked by l.
+func (l *HysteresisLimiter) CacheLen() int {
+	return l.states.len()
+}
+
+// NamespaceCache is the cache of the namespace filters, i.e., ByNamespaces.
+type NamespaceCache = namespaceCache
+
+func NewNamespaceCache(max int) *NamespaceCache {
+	return newNamespaceCache(max)
+}
+
+func (c *namespaceCache) Get(namespace string) (bool, bool) {
+	return c.get(namespace)
+}
+
+func (c *namespaceCache) Set(namespace string, decision bool) {
+	c.set(namespace, decision)
+}
+
+func (c *namespaceCache) Len() int {
+	return c.len()
+}
@@ -0,0 +1,250 @@
+package zapfilter
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// FieldToString returns the value of a field as a canonical string, so field filters
+// and user code compare values the same way.
+//
+// Numbers are formatted in base 10, floats with the shortest representation, durations
+// like time.Duration.String and times with RFC 3339 (nanoseconds). Errors and
+// fmt.Stringer values use their Error and String methods.
+//
+// It returns false for the types it can't render, i.e., objects, arrays or reflected
+// values. The field filters of this package don't render errors and fmt.Stringer
+// values either, see ByFieldValue.
+func FieldToString(f zapcore.Field) (string, bool) {
+	switch f.Type {
+	case zapcore.StringType:
+		return f.String, true
+	case zapcore.ByteStringType:
+		if b, ok := f.Interface.([]byte); ok {
+			return string(b), true
+		}
+	case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type:
+		return strconv.FormatInt(f.Integer, 10), true
+	case zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type, zapcore.UintptrType:
+		return strconv.FormatUint(uint64(f.Integer), 10), true
+	case zapcore.BoolType:
+		return strconv.FormatBool(f.Integer == 1), true
+	case zapcore.DurationType:
+		return time.Duration(f.Integer).String(), true
+	case zapcore.Float64Type:
+		return strconv.FormatFloat(math.Float64frombits(uint64(f.Integer)), 'g', -1, 64), true
+	case zapcore.Float32Type:
+		return strconv.FormatFloat(float64(math.Float32frombits(uint32(f.Integer))), 'g', -1, 32), true
+	case zapcore.TimeType:
+		t := time.Unix(0, f.Integer)
+		if location, ok := f.Interface.(*time.Location); ok {
+			t = t.In(location)
+		}
+		return t.Format(time.RFC3339Nano), true
+	case zapcore.TimeFullType:
+		if t, ok := f.Interface.(time.Time); ok {
+			return t.Format(time.RFC3339Nano), true
+		}
+	case zapcore.ErrorType:
+		if err, ok := f.Interface.(error); ok {
+			return err.Error(), true
+		}
+	case zapcore.StringerType:
+		if stringer, ok := f.Interface.(fmt.Stringer); ok {
+			return stringer.String(), true
+		}
+	}
+	return "", false
+}
+
+// ByAnyField filters entries carrying at least one of the keys.
+//
+// Fields are only known when the entry is written, so Check always passes.
+func ByAnyField(keys ...string) FilterFunc {
+	info := filterInfo{desc: describeCall("ByAnyField", describeKeys(keys)...)}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		if isCheckPhase(fields) {
+			return true
+		}
+		for _, key := range keys {
+			if _, found := findField(fields, key); found {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// ByAllFields filters entries carrying every key, i.e., to keep the entries having
+// both a trace_id and a span_id.
+//
+// Fields are only known when the entry is written, so Check always passes.
+func ByAllFields(keys ...string) FilterFunc {
+	info := filterInfo{desc: describeCall("ByAllFields", describeKeys(keys)...)}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		if isCheckPhase(fields) {
+			return true
+		}
+		for _, key := range keys {
+			if _, found := findField(fields, key); !found {
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// MissingField filters entries not carrying the key, i.e., to route the entries that
+// forgot their request_id to a dedicated core, and fix their call sites.
+//
+// The fields added to the logger with With are taken into account. Fields are only
+// known when the entry is written, so Check always passes.
+func MissingField(key string) FilterFunc {
+	info := filterInfo{desc: describeCall("MissingField", strconv.Quote(key))}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		if isCheckPhase(fields) {
+			return true
+		}
+		_, found := findField(fields, key)
+		return !found
+	})
+}
+
+// ByFieldCount filters entries carrying between min and max fields, inclusive, i.e.,
+// ByFieldCount(50, -1) flags the calls attaching suspiciously many fields, likely from
+// a loop. A negative max means no upper bound.
+//
+// The fields added to the logger with With are counted, but not the fields skipped by
+// the encoder, i.e., zap.Error(nil) or the markers of this package. Fields are only
+// known when the entry is written, so Check always passes.
+func ByFieldCount(min, max int) FilterFunc {
+	info := filterInfo{desc: describeCall("ByFieldCount", strconv.Itoa(min), strconv.Itoa(max))}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		if isCheckPhase(fields) {
+			return true
+		}
+		count := 0
+		for _, field := range fields {
+			if field.Type != zapcore.SkipType {
+				count++
+			}
+		}
+		return count >= min && (max < 0 || count <= max)
+	})
+}
+
+// ByFieldType filters entries having a field with the key of the given type, whatever
+// its value, i.e., ByFieldType("latency", zapcore.DurationType) keeps the entries whose
+// latency is actually a duration, and not a string logged by mistake.
+//
+// Like ByFieldValue, the most recent field with the key wins, and Check always passes.
+func ByFieldType(key string, t zapcore.FieldType) FilterFunc {
+	info := filterInfo{desc: describeCall("ByFieldType", strconv.Quote(key), strconv.Itoa(int(t)))}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		if isCheckPhase(fields) {
+			return true
+		}
+		field, found := findField(fields, key)
+		return found && field.Type == t
+	})
+}
+
+// findField returns the last field with the key, so a field overrides the previous
+// ones like it would in the encoded output.
+func findField(fields []zapcore.Field, key string) (zapcore.Field, bool) {
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i].Key == key && fields[i].Type != zapcore.SkipType {
+			return fields[i], true
+		}
+	}
+	return zapcore.Field{}, false
+}
+
+// describeKeys quotes field keys.
+func describeKeys(keys []string) []string {
+	quoted := make([]string, len(keys))
+	for i, key := range keys {
+		quoted[i] = strconv.Quote(key)
+	}
+	return quoted
+}
+
+// ByFieldValue filters entries having a field with the key whose value, formatted with
+// FieldToString, equals value, i.e., ByFieldValue("tenant", "acme").
+//
+// Only the cheap field types are considered: strings, byte strings, numbers, booleans,
+// durations and times. Errors, fmt.Stringer values, objects, arrays and reflected
+// values never match, and are never evaluated, so filtering doesn't trigger their
+// potentially expensive marshaling.
+//
+// The fields added to the logger with With are taken into account, the most recent
+// field with the key wins. Fields are only known when the entry is written, so Check
+// always passes.
+func ByFieldValue(key, value string) FilterFunc {
+	info := filterInfo{desc: describeCall("ByFieldValue", strconv.Quote(key), strconv.Quote(value))}
+	return describe(info, byFieldValue(key, value, false))
+}
+
+// ByFieldValueOrDefault is like ByFieldValue, but returns defaultPass for the entries
+// without a field with the key, i.e., to keep the entries not labeled with a tenant.
+func ByFieldValueOrDefault(key, value string, defaultPass bool) FilterFunc {
+	info := filterInfo{desc: describeCall("ByFieldValueOrDefault", strconv.Quote(key), strconv.Quote(value), strconv.FormatBool(defaultPass))}
+	return describe(info, byFieldValue(key, value, defaultPass))
+}
+
+func byFieldValue(key, value string, defaultPass bool) FilterFunc {
+	return func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		if isCheckPhase(fields) {
+			return true
+		}
+		field, found := findField(fields, key)
+		if !found {
+			return defaultPass
+		}
+		actual, ok := cheapFieldToString(field)
+		return ok && actual == value
+	}
+}
+
+// cheapFieldToString is FieldToString restricted to the field types it renders without
+// calling user code.
+func cheapFieldToString(f zapcore.Field) (string, bool) {
+	switch f.Type {
+	case zapcore.ErrorType, zapcore.StringerType:
+		return "", false
+	}
+	return FieldToString(f)
+}
+
+// HasError filters entries having a non-nil error field, attached with zap.Error or
+// zap.NamedError, with one of the keys ("error" by default), i.e., to separate the
+// entries carrying an error from plain error-level messages.
+//
+// The errors are only compared to nil, their Error method is never called.
+//
+// Fields are only known when the entry is written, so Check always passes.
+func HasError(keys ...string) FilterFunc {
+	if len(keys) == 0 {
+		keys = []string{"error"}
+	}
+	info := filterInfo{desc: describeCall("HasError", describeKeys(keys)...)}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		if isCheckPhase(fields) {
+			return true
+		}
+		for _, key := range keys {
+			field, found := findField(fields, key)
+			if !found || field.Type != zapcore.ErrorType {
+				continue
+			}
+			if err, ok := field.Interface.(error); ok && err != nil {
+				return true
+			}
+		}
+		return false
+	})
+}
@@ -0,0 +1,329 @@
+package zapfilter_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"moul.io/zapfilter"
+)
+
+func TestFieldToString(t *testing.T) {
+	t.Parallel()
+
+	date := time.Date(2021, 3, 4, 5, 6, 7, 8, time.UTC)
+	cases := []struct {
+		name     string
+		field    zapcore.Field
+		expected string
+		ok       bool
+	}{
+		{"string", zap.String("k", "hello"), "hello", true},
+		{"byte-string", zap.ByteString("k", []byte("hello")), "hello", true},
+		{"int", zap.Int("k", -42), "-42", true},
+		{"int64", zap.Int64("k", 1<<40), "1099511627776", true},
+		{"int32", zap.Int32("k", -32), "-32", true},
+		{"int16", zap.Int16("k", 16), "16", true},
+		{"int8", zap.Int8("k", -8), "-8", true},
+		{"uint64", zap.Uint64("k", 1<<63), "9223372036854775808", true},
+		{"uint32", zap.Uint32("k", 32), "32", true},
+		{"uint16", zap.Uint16("k", 16), "16", true},
+		{"uint8", zap.Uint8("k", 8), "8", true},
+		{"uintptr", zap.Uintptr("k", 0x10), "16", true},
+		{"bool-true", zap.Bool("k", true), "true", true},
+		{"bool-false", zap.Bool("k", false), "false", true},
+		{"duration", zap.Duration("k", 1500*time.Millisecond), "1.5s", true},
+		{"float64", zap.Float64("k", 0.1), "0.1", true},
+		{"float32", zap.Float32("k", 0.1), "0.1", true},
+		{"time", zap.Time("k", date), "2021-03-04T05:06:07.000000008Z", true},
+		{"error", zap.Error(errors.New("oops")), "oops", true},
+		{"stringer", zap.Stringer("k", net.IPv4(127, 0, 0, 1)), "127.0.0.1", true},
+		{"array", zap.Ints("k", []int{1, 2}), "", false},
+		{"reflect", zap.Reflect("k", struct{}{}), "", false},
+		{"skip", zap.Skip(), "", false},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			actual, ok := zapfilter.FieldToString(tc.field)
+			require.Equal(t, tc.ok, ok)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+}
+
+func TestByAnyFieldByAllFields(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	filter := zapfilter.Any(
+		zapfilter.All(zapfilter.ByNamespaces("all"), zapfilter.ByAllFields("trace_id", "span_id")),
+		zapfilter.All(zapfilter.ByNamespaces("any"), zapfilter.ByAnyField("trace_id", "span_id")),
+	)
+	logger := zap.New(zapfilter.NewFilteringCore(next, filter))
+
+	for _, name := range []string{"all", "any"} {
+		logger := logger.Named(name)
+		logger.Info(name+"-none", zap.String("foo", "bar"))
+		logger.Info(name+"-trace", zap.String("trace_id", "1"))
+		logger.Info(name+"-span", zap.String("span_id", "2"))
+		logger.Info(name+"-both", zap.String("trace_id", "1"), zap.String("span_id", "2"))
+	}
+
+	gotLogs := []string{}
+	for _, log := range logs.All() {
+		gotLogs = append(gotLogs, log.Message)
+	}
+	require.Equal(t, []string{"all-both", "any-trace", "any-span", "any-both"}, gotLogs)
+
+	// fields are unknown during Check
+	entry := zapcore.Entry{Level: zapcore.InfoLevel}
+	require.True(t, zapfilter.ByAllFields("trace_id")(entry, nil))
+	require.True(t, zapfilter.ByAnyField("trace_id")(entry, nil))
+	require.True(t, zapfilter.ByAllFields()(entry, []zapcore.Field{}))
+	require.False(t, zapfilter.ByAnyField()(entry, []zapcore.Field{}))
+}
+
+func TestMissingField(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(zapfilter.NewFilteringCore(next, zapfilter.MissingField("request_id")))
+
+	logger.Info("missing")
+	logger.Info("other", zap.String("user_id", "1"))
+	logger.Info("present", zap.String("request_id", "1"))
+	logger.With(zap.String("request_id", "1")).Info("with")
+	logger.Info("skipped", zapcore.Field{Key: "request_id", Type: zapcore.SkipType}) // never encoded
+
+	gotLogs := []string{}
+	for _, log := range logs.All() {
+		gotLogs = append(gotLogs, log.Message)
+	}
+	require.Equal(t, []string{"missing", "other", "skipped"}, gotLogs)
+
+	entry := zapcore.Entry{Level: zapcore.InfoLevel}
+	require.True(t, zapfilter.MissingField("request_id")(entry, nil)) // fields are unknown during Check
+	require.Equal(t, `MissingField("request_id")`, zapfilter.Describe(zapfilter.MissingField("request_id")))
+}
+
+func TestByFieldCount(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(zapfilter.NewFilteringCore(next, zapfilter.ByFieldCount(2, 3)))
+
+	logger.Info("zero")
+	logger.Info("one", zap.Int("a", 1))
+	logger.Info("two", zap.Int("a", 1), zap.Int("b", 2))
+	logger.Info("three", zap.Int("a", 1), zap.Int("b", 2), zap.Int("c", 3))
+	logger.Info("four", zap.Int("a", 1), zap.Int("b", 2), zap.Int("c", 3), zap.Int("d", 4))
+	logger.With(zap.Int("a", 1)).Info("one-plus-one", zap.Int("b", 2))
+	logger.Info("one-plus-skipped", zap.Error(nil), zap.Int("a", 1), zap.Skip())
+
+	gotLogs := []string{}
+	for _, log := range logs.All() {
+		gotLogs = append(gotLogs, log.Message)
+	}
+	require.Equal(t, []string{"two", "three", "one-plus-one"}, gotLogs)
+
+	entry := zapcore.Entry{Level: zapcore.InfoLevel}
+	many := make([]zapcore.Field, 100)
+	require.True(t, zapfilter.ByFieldCount(50, -1)(entry, many))
+	require.False(t, zapfilter.ByFieldCount(50, -1)(entry, many[:49]))
+	require.True(t, zapfilter.ByFieldCount(50, -1)(entry, nil)) // fields are unknown during Check
+	require.True(t, zapfilter.ByFieldCount(0, 0)(entry, []zapcore.Field{}))
+	require.True(t, zapfilter.ByFieldCount(0, 1)(entry, []zapcore.Field{zap.Error(nil), zap.String("a", "b")}))
+	require.True(t, zapfilter.ByFieldCount(2, 2)(entry, []zapcore.Field{zapfilter.ContextLevel(zapcore.DebugLevel), zap.Int("a", 1), zap.Int("b", 2)}))
+	require.Equal(t, "ByFieldCount(50, -1)", zapfilter.Describe(zapfilter.ByFieldCount(50, -1)))
+}
+
+func TestByFieldType(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(zapfilter.NewFilteringCore(next, zapfilter.ByFieldType("latency", zapcore.DurationType)))
+
+	logger.Info("duration", zap.Duration("latency", time.Second))
+	logger.Info("string", zap.String("latency", "1s"))
+	logger.Info("int", zap.Int64("latency", 1000))
+	logger.Info("none")
+	logger.With(zap.String("latency", "1s")).Info("overridden", zap.Duration("latency", time.Second))
+	logger.With(zap.Duration("latency", time.Second)).Info("overridden-string", zap.String("latency", "1s"))
+
+	gotLogs := []string{}
+	for _, log := range logs.All() {
+		gotLogs = append(gotLogs, log.Message)
+	}
+	require.Equal(t, []string{"duration", "overridden"}, gotLogs)
+
+	entry := zapcore.Entry{Level: zapcore.InfoLevel}
+	byType := zapfilter.ByFieldType("latency", zapcore.DurationType)
+	require.True(t, byType(entry, nil)) // fields are unknown during Check
+	require.True(t, byType(entry, []zapcore.Field{zap.Duration("latency", 0)}))
+	require.False(t, byType(entry, []zapcore.Field{zap.String("latency", "0s")}))
+	require.False(t, byType(entry, []zapcore.Field{zap.Duration("other", 0)}))
+	require.False(t, byType(entry, []zapcore.Field{}))
+	require.Equal(t, `ByFieldType("latency", 8)`, zapfilter.Describe(byType))
+}
+
+func TestByFieldValue(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(zapfilter.NewFilteringCore(next, zapfilter.ByFieldValue("tenant", "acme")))
+
+	logger.Info("a")
+	logger.Info("b", zap.String("tenant", "acme"))
+	logger.Info("c", zap.String("tenant", "other"))
+	acme := logger.With(zap.String("tenant", "acme"), zap.Int("shard", 1))
+	acme.Info("d")
+	acme.Info("e", zap.String("tenant", "other")) // the most recent field wins
+	acme.With(zap.String("tenant", "other")).Info("f")
+	logger.With(zap.Int("tenant", 42)).Info("g")
+	logger.Info("h", zap.Stringer("tenant", zapcore.InfoLevel))
+
+	gotLogs := []string{}
+	for _, log := range logs.All() {
+		gotLogs = append(gotLogs, log.Message)
+	}
+	require.Equal(t, []string{"b", "d"}, gotLogs)
+	require.True(t, zapfilter.ByFieldValue("tenant", "acme")(zapcore.Entry{}, nil))
+	require.Equal(t, `ByFieldValue("tenant", "acme")`, zapfilter.Describe(zapfilter.ByFieldValue("tenant", "acme")))
+}
+
+func TestFilterContext_ignoredFields(t *testing.T) {
+	// the context is still merged for the compositions reading the fields
+	next, logs := observer.New(zapcore.DebugLevel)
+	filter := zapfilter.All(zapfilter.MustParseRules("debug:api.*"), zapfilter.ByFieldValue("tenant", "acme"))
+	logger := zap.New(zapfilter.NewFilteringCore(next, filter)).Named("api").Named("users")
+	logger.With(zap.String("tenant", "acme")).Debug("a")
+	logger.With(zap.String("tenant", "other")).Debug("b")
+	require.Equal(t, 1, logs.Len())
+	require.Equal(t, "a", logs.All()[0].Message)
+
+	// but not for the filters ignoring them, which would allocate for each entry
+	discard := zapcore.NewCore(zapcore.NewJSONEncoder(zapcore.EncoderConfig{}), zapcore.AddSync(ioutil.Discard), zapcore.DebugLevel)
+	raw := zap.New(discard).Named("api").Named("users").With(zap.String("tenant", "acme"))
+	filtered := zap.New(zapfilter.NewFilteringCore(discard, zapfilter.MustParseRules("debug:api.* error:*"))).
+		Named("api").Named("users").With(zap.String("tenant", "acme"))
+	rawAllocs := testing.AllocsPerRun(100, func() { raw.Debug("hello", zap.Int("i", 1)) })
+	filteredAllocs := testing.AllocsPerRun(100, func() { filtered.Debug("hello", zap.Int("i", 1)) })
+	require.Equal(t, rawAllocs, filteredAllocs)
+}
+
+func ExampleByFieldValue() {
+	core := zap.NewExample().Core()
+	logger := zap.New(zapfilter.NewFilteringCore(core, zapfilter.ByFieldValue("tenant", "acme")))
+	defer logger.Sync()
+
+	logger.With(zap.String("tenant", "acme")).Info("hello city!")
+	logger.With(zap.String("tenant", "other")).Info("hello region!")
+	logger.With(zap.Int("tenant", 42)).Info("hello planet!")
+	logger.Info("hello solar system!", zap.String("tenant", "acme"))
+
+	// Output:
+	// {"level":"info","msg":"hello city!","tenant":"acme"}
+	// {"level":"info","msg":"hello solar system!","tenant":"acme"}
+}
+
+// expensiveValue counts its evaluations, for every lazy field type.
+type expensiveValue struct{ calls int }
+
+func (v *expensiveValue) Error() string  { v.calls++; return "acme" }
+func (v *expensiveValue) String() string { v.calls++; return "acme" }
+
+func (v *expensiveValue) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	v.calls++
+	return nil
+}
+
+func (v *expensiveValue) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	v.calls++
+	return nil
+}
+
+func TestByFieldValue_expensiveTypes(t *testing.T) {
+	t.Parallel()
+
+	value := &expensiveValue{}
+	cases := []zapcore.Field{
+		zap.NamedError("tenant", value),
+		zap.Stringer("tenant", value),
+		zap.Object("tenant", value),
+		zap.Array("tenant", value),
+		zap.Inline(value),
+		zap.Reflect("tenant", value),
+		zap.Any("tenant", value),
+	}
+	cases[4].Key = "tenant"
+
+	entry := zapcore.Entry{}
+	filter := zapfilter.ByFieldValue("tenant", "acme")
+	for _, field := range cases {
+		require.False(t, filter(entry, []zapcore.Field{field}), field.Type)
+	}
+	require.True(t, zapfilter.ByAnyField("tenant")(entry, cases))
+	require.True(t, zapfilter.HasError("tenant")(entry, cases[:1]))
+	require.Equal(t, 0, value.calls)
+}
+
+func TestByFieldValueOrDefault(t *testing.T) {
+	t.Parallel()
+
+	entry := zapcore.Entry{Level: zapcore.InfoLevel}
+	matching := []zapcore.Field{zap.String("tenant", "acme")}
+	nonMatching := []zapcore.Field{zap.String("tenant", "other")}
+	absent := []zapcore.Field{zap.String("foo", "bar")}
+
+	pass := zapfilter.ByFieldValueOrDefault("tenant", "acme", true)
+	require.True(t, pass(entry, matching))
+	require.False(t, pass(entry, nonMatching))
+	require.True(t, pass(entry, absent))
+	require.True(t, pass(entry, []zapcore.Field{}))
+
+	drop := zapfilter.ByFieldValueOrDefault("tenant", "acme", false)
+	require.True(t, drop(entry, matching))
+	require.False(t, drop(entry, nonMatching))
+	require.False(t, drop(entry, absent))
+	require.True(t, drop(entry, nil)) // check phase
+
+	require.Equal(t, `ByFieldValueOrDefault("tenant", "acme", true)`, zapfilter.Describe(pass))
+}
+
+func TestHasError(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	filter := zapfilter.All(zapfilter.MinimumLevel(zapcore.ErrorLevel), zapfilter.HasError())
+	logger := zap.New(zapfilter.NewFilteringCore(next, filter))
+
+	logger.Error("a")
+	logger.Error("b", zap.Error(errors.New("oops")))
+	logger.Error("c", zap.Error(nil))
+	logger.Error("d", zap.String("error", "not an error"))
+	logger.Error("e", zap.NamedError("cause", errors.New("oops")))
+	logger.Info("f", zap.Error(errors.New("oops")))
+	logger.With(zap.Error(errors.New("oops"))).Error("g")
+
+	gotLogs := []string{}
+	for _, log := range logs.All() {
+		gotLogs = append(gotLogs, log.Message)
+	}
+	require.Equal(t, []string{"b", "g"}, gotLogs)
+
+	entry := zapcore.Entry{Level: zapcore.ErrorLevel}
+	cause := zapfilter.HasError("cause", "err")
+	require.True(t, cause(entry, []zapcore.Field{zap.NamedError("err", errors.New("oops"))}))
+	require.False(t, cause(entry, []zapcore.Field{zap.Error(errors.New("oops"))}))
+	require.True(t, cause(entry, nil)) // check phase
+	require.Equal(t, `HasError("cause", "err")`, zapfilter.Describe(cause))
+	require.Equal(t, `HasError("error")`, zapfilter.Describe(zapfilter.HasError()))
+}
@@ -0,0 +1,59 @@
+package zapfilter
+
+// RulesValue holds rules set from the command line, see ParseRules for the syntax.
+//
+// It implements flag.Value and flag.Getter of the standard library, as well as the
+// pflag.Value interface of github.com/spf13/pflag and the cli.Generic interface of
+// github.com/urfave/cli, without depending on them:
+//
+//   var rules zapfilter.RulesValue
+//   _ = rules.Set("info+:*") // default
+//   pflag.Var(&rules, "log-rules", "logging rules")
+//   // urfave/cli: &cli.GenericFlag{Name: "log-rules", Value: &rules}
+//
+// The zero value holds no rule, so its filter drops every entry.
+type RulesValue struct {
+	rules *Rules
+}
+
+// Set parses and compiles the rules, keeping the previous ones on error.
+func (v *RulesValue) Set(pattern string) error {
+	rules, err := CompileRules(pattern)
+	if err != nil {
+		return err
+	}
+	v.rules = rules
+	return nil
+}
+
+// String returns the rules as they were written.
+func (v *RulesValue) String() string {
+	if v == nil || v.rules == nil {
+		return ""
+	}
+	return v.rules.String()
+}
+
+// Type describes the value for pflag.
+func (v *RulesValue) Type() string {
+	return "rules"
+}
+
+// Get returns the compiled *Rules, for flag.Getter.
+func (v *RulesValue) Get() interface{} {
+	return v.Rules()
+}
+
+// Rules returns the compiled rules; never nil.
+func (v *RulesValue) Rules() *Rules {
+	if v.rules == nil {
+		rules, _ := CompileRules("")
+		return rules
+	}
+	return v.rules
+}
+
+// FilterFunc returns the filter matching the entries selected by the rules.
+func (v *RulesValue) FilterFunc() FilterFunc {
+	return v.Rules().FilterFunc()
+}
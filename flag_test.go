@@ -0,0 +1,57 @@
+package zapfilter_test
+
+import (
+	"flag"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"moul.io/zapfilter"
+	"moul.io/zapfilter/zapfiltertest"
+)
+
+// the interfaces of github.com/spf13/pflag and github.com/urfave/cli
+type (
+	pflagValue interface {
+		String() string
+		Set(string) error
+		Type() string
+	}
+	cliGeneric interface {
+		Set(value string) error
+		String() string
+	}
+)
+
+var (
+	_ flag.Getter = (*zapfilter.RulesValue)(nil)
+	_ pflagValue  = (*zapfilter.RulesValue)(nil)
+	_ cliGeneric  = (*zapfilter.RulesValue)(nil)
+)
+
+func TestRulesValue(t *testing.T) {
+	t.Parallel()
+
+	var value zapfilter.RulesValue
+	require.Equal(t, "", value.String())
+	require.Equal(t, "rules", value.Type())
+	zapfiltertest.AssertDrops(t, value.FilterFunc(), zapfiltertest.Entry(zapcore.ErrorLevel, "api", "hello"))
+
+	require.NoError(t, value.Set("info+:*"))
+	require.Equal(t, "info+:*", value.String())
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.Var(&value, "log-rules", "logging rules")
+	require.NoError(t, fs.Parse([]string{"-log-rules", "debug:api.* error:*"}))
+	require.Equal(t, "debug:api.* error:*", value.String())
+	require.Equal(t, "debug:api.* error:*", value.Get().(*zapfilter.Rules).String())
+	zapfiltertest.AssertPasses(t, value.FilterFunc(), zapfiltertest.Entry(zapcore.DebugLevel, "api.users", "hello"))
+	zapfiltertest.AssertDrops(t, value.FilterFunc(), zapfiltertest.Entry(zapcore.InfoLevel, "db", "hello"))
+
+	// errors are surfaced, and the previous rules kept
+	require.Error(t, fs.Parse([]string{"-log-rules", "invalid:*"}))
+	require.EqualError(t, value.Set("invalid:*"), `unsupported keyword: "invalid"`)
+	require.Equal(t, "debug:api.* error:*", value.String())
+}
@@ -0,0 +1,24 @@
+package zapfilter
+
+import (
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Fresh drops the entries whose time is older than maxAge, i.e., to discard stale
+// entries when replaying buffered logs.
+//
+// Live entries are written right after their creation, so their age is close to zero:
+// Fresh is meant for buffered or replayed entries.
+func Fresh(maxAge time.Duration) FilterFunc {
+	return FreshWithClock(maxAge, time.Now)
+}
+
+// FreshWithClock is like Fresh, but gets the current time from now.
+func FreshWithClock(maxAge time.Duration, now func() time.Time) FilterFunc {
+	info := filterInfo{desc: describeCall("Fresh", maxAge.String()), ignoresFields: true}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		return now().Sub(entry.Time) <= maxAge
+	})
+}
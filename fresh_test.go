@@ -0,0 +1,43 @@
+package zapfilter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"moul.io/zapfilter"
+)
+
+func TestFresh(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	filter := zapfilter.FreshWithClock(time.Minute, func() time.Time { return now })
+
+	cases := []struct {
+		name     string
+		age      time.Duration
+		expected bool
+	}{
+		{"now", 0, true},
+		{"recent", 30 * time.Second, true},
+		{"limit", time.Minute, true},
+		{"stale", time.Minute + time.Nanosecond, false},
+		{"old", 24 * time.Hour, false},
+		{"future", -time.Hour, true},
+	}
+	for _, tc := range cases {
+		entry := zapcore.Entry{Time: now.Add(-tc.age)}
+		require.Equal(t, tc.expected, filter(entry, nil), tc.name)
+	}
+	require.Equal(t, "Fresh(1m0s)", zapfilter.Describe(filter))
+
+	// live entries are fresh
+	next, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(zapfilter.NewFilteringCore(next, zapfilter.Fresh(time.Minute)))
+	logger.Info("hello")
+	require.Equal(t, 1, logs.Len())
+}
@@ -0,0 +1,37 @@
+package zapfilter
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// internalKey is the key of the fields created by Internal.
+const internalKey = "zapfilter.internal"
+
+// Internal returns a field marking the entries of a logger as generated by the filtering
+// machinery itself, so every filtering core lets them pass without calling its filter.
+//
+// Callbacks given to this package, i.e., the onPanic of WithRecover, must log with such
+// a logger when they log with the logger they filter, otherwise they would call the
+// filter again, recursing forever or deadlocking on the filter's own mutex:
+//
+//   var logger *zap.Logger
+//   core := zapfilter.NewFilteringCore(next, filter, zapfilter.WithRecover(func(r interface{}) {
+//       logger.With(zapfilter.Internal()).Error("filter panicked", zap.Any("panic", r))
+//   }))
+//   logger = zap.New(core)
+//
+// The field has to be attached with With: the fields of a logging call are only known
+// once the filter has already been called by Check. The field is never encoded.
+func Internal() zapcore.Field {
+	return zapcore.Field{Key: internalKey, Type: zapcore.SkipType}
+}
+
+// hasInternal returns true if fields contain an Internal field.
+func hasInternal(fields []zapcore.Field) bool {
+	for _, field := range fields {
+		if field.Type == zapcore.SkipType && field.Key == internalKey {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,55 @@
+package zapfilter_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"moul.io/zapfilter"
+)
+
+func TestInternal(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	calls, depth, maxDepth := 0, 0, 0
+	panicking := func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		calls++
+		panic("oops")
+	}
+
+	var logger *zap.Logger
+	onPanic := func(r interface{}) {
+		depth++
+		defer func() { depth-- }()
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+		if depth > 3 {
+			return // without the guard, the filter would be called again, forever
+		}
+		logger.With(zapfilter.Internal()).Error("filter panicked", zap.String("panic", fmt.Sprint(r)))
+	}
+	core := zapfilter.NewFilteringCore(next, panicking, zapfilter.WithRecover(onPanic))
+	logger = zap.New(core)
+
+	logger.Info("a")
+	logger.With(zapfilter.Internal()).Debug("b")
+
+	require.Equal(t, 1, calls)
+	require.Equal(t, 1, maxDepth)
+	gotLogs := []string{}
+	for _, log := range logs.All() {
+		gotLogs = append(gotLogs, log.Message)
+	}
+	require.Equal(t, []string{"filter panicked", "b"}, gotLogs)
+	require.Equal(t, map[string]interface{}{"panic": "oops"}, logs.All()[0].ContextMap())
+
+	// the levels of the filter don't apply to internal loggers
+	core = zapfilter.NewFilteringCore(next, zapfilter.MustParseRules("warn+:*"))
+	require.False(t, core.Enabled(zapcore.DebugLevel))
+	require.True(t, core.With([]zapcore.Field{zapfilter.Internal()}).Enabled(zapcore.DebugLevel))
+}
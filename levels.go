@@ -0,0 +1,271 @@
+package zapfilter
+
+import (
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// LevelSet is a set of levels, including custom ones.
+//
+// The zero value is an empty set.
+type LevelSet struct {
+	bits [4]uint64 // one bit per possible zapcore.Level, from math.MinInt8 to math.MaxInt8
+}
+
+// NewLevelSet returns a set containing the given levels.
+func NewLevelSet(levels ...zapcore.Level) LevelSet {
+	var set LevelSet
+	for _, level := range levels {
+		set.Add(level)
+	}
+	return set
+}
+
+// levelsFrom returns the set of every level greater than or equal to min.
+func levelsFrom(min zapcore.Level) LevelSet {
+	var set LevelSet
+	for level := int(min); level <= math.MaxInt8; level++ {
+		set.Add(zapcore.Level(level))
+	}
+	return set
+}
+
+// levelsUpTo returns the set of every level lower than or equal to max.
+func levelsUpTo(max zapcore.Level) LevelSet {
+	var set LevelSet
+	for level := math.MinInt8; level <= int(max); level++ {
+		set.Add(zapcore.Level(level))
+	}
+	return set
+}
+
+// builtinLevelsFrom returns the set of zap's levels greater than or equal to min.
+func builtinLevelsFrom(min zapcore.Level) LevelSet {
+	var set LevelSet
+	for _, level := range allLevels {
+		if level >= min {
+			set.Add(level)
+		}
+	}
+	return set
+}
+
+// allLevelSet contains every possible level.
+var allLevelSet = levelsFrom(math.MinInt8)
+
+func levelIndex(level zapcore.Level) (int, uint64) {
+	index := int(level) - math.MinInt8
+	return index / 64, 1 << uint(index%64)
+}
+
+// Add adds a level to the set.
+func (s *LevelSet) Add(level zapcore.Level) {
+	word, mask := levelIndex(level)
+	s.bits[word] |= mask
+}
+
+// Has returns true if the level is part of the set.
+func (s LevelSet) Has(level zapcore.Level) bool {
+	word, mask := levelIndex(level)
+	return s.bits[word]&mask != 0
+}
+
+// Len returns the number of levels in the set.
+func (s LevelSet) Len() int {
+	count := 0
+	for _, word := range s.bits {
+		count += bits.OnesCount64(word)
+	}
+	return count
+}
+
+// IsEmpty returns true if the set has no level.
+func (s LevelSet) IsEmpty() bool {
+	return s == LevelSet{}
+}
+
+// Levels returns the levels of the set, in ascending order.
+func (s LevelSet) Levels() []zapcore.Level {
+	levels := make([]zapcore.Level, 0, s.Len())
+	for level := math.MinInt8; level <= math.MaxInt8; level++ {
+		if s.Has(zapcore.Level(level)) {
+			levels = append(levels, zapcore.Level(level))
+		}
+	}
+	return levels
+}
+
+// Min returns the lowest level of the set, or false if the set is empty.
+func (s LevelSet) Min() (zapcore.Level, bool) {
+	for word, value := range s.bits {
+		if value != 0 {
+			return zapcore.Level(word*64 + bits.TrailingZeros64(value) + math.MinInt8), true
+		}
+	}
+	return 0, false
+}
+
+// Union returns the levels part of either set.
+func (s LevelSet) Union(other LevelSet) LevelSet {
+	for i := range s.bits {
+		s.bits[i] |= other.bits[i]
+	}
+	return s
+}
+
+// Intersect returns the levels part of both sets.
+func (s LevelSet) Intersect(other LevelSet) LevelSet {
+	for i := range s.bits {
+		s.bits[i] &= other.bits[i]
+	}
+	return s
+}
+
+// Difference returns the levels of the set that aren't part of the other set.
+func (s LevelSet) Difference(other LevelSet) LevelSet {
+	for i := range s.bits {
+		s.bits[i] &^= other.bits[i]
+	}
+	return s
+}
+
+// LevelSetToken returns the most compact LEVELS token of ParseRules matching the set,
+// i.e., "info+" rather than "info,warn,error,dpanic,panic,fatal", "debug+" for every
+// level of zap, "*" for every level, custom ones included, or "debug,warn+".
+//
+// Custom levels are rendered as integers, i.e., "-2", and every level from a custom
+// level or above, custom ones included, as "-2+". An empty set renders as an empty
+// string.
+func LevelSetToken(set LevelSet) string {
+	if set == allLevelSet {
+		return "*"
+	}
+	// the lowest level from which every level, up to math.MaxInt8, is in the set
+	top := math.MaxInt8 + 1
+	for top > math.MinInt8 && set.Has(zapcore.Level(top-1)) {
+		top--
+	}
+	if top < math.MaxInt8 {
+		set = set.Difference(levelsFrom(zapcore.Level(top)))
+	}
+
+	var tokens []string
+	// the lowest builtin level from which every builtin level is in the set
+	from := len(allLevels)
+	for from > 0 && set.Has(allLevels[from-1]) {
+		from--
+	}
+	for _, level := range set.Levels() {
+		switch {
+		case from < len(allLevels) && level == allLevels[from]:
+			switch {
+			case from == len(allLevels)-1:
+				tokens = append(tokens, level.String())
+			default:
+				tokens = append(tokens, level.String()+"+")
+			}
+		case from < len(allLevels) && level > allLevels[from] && level <= zapcore.FatalLevel:
+			// covered by the "+" token
+		case level < zapcore.DebugLevel || level > zapcore.FatalLevel:
+			tokens = append(tokens, strconv.Itoa(int(level)))
+		default:
+			tokens = append(tokens, level.String())
+		}
+	}
+	if top < math.MaxInt8 {
+		tokens = append(tokens, strconv.Itoa(top)+"+")
+	}
+	return strings.Join(tokens, ",")
+}
+
+// EffectiveMinLevel returns the lowest level that can pass the filter.
+//
+// It only works with filters whose levels can be derived from their construction,
+// i.e., MinimumLevel, ExactLevel, ByLevels, ParseRules, and their combinations with
+// All, Any and the namespace filters. It returns false for other filters, and for
+// filters that can't pass any level.
+//
+// Filters that don't restrict levels, like ByNamespaces, return the lowest possible
+// level, zapcore.Level(math.MinInt8).
+func EffectiveMinLevel(filter FilterFunc) (zapcore.Level, bool) {
+	levels := levelsOf(filter)
+	if levels == nil {
+		return 0, false
+	}
+	return levels.Min()
+}
+
+// LevelEnabler returns a zapcore.LevelEnabler enabling the levels that can pass the
+// filter, i.e., to build a zapcore.NewCore with the same level gating as the filter:
+//
+//   core := zapcore.NewCore(encoder, sink, zapfilter.LevelEnabler(filter))
+//
+// It is what the Enabled method of NewFilteringCore relies on, without asking the next
+// core. Levels are only known for the filters EffectiveMinLevel understands; for the
+// others, every level is enabled, and the filter is left to decide in Check.
+func LevelEnabler(filter FilterFunc) zapcore.LevelEnabler {
+	levels := levelsOf(filter)
+	if levels == nil {
+		levels = &allLevelSet
+	}
+	return zap.LevelEnablerFunc(levels.Has)
+}
+
+// levelsOf returns a superset of the levels that can pass the filter, or nil if they
+// are unknown.
+func levelsOf(filter FilterFunc) *LevelSet {
+	if info := infoOf(filter); info != nil {
+		return info.levels
+	}
+	if value, isConstant := constantOf(filter); isConstant {
+		if value {
+			return &allLevelSet
+		}
+		return &LevelSet{}
+	}
+	return nil
+}
+
+// anyFiltersLevels returns the union of the levels of the filters, or nil if one of them is
+// unknown.
+func anyFiltersLevels(filters []FilterFunc) *LevelSet {
+	var union LevelSet
+	for _, filter := range filters {
+		if filter == nil {
+			continue
+		}
+		levels := levelsOf(filter)
+		if levels == nil {
+			return nil
+		}
+		union = union.Union(*levels)
+	}
+	return &union
+}
+
+// allFiltersLevels returns the intersection of the known levels of the filters, or nil if
+// none of them is known.
+func allFiltersLevels(filters []FilterFunc) *LevelSet {
+	var intersection *LevelSet
+	for _, filter := range filters {
+		if filter == nil {
+			continue
+		}
+		levels := levelsOf(filter)
+		if levels == nil {
+			continue
+		}
+		if intersection == nil {
+			intersection = levels
+			continue
+		}
+		merged := intersection.Intersect(*levels)
+		intersection = &merged
+	}
+	return intersection
+}
@@ -0,0 +1,141 @@
+package zapfilter_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"moul.io/zapfilter"
+)
+
+func TestLevelSet(t *testing.T) {
+	t.Parallel()
+
+	var set zapfilter.LevelSet
+	require.True(t, set.IsEmpty())
+	_, found := set.Min()
+	require.False(t, found)
+
+	set = zapfilter.NewLevelSet(zapcore.ErrorLevel, zapcore.Level(-2), zapcore.Level(math.MaxInt8), zapcore.Level(math.MinInt8))
+	require.Equal(t, 4, set.Len())
+	require.True(t, set.Has(zapcore.ErrorLevel))
+	require.False(t, set.Has(zapcore.InfoLevel))
+	require.Equal(t, []zapcore.Level{math.MinInt8, -2, zapcore.ErrorLevel, math.MaxInt8}, set.Levels())
+	min, found := set.Min()
+	require.True(t, found)
+	require.Equal(t, zapcore.Level(math.MinInt8), min)
+
+	other := zapfilter.NewLevelSet(zapcore.InfoLevel, zapcore.ErrorLevel)
+	require.Equal(t, []zapcore.Level{zapcore.ErrorLevel}, set.Intersect(other).Levels())
+	require.Equal(t, 5, set.Union(other).Len())
+}
+
+func TestEffectiveMinLevel(t *testing.T) {
+	t.Parallel()
+
+	custom := func(entry zapcore.Entry, fields []zapcore.Field) bool { return true }
+	cases := []struct {
+		name          string
+		filter        zapfilter.FilterFunc
+		expectedLevel zapcore.Level
+		expectedFound bool
+	}{
+		{"everything", zapfilter.MustParseRules("*"), math.MinInt8, true},
+		{"builtins", zapfilter.MustParseRules("debug+:*"), zapcore.DebugLevel, true},
+		{"info", zapfilter.MustParseRules("info:*"), zapcore.InfoLevel, true},
+		{"warn+", zapfilter.MustParseRules("warn+:*"), zapcore.WarnLevel, true},
+		{"multiple-rules", zapfilter.MustParseRules("error:* info,warn:foo"), zapcore.InfoLevel, true},
+		{"namespace-only", zapfilter.MustParseRules("foo"), math.MinInt8, true},
+		{"empty", zapfilter.MustParseRules(""), 0, false},
+		{"minimum-level", zapfilter.MinimumLevel(zapcore.WarnLevel), zapcore.WarnLevel, true},
+		{"exact-level", zapfilter.ExactLevel(zapcore.ErrorLevel), zapcore.ErrorLevel, true},
+		{"by-namespaces", zapfilter.ByNamespaces("foo"), math.MinInt8, true},
+		{"all-with-custom", zapfilter.All(custom, zapfilter.MinimumLevel(zapcore.InfoLevel)), zapcore.InfoLevel, true},
+		{"all-disjoint", zapfilter.All(zapfilter.ExactLevel(zapcore.InfoLevel), zapfilter.ExactLevel(zapcore.WarnLevel)), 0, false},
+		{"any-with-custom", zapfilter.Any(custom, zapfilter.MinimumLevel(zapcore.InfoLevel)), 0, false},
+		{"named", zapfilter.NamedFilter("foo", zapfilter.MinimumLevel(zapcore.InfoLevel)), zapcore.InfoLevel, true},
+		{"reverse", zapfilter.Reverse(zapfilter.MinimumLevel(zapcore.InfoLevel)), 0, false},
+		{"custom", custom, 0, false},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			level, found := zapfilter.EffectiveMinLevel(tc.filter)
+			require.Equal(t, tc.expectedFound, found)
+			require.Equal(t, tc.expectedLevel, level)
+		})
+	}
+}
+
+func TestLevelSetToken(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		levels   []zapcore.Level
+		expected string
+	}{
+		{nil, ""},
+		{[]zapcore.Level{zapcore.DebugLevel, zapcore.InfoLevel, zapcore.WarnLevel, zapcore.ErrorLevel, zapcore.DPanicLevel, zapcore.PanicLevel, zapcore.FatalLevel}, "debug+"},
+		{[]zapcore.Level{zapcore.InfoLevel, zapcore.WarnLevel, zapcore.ErrorLevel, zapcore.DPanicLevel, zapcore.PanicLevel, zapcore.FatalLevel}, "info+"},
+		{[]zapcore.Level{zapcore.WarnLevel, zapcore.ErrorLevel, zapcore.DPanicLevel, zapcore.PanicLevel, zapcore.FatalLevel}, "warn+"},
+		{[]zapcore.Level{zapcore.PanicLevel, zapcore.FatalLevel}, "panic+"},
+		{[]zapcore.Level{zapcore.InfoLevel}, "info"},
+		{[]zapcore.Level{zapcore.FatalLevel}, "fatal"},
+		{[]zapcore.Level{zapcore.InfoLevel, zapcore.WarnLevel}, "info,warn"},
+		{[]zapcore.Level{zapcore.DebugLevel, zapcore.WarnLevel, zapcore.ErrorLevel, zapcore.DPanicLevel, zapcore.PanicLevel, zapcore.FatalLevel}, "debug,warn+"},
+		{[]zapcore.Level{zapcore.Level(-3), zapcore.ErrorLevel, zapcore.DPanicLevel, zapcore.PanicLevel, zapcore.FatalLevel, zapcore.Level(10)}, "-3,error+,10"},
+		{[]zapcore.Level{zapcore.Level(-2)}, "-2"},
+		{[]zapcore.Level{zapcore.Level(126), zapcore.Level(127)}, "126+"},
+		{[]zapcore.Level{zapcore.Level(127)}, "127"},
+	}
+	for _, tc := range cases {
+		require.Equal(t, tc.expected, zapfilter.LevelSetToken(zapfilter.NewLevelSet(tc.levels...)), tc.expected)
+	}
+
+	// tokens round-trip through ParseRules
+	for _, token := range []string{"*", "debug+", "info+", "warn+", "panic+", "info", "fatal", "info,warn", "debug,warn+", "-2", "-2+", "-3,error+,10", "126+", "debug,3+"} {
+		rules, err := zapfilter.CompileRules(token + ":*")
+		require.NoError(t, err)
+		require.Equal(t, token, zapfilter.LevelSetToken(rules.Decompose()[0].Levels))
+	}
+}
+
+func TestLevelEnabler(t *testing.T) {
+	t.Parallel()
+
+	filter := zapfilter.MustParseRules("debug:api.* warn+:*")
+	enabler := zapfilter.LevelEnabler(filter)
+	require.True(t, enabler.Enabled(zapcore.DebugLevel))
+	require.False(t, enabler.Enabled(zapcore.InfoLevel))
+	require.True(t, enabler.Enabled(zapcore.ErrorLevel))
+	require.False(t, enabler.Enabled(zapcore.Level(-2)))
+
+	// unknown levels are all enabled
+	custom := func(zapcore.Entry, []zapcore.Field) bool { return false }
+	require.True(t, zapfilter.LevelEnabler(custom).Enabled(zapcore.InfoLevel))
+	require.False(t, zapfilter.LevelEnabler(zapfilter.DenyAll()).Enabled(zapcore.InfoLevel))
+
+	// gate a core with the levels of the filter, or with a filtering core
+	core, logs := observer.New(enabler)
+	logger := zap.New(core)
+	logger.Debug("a")
+	logger.Info("b")
+	logger.Warn("c")
+
+	all, _ := observer.New(zapcore.DebugLevel)
+	gated, gatedLogs := observer.New(zapfilter.NewFilteringCore(all, filter))
+	gatedLogger := zap.New(gated)
+	gatedLogger.Info("d")
+	gatedLogger.Error("e")
+
+	require.Equal(t, 2, logs.Len())
+	require.Equal(t, "a", logs.All()[0].Message)
+	require.Equal(t, "c", logs.All()[1].Message)
+	require.Equal(t, 1, gatedLogs.Len())
+	require.Equal(t, "e", gatedLogs.All()[0].Message)
+}
@@ -0,0 +1,221 @@
+package zapfilter
+
+import (
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// MappingFunc rewrites an entry and its fields before they are written.
+//
+// It must not modify the fields slice it receives in place, but return a new one.
+//
+// It is also called by Check, with nil fields like the filters, to check the mapped
+// entry with the next core; the fields it returns are then ignored.
+type MappingFunc func(zapcore.Entry, []zapcore.Field) (zapcore.Entry, []zapcore.Field)
+
+// NewMappingCore returns a core middleware that rewrites entries with mapping before
+// calling Write on the next core in the chain.
+//
+// Fields added with With are kept by the mapping core and passed to mapping along
+// with the fields of each entry, so they are rewritten too; they are only encoded by
+// the next core when an entry is written.
+func NewMappingCore(next zapcore.Core, mapping MappingFunc) zapcore.Core {
+	return &mappingCore{next: next, mapping: mapping}
+}
+
+type mappingCore struct {
+	next    zapcore.Core
+	mapping MappingFunc
+	context []zapcore.Field
+}
+
+// Check lets the next core check the mapped entry, so its own filtering applies, i.e.,
+// a sampler or the levels of the cores of a zapcore.NewTee, then adds itself to map
+// the entry before writing it to the cores the next core selected.
+func (core *mappingCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	mapped, _ := core.mapping(entry, nil)
+	checked := core.next.Check(mapped, nil)
+	if checked == nil {
+		return ce
+	}
+	checkedCore := &checkedMappingCore{mappingCore: core, checked: checked}
+	checkedCore.parent = ce.AddCore(entry, checkedCore)
+	return checkedCore.parent
+}
+
+// Write maps the entry, with the fields added with With first, then writes it to the
+// next core.
+func (core *mappingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry, fields = core.apply(entry, fields)
+	return core.next.Write(entry, fields)
+}
+
+// apply maps the entry, with the fields added with With first.
+func (core *mappingCore) apply(entry zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+	if fields == nil {
+		// nil fields are reserved to the Check phase.
+		fields = []zapcore.Field{}
+	}
+	if len(core.context) > 0 {
+		fields = append(core.context[:len(core.context):len(core.context)], fields...)
+	}
+	return core.mapping(entry, fields)
+}
+
+// checkedMappingCore maps an entry checked by a mappingCore, then writes it to the cores
+// selected by the Check of the next core.
+type checkedMappingCore struct {
+	*mappingCore
+	parent  *zapcore.CheckedEntry // the checked entry it was added to
+	checked *zapcore.CheckedEntry // the checked entry of the next core
+}
+
+// Write maps the entry and writes it to the cores of the checked entry of the next
+// core; their errors are reported to the error output of the logger.
+func (core *checkedMappingCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	core.checked.Entry, fields = core.apply(entry, fields)
+	core.checked.ErrorOutput = core.parent.ErrorOutput
+	core.checked.Write(fields...)
+	return nil
+}
+
+// With adds structured context to the core.
+func (core *mappingCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *core
+	clone.context = append(core.context[:len(core.context):len(core.context)], fields...)
+	return &clone
+}
+
+// Enabled asks the next core whether a level is enabled.
+func (core *mappingCore) Enabled(level zapcore.Level) bool {
+	return core.next.Enabled(level)
+}
+
+// Sync flushes the next core.
+func (core *mappingCore) Sync() error {
+	return core.next.Sync()
+}
+
+// redacted replaces the values of the fields selected by RedactFields.
+const redacted = "[REDACTED]"
+
+// RedactFields returns a MappingFunc replacing the values of the fields with the given
+// keys by "[REDACTED]", i.e., passwords or authorization headers, while keeping the
+// entries.
+//
+// The order of the fields is preserved. String fields stay strings; fields of other
+// types, including objects and arrays, are replaced by a string field. Nested fields
+// are not inspected.
+func RedactFields(keys ...string) MappingFunc {
+	sensitive := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		sensitive[key] = true
+	}
+	return func(entry zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+		var mapped []zapcore.Field
+		for i, field := range fields {
+			if !sensitive[field.Key] || field.Type == zapcore.SkipType {
+				continue
+			}
+			if mapped == nil {
+				mapped = append([]zapcore.Field(nil), fields...)
+			}
+			if field.Type == zapcore.ByteStringType {
+				mapped[i] = zap.ByteString(field.Key, []byte(redacted))
+			} else {
+				mapped[i] = zap.String(field.Key, redacted)
+			}
+		}
+		if mapped == nil {
+			return entry, fields
+		}
+		return entry, mapped
+	}
+}
+
+// AddFieldsIf returns a MappingFunc appending fields to the entries matching cond, i.e.,
+// to tag error entries with alert=true for downstream routing.
+//
+// A field is not appended if the entry already has a field with the same key, either
+// from the logging call or from With.
+func AddFieldsIf(cond FilterFunc, fields ...zapcore.Field) MappingFunc {
+	return func(entry zapcore.Entry, entryFields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+		if isCheckPhase(entryFields) {
+			// the fields are unknown yet, and the entry is left untouched anyway
+			return entry, entryFields
+		}
+		if !cond(entry, entryFields) {
+			return entry, entryFields
+		}
+		mapped := entryFields[:len(entryFields):len(entryFields)]
+		for _, field := range fields {
+			if _, found := findField(entryFields, field.Key); !found {
+				mapped = append(mapped, field)
+			}
+		}
+		return entry, mapped
+	}
+}
+
+// OnlyStacktraceAtOrAbove returns a MappingFunc removing the stacktrace of the entries
+// with a level lower than level, i.e., to keep stacktraces for error entries only.
+func OnlyStacktraceAtOrAbove(level zapcore.Level) MappingFunc {
+	return func(entry zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+		if entry.Level < level {
+			entry.Stack = ""
+		}
+		return entry, fields
+	}
+}
+
+// StripANSI returns a MappingFunc removing the ANSI escape sequences, i.e., colors, from
+// the message of the entries.
+//
+// Put the mapping core before a filtering core so the filters match the visible text:
+//
+//   core := zapfilter.NewMappingCore(zapfilter.NewFilteringCore(next, filter), zapfilter.StripANSI())
+func StripANSI() MappingFunc {
+	return func(entry zapcore.Entry, fields []zapcore.Field) (zapcore.Entry, []zapcore.Field) {
+		entry.Message = stripANSI(entry.Message)
+		return entry, fields
+	}
+}
+
+// stripANSI removes the CSI (ESC [ ... final byte), OSC (ESC ] ... BEL or ESC \) and
+// two-byte escape sequences of s.
+func stripANSI(s string) string {
+	if strings.IndexByte(s, '\x1b') < 0 {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\x1b' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case '[':
+			// parameters and intermediate bytes, up to a final byte in 0x40-0x7e
+			for i+1 < len(s) && (s[i+1] < 0x40 || s[i+1] > 0x7e) {
+				i++
+			}
+			i++
+		case ']':
+			for i+1 < len(s) {
+				i++
+				if s[i] == '\a' {
+					break
+				}
+				if s[i] == '\x1b' && i+1 < len(s) && s[i+1] == '\\' {
+					i++
+					break
+				}
+			}
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,149 @@
+package zapfilter_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"moul.io/zapfilter"
+)
+
+func TestRedactFields(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.InfoLevel)
+	logger := zap.New(zapfilter.NewMappingCore(next, zapfilter.RedactFields("password", "authorization", "pin")))
+
+	fields := []zapcore.Field{
+		zap.String("user", "alice"),
+		zap.String("password", "hunter2"),
+		zap.Int("pin", 1234),
+		zap.ByteString("authorization", []byte("Bearer xxx")),
+	}
+	logger.With(zap.String("authorization", "Basic yyy"), zap.Int("attempt", 1)).Info("login", fields...)
+	logger.Debug("disabled", zap.String("password", "hunter2"))
+
+	require.Equal(t, 1, logs.Len())
+	entry := logs.All()[0]
+	require.Equal(t, "login", entry.Message)
+
+	keys := []string{}
+	for _, field := range entry.Context {
+		keys = append(keys, field.Key)
+	}
+	require.Equal(t, []string{"authorization", "attempt", "user", "password", "pin", "authorization"}, keys)
+	require.Equal(t, zapcore.ByteStringType, entry.Context[5].Type)
+	require.Equal(t, map[string]interface{}{
+		"user":          "alice",
+		"password":      "[REDACTED]",
+		"pin":           "[REDACTED]",
+		"authorization": "[REDACTED]",
+		"attempt":       int64(1),
+	}, entry.ContextMap())
+
+	// the fields of the caller are untouched
+	require.Equal(t, "hunter2", fields[1].String)
+	require.Equal(t, int64(1234), fields[2].Integer)
+}
+
+func TestAddFieldsIf(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	mapping := zapfilter.AddFieldsIf(zapfilter.MinimumLevel(zapcore.ErrorLevel), zap.Bool("alert", true), zap.String("team", "core"))
+	logger := zap.New(zapfilter.NewMappingCore(next, mapping))
+
+	logger.Info("a")
+	logger.Error("b")
+	logger.With(zap.String("team", "db")).Error("c")
+	logger.Error("d", zap.Bool("alert", false))
+
+	require.Equal(t, 4, logs.Len())
+	require.Equal(t, map[string]interface{}{}, logs.All()[0].ContextMap())
+	require.Equal(t, map[string]interface{}{"alert": true, "team": "core"}, logs.All()[1].ContextMap())
+	require.Equal(t, map[string]interface{}{"alert": true, "team": "db"}, logs.All()[2].ContextMap())
+	require.Len(t, logs.All()[2].Context, 2) // not duplicated
+	require.Equal(t, map[string]interface{}{"alert": false, "team": "core"}, logs.All()[3].ContextMap())
+}
+
+func TestStripANSI(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	// the filter sees the visible text
+	visible := func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		return !strings.HasPrefix(entry.Message, "\x1b[")
+	}
+	filtering := zapfilter.NewFilteringCore(next, visible)
+	logger := zap.New(zapfilter.NewMappingCore(filtering, zapfilter.StripANSI()))
+
+	messages := map[string]string{
+		"plain":                                "plain",
+		"\x1b[31mred\x1b[0m":                   "red",
+		"\x1b[1;38;5;208mbold orange\x1b[m!":   "bold orange!",
+		"\x1b]8;;http://x\x07link\x1b]8;;\x07": "link",
+		"\x1b]0;title\x1b\\text":               "text",
+		"\x1bcreset":                           "reset",
+		"trailing\x1b":                         "trailing\x1b",
+		"unterminated\x1b[31":                  "unterminated",
+	}
+	for message, expected := range messages {
+		logger.Info(message)
+		require.Equal(t, expected, logs.TakeAll()[0].Message, "%q", message)
+	}
+}
+
+func TestOnlyStacktraceAtOrAbove(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	core := zapfilter.NewMappingCore(next, zapfilter.OnlyStacktraceAtOrAbove(zapcore.ErrorLevel))
+	logger := zap.New(core, zap.AddStacktrace(zapcore.InfoLevel))
+
+	logger.Info("info")
+	logger.Warn("warn", zap.String("foo", "bar"))
+	logger.Error("error")
+	logger.DPanic("dpanic")
+
+	entries := logs.All()
+	require.Len(t, entries, 4)
+	require.Empty(t, entries[0].Stack)
+	require.Empty(t, entries[1].Stack)
+	require.Equal(t, "warn", entries[1].Message)
+	require.Equal(t, map[string]interface{}{"foo": "bar"}, entries[1].ContextMap())
+	require.NotEmpty(t, entries[2].Stack)
+	require.NotEmpty(t, entries[3].Stack)
+}
+
+func TestMappingCore_check(t *testing.T) {
+	t.Parallel()
+
+	// the next core selects the cores of the entries, i.e., with a tee of levels
+	debugCore, debugLogs := observer.New(zapcore.DebugLevel)
+	errorCore, errorLogs := observer.New(zapcore.ErrorLevel)
+	tee := zapcore.NewTee(debugCore, errorCore)
+	logger := zap.New(zapfilter.NewMappingCore(tee, zapfilter.RedactFields("password")))
+
+	logger.Debug("debug", zap.String("password", "hunter2"))
+	logger.With(zap.String("password", "hunter2")).Error("error")
+
+	require.Equal(t, 2, debugLogs.Len())
+	require.Equal(t, 1, errorLogs.Len())
+	require.Equal(t, "error", errorLogs.All()[0].Message)
+	for _, entry := range append(debugLogs.All(), errorLogs.All()...) {
+		require.Equal(t, map[string]interface{}{"password": "[REDACTED]"}, entry.ContextMap())
+	}
+
+	// or with a sampler
+	next, logs := observer.New(zapcore.DebugLevel)
+	sampler := zapcore.NewSamplerWithOptions(next, time.Hour, 1, 0)
+	logger = zap.New(zapfilter.NewMappingCore(sampler, zapfilter.RedactFields("password")))
+	for i := 0; i < 3; i++ {
+		logger.Info("sampled")
+	}
+	require.Equal(t, 1, logs.Len())
+}
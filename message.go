@@ -0,0 +1,42 @@
+package zapfilter
+
+import (
+	"strconv"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// ByMessageLength filters entries whose message length, in bytes, is within min and
+// max (inclusive), i.e., ByMessageLength(-1, 8192) drops dump-style messages.
+//
+// A negative min or max means unbounded. Empty messages have a length of zero, so they
+// only pass when min is zero or unbounded. If min is greater than max, every entry is
+// dropped.
+func ByMessageLength(min, max int) FilterFunc {
+	info := filterInfo{desc: describeCall("ByMessageLength", strconv.Itoa(min), strconv.Itoa(max)), ignoresFields: true}
+	switch {
+	case min >= 0 && max >= 0 && min > max:
+		return describe(info, alwaysFalseFilter)
+	case min <= 0 && max < 0:
+		return describe(info, alwaysTrueFilter)
+	}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		length := len(entry.Message)
+		if min >= 0 && length < min {
+			return false
+		}
+		return max < 0 || length <= max
+	})
+}
+
+// ByMessage filters entries whose message contains substr, i.e., ByMessage("panic").
+func ByMessage(substr string) FilterFunc {
+	info := filterInfo{desc: describeCall("ByMessage", strconv.Quote(substr)), ignoresFields: true}
+	if substr == "" {
+		return describe(info, alwaysTrueFilter)
+	}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		return strings.Contains(entry.Message, substr)
+	})
+}
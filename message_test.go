@@ -0,0 +1,51 @@
+package zapfilter_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"moul.io/zapfilter"
+)
+
+func TestByMessageLength(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		min, max int
+		expected map[int]bool // message length -> passes
+	}{
+		{"bounded", 2, 4, map[int]bool{0: false, 1: false, 2: true, 4: true, 5: false}},
+		{"max-only", -1, 8192, map[int]bool{0: true, 8192: true, 8193: false}},
+		{"min-only", 1, -1, map[int]bool{0: false, 1: true, 10000: true}},
+		{"unbounded", -1, -1, map[int]bool{0: true, 10000: true}},
+		{"zero-min", 0, -1, map[int]bool{0: true, 1: true}},
+		{"empty-only", 0, 0, map[int]bool{0: true, 1: false}},
+		{"min-greater-than-max", 5, 2, map[int]bool{0: false, 3: false, 5: false}},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			filter := zapfilter.ByMessageLength(tc.min, tc.max)
+			for length, expected := range tc.expected {
+				entry := zapcore.Entry{Message: strings.Repeat("a", length)}
+				require.Equal(t, expected, filter(entry, nil), "length=%d", length)
+			}
+		})
+	}
+}
+
+func TestByMessage(t *testing.T) {
+	t.Parallel()
+
+	filter := zapfilter.ByMessage("panic")
+	require.True(t, filter(zapcore.Entry{Message: "recovered from panic"}, nil))
+	require.True(t, filter(zapcore.Entry{Message: "panic"}, nil))
+	require.False(t, filter(zapcore.Entry{Message: "Panic"}, nil))
+	require.False(t, filter(zapcore.Entry{}, nil))
+	require.True(t, zapfilter.IsAlwaysTrue(zapfilter.ByMessage("")))
+	require.Equal(t, `ByMessage("panic")`, zapfilter.Describe(filter))
+}
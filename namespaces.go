@@ -0,0 +1,430 @@
+package zapfilter
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// rootPattern is the reserved pattern matching the root (unnamed) logger.
+const rootPattern = "<root>"
+
+// rootSpecificity makes rootPattern win over every other pattern.
+const rootSpecificity = int(^uint(0) >> 1)
+
+// namespacePattern is a parsed ByNamespaces pattern.
+type namespacePattern struct {
+	pattern     string // without the '-' prefix
+	exclude     bool
+	root        bool
+	prefix      string // set when pattern is a literal prefix followed by a single '*'
+	specificity int
+}
+
+// namespaceMatcher decides whether a namespace is accepted by a list of patterns, see
+// ByNamespaces for the precedence rules.
+type namespaceMatcher struct {
+	patterns           []namespacePattern
+	hasInclude         bool
+	hasIncludeWildcard bool
+	hasExclude         bool
+}
+
+func newNamespaceMatcher(patterns []string) namespaceMatcher {
+	var matcher namespaceMatcher
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "-") {
+			matcher.add(pattern[1:], true)
+		} else {
+			matcher.add(pattern, false)
+		}
+	}
+	return matcher
+}
+
+// add appends a pattern, without its '-' prefix; empty patterns are ignored.
+func (m *namespaceMatcher) add(pattern string, exclude bool) {
+	if pattern == "" {
+		return
+	}
+	parsed := namespacePattern{pattern: pattern, exclude: exclude}
+	if exclude {
+		m.hasExclude = true
+	} else {
+		m.hasInclude = true
+		if pattern == "*" {
+			m.hasIncludeWildcard = true
+		}
+	}
+	parsed.specificity = patternSpecificity(parsed.pattern)
+	if literal := strings.TrimSuffix(parsed.pattern, "*"); literal != "" && literal != parsed.pattern && !strings.ContainsAny(literal, `*?[\`) {
+		parsed.prefix = literal
+	}
+	if parsed.pattern == rootPattern {
+		parsed.root = true
+		parsed.specificity = rootSpecificity
+	}
+	m.patterns = append(m.patterns, parsed)
+}
+
+// match returns true if the most specific pattern matching the namespace is an include.
+func (m namespaceMatcher) match(namespace string) bool {
+	accepted := !m.hasInclude
+	best := -1
+	for _, pattern := range m.patterns {
+		if pattern.specificity < best || (pattern.specificity == best && !pattern.exclude) {
+			continue // cannot change the decision
+		}
+		if pattern.root {
+			if namespace == "" {
+				best = pattern.specificity
+				accepted = !pattern.exclude
+			}
+			continue
+		}
+		if pattern.prefix != "" {
+			// same as path.Match, where '*' doesn't match '/', without parsing the pattern
+			if strings.HasPrefix(namespace, pattern.prefix) && strings.IndexByte(namespace[len(pattern.prefix):], '/') < 0 {
+				best = pattern.specificity
+				accepted = !pattern.exclude
+			}
+			continue
+		}
+		if matched, _ := path.Match(pattern.pattern, namespace); matched {
+			best = pattern.specificity
+			accepted = !pattern.exclude
+		}
+	}
+	return accepted
+}
+
+// cachedFilter returns a filter memoizing the decision for up to 10000 namespaces.
+func (m namespaceMatcher) cachedFilter() FilterFunc {
+	decisions := newNamespaceCache(defaultMaxKeys)
+	return func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		if decision, found := decisions.get(entry.LoggerName); found {
+			return decision
+		}
+		decision := m.match(entry.LoggerName)
+		decisions.set(entry.LoggerName, decision)
+		return decision
+	}
+}
+
+// alwaysMatch returns true if every namespace is accepted.
+func (m namespaceMatcher) alwaysMatch() bool {
+	return m.hasIncludeWildcard && !m.hasExclude
+}
+
+// patternSpecificity counts the literal characters of a path.Match pattern.
+func patternSpecificity(pattern string) int {
+	specificity := 0
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '*', '?':
+		case '[':
+			// skip the character class
+			for i < len(pattern) && pattern[i] != ']' {
+				i++
+			}
+		case '\\':
+			i++
+			specificity++
+		default:
+			specificity++
+		}
+	}
+	return specificity
+}
+
+// ByRootLogger filters entries of the root (unnamed) logger, like the "<root>" pattern.
+func ByRootLogger() FilterFunc {
+	info := filterInfo{desc: describeCall("ByRootLogger"), levels: &allLevelSet, ignoresFields: true}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		return entry.LoggerName == ""
+	})
+}
+
+// ByLeafNamespace filters entries whose last namespace segment, after the last '.',
+// matches the path.Match pattern, i.e., ByLeafNamespace("frontend") matches
+// "frontend", "api.frontend" and "web.v2.frontend".
+//
+// The root logger has an empty leaf. Malformed patterns match no namespace.
+func ByLeafNamespace(pattern string) FilterFunc {
+	info := filterInfo{desc: describeCall("ByLeafNamespace", fmt.Sprintf("%q", pattern)), levels: &allLevelSet, ignoresFields: true}
+	if pattern == "*" {
+		return describe(info, alwaysTrueFilter)
+	}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		leaf := entry.LoggerName[strings.LastIndexByte(entry.LoggerName, '.')+1:]
+		matched, _ := path.Match(pattern, leaf)
+		return matched
+	})
+}
+
+// Allow filters entries whose namespace is accepted by the patterns, like ByNamespaces,
+// but takes the patterns as separate arguments, i.e., Allow("api.*", "db"). Empty
+// patterns are ignored; without patterns, no entry passes.
+func Allow(patterns ...string) FilterFunc {
+	info := filterInfo{desc: describeCall("Allow", describeKeys(patterns)...), levels: &allLevelSet, ignoresFields: true}
+	matcher := newNamespaceMatcher(patterns)
+	switch {
+	case len(matcher.patterns) == 0:
+		info.levels = &LevelSet{}
+		return describe(info, alwaysFalseFilter)
+	case matcher.alwaysMatch():
+		return describe(info, alwaysTrueFilter)
+	}
+	return describe(info, matcher.cachedFilter())
+}
+
+// Deny is the reverse of Allow: it filters entries whose namespace is not accepted by
+// the patterns, i.e., Deny("grpc.*", "vendor.*") keeps everything else. Without
+// patterns, every entry passes.
+func Deny(patterns ...string) FilterFunc {
+	info := filterInfo{desc: describeCall("Deny", describeKeys(patterns)...), levels: &allLevelSet, ignoresFields: true}
+	matcher := newNamespaceMatcher(patterns)
+	switch {
+	case len(matcher.patterns) == 0:
+		return describe(info, alwaysTrueFilter)
+	case matcher.alwaysMatch():
+		info.levels = &LevelSet{}
+		return describe(info, alwaysFalseFilter)
+	}
+	allowed := matcher.cachedFilter()
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		return !allowed(entry, fields)
+	})
+}
+
+// ByNamespacesRule is like ByNamespaces, but takes the include and exclude patterns as
+// separate lists, without '-' prefixes, i.e., ByNamespacesRule([]string{"api.*"},
+// []string{"api.health"}) is ByNamespaces("api.*,-api.health").
+//
+// Patterns are never interpreted: an include starting with '-' matches namespaces
+// starting with '-'. Empty patterns are ignored. Like with ByNamespaces, excludes alone
+// accept everything else.
+func ByNamespacesRule(includes, excludes []string) FilterFunc {
+	info := filterInfo{desc: describeCall("ByNamespacesRule", fmt.Sprintf("%q", includes), fmt.Sprintf("%q", excludes)), levels: &allLevelSet, ignoresFields: true}
+	var matcher namespaceMatcher
+	for _, pattern := range includes {
+		matcher.add(pattern, false)
+	}
+	for _, pattern := range excludes {
+		matcher.add(pattern, true)
+	}
+	switch {
+	case len(matcher.patterns) == 0:
+		info.levels = &LevelSet{}
+		return describe(info, alwaysFalseFilter)
+	case matcher.alwaysMatch():
+		return describe(info, alwaysTrueFilter)
+	}
+	return describe(info, matcher.cachedFilter())
+}
+
+// ByNamespacesNoCache is like ByNamespaces, but matches the patterns for each entry
+// instead of memoizing the decisions, without any map or mutex.
+//
+// It is meant for the services creating unbounded dynamic namespaces, i.e., one per
+// request, for which the cache of ByNamespaces is pure overhead and keeps growing.
+func ByNamespacesNoCache(input string) FilterFunc {
+	info := filterInfo{desc: describeCall("ByNamespacesNoCache", fmt.Sprintf("%q", input)), levels: &allLevelSet, ignoresFields: true}
+	matcher := newNamespaceMatcher(strings.Split(input, ","))
+	switch {
+	case len(matcher.patterns) == 0:
+		info.levels = &LevelSet{}
+		return describe(info, alwaysFalseFilter)
+	case matcher.alwaysMatch():
+		return describe(info, alwaysTrueFilter)
+	}
+
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		return matcher.match(entry.LoggerName)
+	})
+}
+
+// ByNamespacesShared is like ByNamespaces, but stores its decisions in a cache shared
+// by every ByNamespacesShared filter of the process, keyed by patterns and namespace.
+//
+// It avoids recomputing the same matches when many filtering cores are built with the
+// same patterns, i.e., one per subsystem. The shared cache is bounded and is cleared
+// with ClearCache.
+func ByNamespacesShared(input string) FilterFunc {
+	info := filterInfo{desc: describeCall("ByNamespacesShared", fmt.Sprintf("%q", input)), levels: &allLevelSet, ignoresFields: true}
+	matcher := newNamespaceMatcher(strings.Split(input, ","))
+	switch {
+	case len(matcher.patterns) == 0:
+		info.levels = &LevelSet{}
+		return describe(info, alwaysFalseFilter)
+	case matcher.alwaysMatch():
+		return describe(info, alwaysTrueFilter)
+	}
+
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		var decision bool
+		key := sharedCacheKey{patterns: input, namespace: entry.LoggerName}
+		sharedCache.update(key, func(value interface{}, found bool) interface{} {
+			if found {
+				decision = value.(bool)
+			} else {
+				decision = matcher.match(entry.LoggerName)
+			}
+			return decision
+		})
+		return decision
+	})
+}
+
+// MemoizeByNamespaceLevel caches the decisions of filter per namespace and level, i.e.,
+// for an expensive combination of namespace and level filters, so it is called at most
+// once per namespace and level.
+//
+// filter must only depend on the namespace and level of the entries, not on their
+// message, fields or time. Up to 10000 decisions are kept; the least recently used are
+// forgotten first.
+func MemoizeByNamespaceLevel(filter FilterFunc) FilterFunc {
+	if _, isConstant := constantOf(filter); isConstant || filter == nil {
+		return filter
+	}
+	info := filterInfo{desc: describeCall("MemoizeByNamespaceLevel", Describe(filter)), levels: levelsOf(filter), ignoresFields: true}
+
+	type memoKey struct {
+		namespace string
+		level     zapcore.Level
+	}
+	decisions := newBoundedCache(defaultMaxKeys)
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		var decision bool
+		key := memoKey{namespace: entry.LoggerName, level: entry.Level}
+		decisions.update(key, func(value interface{}, found bool) interface{} {
+			if found {
+				decision = value.(bool)
+			} else {
+				decision = filter(entry, fields)
+			}
+			return decision
+		})
+		return decision
+	})
+}
+
+// ClearCache empties the cache shared by ByNamespacesShared filters.
+func ClearCache() {
+	sharedCache.reset()
+}
+
+// sharedCacheSize is the number of decisions kept by the shared cache.
+const sharedCacheSize = 100000
+
+var sharedCache = newBoundedCache(sharedCacheSize)
+
+type sharedCacheKey struct {
+	patterns  string
+	namespace string
+}
+
+// NamespaceSet is a compiled list of ByNamespaces patterns, validated once, that can be
+// matched directly or shared by several filters.
+type NamespaceSet struct {
+	patterns []string
+	matcher  namespaceMatcher
+}
+
+// NewNamespaceSet compiles patterns, with the syntax and precedence rules of
+// ByNamespaces, i.e., NewNamespaceSet("api.*", "-api.health"). Each pattern is checked
+// with ValidateNamespacePattern, so empty and malformed patterns are reported.
+func NewNamespaceSet(patterns ...string) (*NamespaceSet, error) {
+	for _, pattern := range patterns {
+		if err := ValidateNamespacePattern(pattern); err != nil {
+			return nil, err
+		}
+	}
+	return &NamespaceSet{
+		patterns: append([]string(nil), patterns...),
+		matcher:  newNamespaceMatcher(patterns),
+	}, nil
+}
+
+// Matches returns true if the namespace is accepted by the patterns; an empty set
+// matches nothing.
+func (s *NamespaceSet) Matches(namespace string) bool {
+	return len(s.matcher.patterns) > 0 && s.matcher.match(namespace)
+}
+
+// Filter returns a filter matching the entries whose namespace is accepted by the
+// patterns, like ByNamespaces. Each filter memoizes its own decisions.
+func (s *NamespaceSet) Filter() FilterFunc {
+	info := filterInfo{desc: describeCall("NamespaceSet", describeKeys(s.patterns)...), levels: &allLevelSet, ignoresFields: true}
+	switch {
+	case len(s.matcher.patterns) == 0:
+		info.levels = &LevelSet{}
+		return describe(info, alwaysFalseFilter)
+	case s.matcher.alwaysMatch():
+		return describe(info, alwaysTrueFilter)
+	}
+	return describe(info, s.matcher.cachedFilter())
+}
+
+// ValidateNamespacePattern checks that a single ByNamespaces pattern, optionally
+// prefixed with '-' to exclude, is a valid path.Match pattern.
+func ValidateNamespacePattern(pattern string) error {
+	glob := strings.TrimPrefix(pattern, "-")
+	if glob == "" {
+		return fmt.Errorf("invalid namespace pattern %q: empty pattern", pattern)
+	}
+	if err := validateGlob(glob); err != nil {
+		return fmt.Errorf("invalid namespace pattern %q: %w", pattern, err)
+	}
+	return nil
+}
+
+// validateGlob checks the whole pattern, whereas path.Match may stop at the first
+// mismatch without reporting the malformed parts.
+func validateGlob(pattern string) error {
+	for i := 0; i < len(pattern); i++ {
+		switch pattern[i] {
+		case '\\':
+			i++
+			if i >= len(pattern) {
+				return path.ErrBadPattern
+			}
+		case '[':
+			i++
+			if i < len(pattern) && pattern[i] == '^' {
+				i++
+			}
+			for ranges := 0; i >= len(pattern) || pattern[i] != ']' || ranges == 0; ranges++ {
+				next, err := validateClassChar(pattern, i)
+				if err != nil {
+					return err
+				}
+				i = next
+				if i < len(pattern) && pattern[i] == '-' {
+					if i, err = validateClassChar(pattern, i+1); err != nil {
+						return err
+					}
+				}
+			}
+		}
+	}
+	_, err := path.Match(pattern, "")
+	return err
+}
+
+// validateClassChar checks the character class character at index i and returns the
+// index of the next one.
+func validateClassChar(pattern string, i int) (int, error) {
+	if i >= len(pattern) || pattern[i] == '-' || pattern[i] == ']' {
+		return 0, path.ErrBadPattern
+	}
+	if pattern[i] == '\\' {
+		i++
+		if i >= len(pattern) {
+			return 0, path.ErrBadPattern
+		}
+	}
+	return i + 1, nil
+}
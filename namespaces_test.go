@@ -0,0 +1,348 @@
+package zapfilter_test
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"moul.io/zapfilter"
+	"moul.io/zapfilter/zapfiltertest"
+)
+
+func TestByNamespacesShared(t *testing.T) {
+	defer zapfilter.ClearCache()
+
+	entries := []zapcore.Entry{
+		zapfiltertest.Entry(zapcore.InfoLevel, "", ""),
+		zapfiltertest.Entry(zapcore.InfoLevel, "foo", ""),
+		zapfiltertest.Entry(zapcore.InfoLevel, "foo.bar", ""),
+		zapfiltertest.Entry(zapcore.InfoLevel, "foo.baz", ""),
+		zapfiltertest.Entry(zapcore.InfoLevel, "bar", ""),
+	}
+	for _, patterns := range []string{"", "*", "foo*", "foo.*,-foo.bar", "-foo.*,foo.bar", "-foo"} {
+		expected := zapfiltertest.Record(zapfilter.ByNamespaces(patterns), entries)
+		for i := 0; i < 3; i++ { // shared between filters built with the same patterns
+			require.Equal(t, expected, zapfiltertest.Record(zapfilter.ByNamespacesShared(patterns), entries), patterns)
+		}
+		zapfilter.ClearCache()
+		require.Equal(t, expected, zapfiltertest.Record(zapfilter.ByNamespacesShared(patterns), entries), patterns)
+	}
+}
+
+func BenchmarkManyCores(b *testing.B) {
+	const cores = 100
+	names := make([]string, 50)
+	for i := range names {
+		names[i] = fmt.Sprintf("service.subsystem%d.component", i)
+	}
+	entry := zapcore.Entry{Level: zapcore.InfoLevel}
+
+	for _, constructor := range []struct {
+		name string
+		new  func(string) zapfilter.FilterFunc
+	}{
+		{"ByNamespaces", zapfilter.ByNamespaces},
+		{"ByNamespacesShared", zapfilter.ByNamespacesShared},
+	} {
+		constructor := constructor
+		b.Run(constructor.name, func(b *testing.B) {
+			defer zapfilter.ClearCache()
+			for i := 0; i < b.N; i++ {
+				// build many filters with the same patterns, and use each once per name
+				for j := 0; j < cores; j++ {
+					filter := constructor.new("service.*,-service.subsystem1?.*,*.component")
+					for _, name := range names {
+						entry.LoggerName = name
+						filter(entry, nil)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestValidateNamespacePattern(t *testing.T) {
+	t.Parallel()
+
+	valid := []string{
+		"foo", "foo.*", "*", "-foo", "-foo.*", "f?o", "[a-z]oo", "[^a-z]oo", "-[abc]",
+		`foo\*`, `[\]]`, `[a\-z]`, "ns[0-9][0-9]",
+	}
+	for _, pattern := range valid {
+		require.NoError(t, zapfilter.ValidateNamespacePattern(pattern), pattern)
+	}
+
+	invalid := []string{"", "-", "[", "foo[", "-foo[", "[]", "[a-]", "[-a]", "[a", "[^]", `foo\`, `[\`, "[a-z", "foo.[]a]"}
+	for _, pattern := range invalid {
+		err := zapfilter.ValidateNamespacePattern(pattern)
+		require.Error(t, err, pattern)
+		require.Contains(t, err.Error(), fmt.Sprintf("%q", pattern))
+	}
+	require.True(t, errors.Is(zapfilter.ValidateNamespacePattern("-foo["), path.ErrBadPattern))
+}
+
+func TestByRootLogger(t *testing.T) {
+	t.Parallel()
+
+	root := zapfiltertest.Entry(zapcore.InfoLevel, "", "hello")
+	named := zapfiltertest.Entry(zapcore.InfoLevel, "foo", "hello")
+	debugRoot := zapfiltertest.Entry(zapcore.DebugLevel, "", "hello")
+	literal := zapfiltertest.Entry(zapcore.InfoLevel, "<root>", "hello")
+
+	zapfiltertest.AssertPasses(t, zapfilter.ByRootLogger(), root, debugRoot)
+	zapfiltertest.AssertDrops(t, zapfilter.ByRootLogger(), named, literal)
+
+	zapfiltertest.AssertPasses(t, zapfilter.MustParseRules("info:<root>"), root)
+	zapfiltertest.AssertDrops(t, zapfilter.MustParseRules("info:<root>"), named, debugRoot, literal)
+
+	// <root> wins over wildcards, whatever the order
+	zapfiltertest.AssertPasses(t, zapfilter.ByNamespaces("*,-<root>"), named)
+	zapfiltertest.AssertDrops(t, zapfilter.ByNamespaces("*,-<root>"), root)
+	zapfiltertest.AssertPasses(t, zapfilter.ByNamespaces("-*,<root>"), root)
+	zapfiltertest.AssertDrops(t, zapfilter.ByNamespaces("-*,<root>"), named)
+
+	require.Equal(t, "ByRootLogger()", zapfilter.Describe(zapfilter.ByRootLogger()))
+}
+
+func TestMemoizeByNamespaceLevel(t *testing.T) {
+	t.Parallel()
+
+	var mutex sync.Mutex
+	calls := map[string]int{}
+	expensive := func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		mutex.Lock()
+		calls[entry.LoggerName+":"+entry.Level.String()]++
+		mutex.Unlock()
+		return entry.Level >= zapcore.WarnLevel || entry.LoggerName == "api"
+	}
+	filter := zapfilter.MemoizeByNamespaceLevel(expensive)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				for _, namespace := range []string{"api", "db"} {
+					for _, level := range []zapcore.Level{zapcore.InfoLevel, zapcore.WarnLevel} {
+						filter(zapfiltertest.Entry(level, namespace, fmt.Sprint(j)), nil)
+					}
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, map[string]int{"api:info": 1, "api:warn": 1, "db:info": 1, "db:warn": 1}, calls)
+	zapfiltertest.AssertPasses(t, filter,
+		zapfiltertest.Entry(zapcore.InfoLevel, "api", ""),
+		zapfiltertest.Entry(zapcore.WarnLevel, "db", ""),
+	)
+	zapfiltertest.AssertDrops(t, filter, zapfiltertest.Entry(zapcore.InfoLevel, "db", ""))
+
+	require.Equal(t, "MemoizeByNamespaceLevel(custom)", zapfilter.Describe(filter))
+	require.True(t, zapfilter.IsAlwaysTrue(zapfilter.MemoizeByNamespaceLevel(zapfilter.AllowAll())))
+	level, ok := zapfilter.EffectiveMinLevel(zapfilter.MemoizeByNamespaceLevel(zapfilter.MustParseRules("warn+:*")))
+	require.True(t, ok)
+	require.Equal(t, zapcore.WarnLevel, level)
+}
+
+func TestNamespaceSet(t *testing.T) {
+	t.Parallel()
+
+	set, err := zapfilter.NewNamespaceSet("foo*", "-foo.bar", "bar.*", "-bar.*", "<root>")
+	require.NoError(t, err)
+	cases := map[string]bool{
+		"":        true,  // <root>
+		"foo":     true,  // foo*
+		"foo.baz": true,  // foo*
+		"foo.bar": false, // -foo.bar is more specific than foo*
+		"bar.foo": false, // same specificity, exclude wins
+		"baz":     false, // no include matches
+	}
+	filter := set.Filter()
+	for namespace, expected := range cases {
+		require.Equal(t, expected, set.Matches(namespace), namespace)
+		require.Equal(t, expected, filter(zapcore.Entry{LoggerName: namespace}, nil), namespace)
+	}
+	require.Equal(t, `NamespaceSet("foo*", "-foo.bar", "bar.*", "-bar.*", "<root>")`, zapfilter.Describe(filter))
+
+	excludes, err := zapfilter.NewNamespaceSet("-foo", "-bar*")
+	require.NoError(t, err)
+	require.True(t, excludes.Matches("baz"))
+	require.False(t, excludes.Matches("bar.baz"))
+
+	empty, err := zapfilter.NewNamespaceSet()
+	require.NoError(t, err)
+	require.False(t, empty.Matches("foo"))
+	require.True(t, zapfilter.IsAlwaysFalse(empty.Filter()))
+	all, err := zapfilter.NewNamespaceSet("*")
+	require.NoError(t, err)
+	require.True(t, zapfilter.IsAlwaysTrue(all.Filter()))
+
+	for _, patterns := range [][]string{{"foo", ""}, {"-"}, {"foo["}, {"ok", "-[a-"}} {
+		_, err := zapfilter.NewNamespaceSet(patterns...)
+		require.Error(t, err, patterns)
+	}
+	_, err = zapfilter.NewNamespaceSet("foo", "-foo[")
+	require.EqualError(t, err, `invalid namespace pattern "-foo[": syntax error in pattern`)
+}
+
+func TestByLeafNamespace(t *testing.T) {
+	t.Parallel()
+
+	entry := func(namespace string) zapcore.Entry {
+		return zapfiltertest.Entry(zapcore.InfoLevel, namespace, "hello")
+	}
+
+	frontend := zapfilter.ByLeafNamespace("frontend")
+	zapfiltertest.AssertPasses(t, frontend, entry("frontend"), entry("api.frontend"), entry("web.v2.frontend"))
+	zapfiltertest.AssertDrops(t, frontend, entry(""), entry("frontend.api"), entry("api.frontends"), entry("api.frontend/x"))
+
+	wildcard := zapfilter.ByLeafNamespace("front*")
+	zapfiltertest.AssertPasses(t, wildcard, entry("front"), entry("web.frontend"), entry("web.v2.frontoffice"))
+	zapfiltertest.AssertDrops(t, wildcard, entry("frontend.api"), entry("web"))
+
+	zapfiltertest.AssertDrops(t, zapfilter.ByLeafNamespace("[front"), entry("[front"))
+	require.True(t, zapfilter.IsAlwaysTrue(zapfilter.ByLeafNamespace("*")))
+	require.Equal(t, `ByLeafNamespace("frontend")`, zapfilter.Describe(frontend))
+}
+
+func TestByNamespacesRule(t *testing.T) {
+	t.Parallel()
+
+	names := []string{"", "foo", "foo.bar", "foo.baz", "bar", "bar.foo", "<root>", "-foo"}
+	cases := []struct {
+		input              string
+		includes, excludes []string
+	}{
+		{"", nil, nil},
+		{"*", []string{"*"}, nil},
+		{"foo*", []string{"foo*"}, nil},
+		{"foo.*,-foo.bar", []string{"foo.*"}, []string{"foo.bar"}},
+		{"-foo", nil, []string{"foo"}},
+		{"-foo,-bar*", nil, []string{"foo", "bar*"}},
+		{"*,-<root>", []string{"*"}, []string{"<root>"}},
+		{"-*,bar.*", []string{"bar.*"}, []string{"*"}},
+		{"foo,,bar", []string{"foo", "", "bar"}, []string{""}},
+	}
+	for _, tc := range cases {
+		byString, byRule := zapfilter.ByNamespaces(tc.input), zapfilter.ByNamespacesRule(tc.includes, tc.excludes)
+		for _, name := range names {
+			entry := zapcore.Entry{LoggerName: name}
+			require.Equal(t, byString(entry, nil), byRule(entry, nil), "input=%q name=%q", tc.input, name)
+		}
+	}
+
+	// patterns are never interpreted
+	literal := zapfilter.ByNamespacesRule([]string{"-foo"}, nil)
+	zapfiltertest.AssertPasses(t, literal, zapfiltertest.Entry(zapcore.InfoLevel, "-foo", ""))
+	zapfiltertest.AssertDrops(t, literal, zapfiltertest.Entry(zapcore.InfoLevel, "bar", ""))
+
+	require.True(t, zapfilter.IsAlwaysFalse(zapfilter.ByNamespacesRule(nil, nil)))
+	require.True(t, zapfilter.IsAlwaysTrue(zapfilter.ByNamespacesRule([]string{"*"}, nil)))
+	require.Equal(t,
+		`ByNamespacesRule(["foo.*"], ["foo.bar"])`,
+		zapfilter.Describe(zapfilter.ByNamespacesRule([]string{"foo.*"}, []string{"foo.bar"})),
+	)
+}
+
+func TestByNamespacesNoCache(t *testing.T) {
+	t.Parallel()
+
+	names := []string{"", "foo", "foo.bar", "foo.baz", "bar", "bar.foo", "<root>"}
+	for _, input := range []string{"", "*", "foo*", "foo.*,-foo.bar", "-foo", "*,-<root>", "-*,bar.*"} {
+		cached, noCache := zapfilter.ByNamespaces(input), zapfilter.ByNamespacesNoCache(input)
+		for _, name := range names {
+			entry := zapcore.Entry{LoggerName: name}
+			require.Equal(t, cached(entry, nil), noCache(entry, nil), "input=%q name=%q", input, name)
+		}
+	}
+
+	require.True(t, zapfilter.IsAlwaysFalse(zapfilter.ByNamespacesNoCache("")))
+	require.True(t, zapfilter.IsAlwaysTrue(zapfilter.ByNamespacesNoCache("*")))
+	require.Equal(t, `ByNamespacesNoCache("foo.*")`, zapfilter.Describe(zapfilter.ByNamespacesNoCache("foo.*")))
+}
+
+func TestAllowDeny(t *testing.T) {
+	t.Parallel()
+
+	entry := func(namespace string) zapcore.Entry {
+		return zapfiltertest.Entry(zapcore.InfoLevel, namespace, "hello")
+	}
+	root, foo, fooBar, fooBaz, bar, qux := entry(""), entry("foo"), entry("foo.bar"), entry("foo.baz"), entry("bar"), entry("qux")
+
+	allow := zapfilter.Allow("foo.*", "", "bar")
+	zapfiltertest.AssertPasses(t, allow, fooBar, fooBaz, bar)
+	zapfiltertest.AssertDrops(t, allow, root, foo, qux)
+
+	deny := zapfilter.Deny("foo.*", "", "bar")
+	zapfiltertest.AssertPasses(t, deny, root, foo, qux)
+	zapfiltertest.AssertDrops(t, deny, fooBar, fooBaz, bar)
+
+	// overlapping patterns are denied once, whatever their order
+	for _, deny := range []zapfilter.FilterFunc{
+		zapfilter.Deny("foo*", "foo.bar", "foo.*"),
+		zapfilter.Deny("foo.bar", "foo.*", "foo*"),
+	} {
+		zapfiltertest.AssertPasses(t, deny, root, bar, qux)
+		zapfiltertest.AssertDrops(t, deny, foo, fooBar, fooBaz)
+	}
+
+	// excludes keep the ByNamespaces semantics, Deny reverses them
+	zapfiltertest.AssertPasses(t, zapfilter.Allow("foo*", "-foo.bar"), foo, fooBaz)
+	zapfiltertest.AssertDrops(t, zapfilter.Allow("foo*", "-foo.bar"), fooBar, bar)
+	zapfiltertest.AssertPasses(t, zapfilter.Deny("foo*", "-foo.bar"), fooBar, bar)
+	zapfiltertest.AssertDrops(t, zapfilter.Deny("foo*", "-foo.bar"), foo, fooBaz)
+
+	require.True(t, zapfilter.IsAlwaysFalse(zapfilter.Allow()))
+	require.True(t, zapfilter.IsAlwaysFalse(zapfilter.Allow("", "-")))
+	require.True(t, zapfilter.IsAlwaysTrue(zapfilter.Allow("*")))
+	require.True(t, zapfilter.IsAlwaysTrue(zapfilter.Deny()))
+	require.True(t, zapfilter.IsAlwaysFalse(zapfilter.Deny("*")))
+	require.Equal(t, `Deny("foo.*", "bar")`, zapfilter.Describe(zapfilter.Deny("foo.*", "bar")))
+	require.Equal(t, `Allow()`, zapfilter.Describe(zapfilter.Allow()))
+}
+
+func TestByNamespaces_prefixEquivalence(t *testing.T) {
+	t.Parallel()
+
+	patterns := []string{
+		"api*", "api.*", "a*", "api.v1.*", // fast path
+		"api.*.users*", "a?i*", "ap[a-z]*", `api\.*`, "api.**", "api", // path.Match
+	}
+	names := []string{"", "a", "api", "api.", "api.v1", "api.v1.users", "api/v1", "api.v1/users", "apx.v1", "ap", "api.*"}
+	for _, pattern := range patterns {
+		filter := zapfilter.ByNamespaces(pattern)
+		for _, name := range names {
+			expected, err := path.Match(pattern, name)
+			require.NoError(t, err)
+			actual := filter(zapcore.Entry{LoggerName: name}, nil)
+			require.Equal(t, expected, actual, "pattern=%q name=%q", pattern, name)
+		}
+	}
+}
+
+func BenchmarkByNamespaces_prefix(b *testing.B) {
+	names := make([]string, 1000)
+	for i := range names {
+		names[i] = fmt.Sprintf("api.request%d", i)
+	}
+	for _, pattern := range []string{"api.*", "api.req*st*"} {
+		pattern := pattern
+		b.Run(pattern, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				// a new filter, so the decisions are not cached yet
+				filter := zapfilter.ByNamespaces(pattern + ",-api.request1")
+				for _, name := range names {
+					filter(zapcore.Entry{LoggerName: name}, nil)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,385 @@
+package zapfilter
+
+import (
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// MinOccurrences drops an entry until the same namespace, level and message has been
+// written at least n times within the window, then passes it for as long as it keeps
+// occurring at least n times per window.
+//
+// Up to 10000 distinct entries are tracked; the least recently seen are forgotten first.
+//
+// Use NewMinOccurrencesLimiter to access the number of dropped entries.
+func MinOccurrences(n int, window time.Duration) FilterFunc {
+	return minOccurrences(n, window, time.Now, defaultMaxKeys)
+}
+
+// MinOccurrencesWithClock is like MinOccurrences, but gets the current time from now.
+func MinOccurrencesWithClock(n int, window time.Duration, now func() time.Time) FilterFunc {
+	return minOccurrences(n, window, now, defaultMaxKeys)
+}
+
+// MinOccurrencesWithMaxKeys is like MinOccurrences, but tracks up to maxKeys distinct
+// entries, 10000 if maxKeys <= 0.
+func MinOccurrencesWithMaxKeys(n int, window time.Duration, maxKeys int) FilterFunc {
+	return minOccurrences(n, window, time.Now, maxKeys)
+}
+
+func minOccurrences(n int, window time.Duration, now func() time.Time, maxKeys int) FilterFunc {
+	info := filterInfo{desc: describeCall("MinOccurrences", strconv.Itoa(n), window.String()), ignoresFields: true}
+	if n <= 1 {
+		return describe(info, alwaysTrueFilter)
+	}
+	return describe(info, newMinOccurrencesLimiter(n, window, now, maxKeys).Filter)
+}
+
+// MinOccurrencesLimiter tracks the recent occurrences of each entry, see
+// MinOccurrences.
+type MinOccurrencesLimiter struct {
+	passed  uint64 // first fields to guarantee 64-bit alignment for atomic operations
+	dropped uint64
+	n       int
+	window  time.Duration
+	now     func() time.Time
+	seen    *boundedCache // occurrenceKey -> the n-1 most recent occurrences
+}
+
+// NewMinOccurrencesLimiter returns a MinOccurrencesLimiter that has seen no entry yet;
+// with n <= 1, every entry passes.
+func NewMinOccurrencesLimiter(n int, window time.Duration) *MinOccurrencesLimiter {
+	return newMinOccurrencesLimiter(n, window, time.Now, defaultMaxKeys)
+}
+
+// NewMinOccurrencesLimiterWithClock is like NewMinOccurrencesLimiter, but gets the
+// current time from now.
+func NewMinOccurrencesLimiterWithClock(n int, window time.Duration, now func() time.Time) *MinOccurrencesLimiter {
+	return newMinOccurrencesLimiter(n, window, now, defaultMaxKeys)
+}
+
+// NewMinOccurrencesLimiterWithMaxKeys is like NewMinOccurrencesLimiter, but tracks up
+// to maxKeys distinct entries, 10000 if maxKeys <= 0.
+func NewMinOccurrencesLimiterWithMaxKeys(n int, window time.Duration, maxKeys int) *MinOccurrencesLimiter {
+	return newMinOccurrencesLimiter(n, window, time.Now, maxKeys)
+}
+
+func newMinOccurrencesLimiter(n int, window time.Duration, now func() time.Time, maxKeys int) *MinOccurrencesLimiter {
+	return &MinOccurrencesLimiter{n: n, window: window, now: now, seen: newBoundedCache(maxKeys)}
+}
+
+// Filter is a FilterFunc recording an occurrence for each written entry.
+func (l *MinOccurrencesLimiter) Filter(entry zapcore.Entry, fields []zapcore.Field) bool {
+	if isCheckPhase(fields) {
+		return true
+	}
+
+	passed := l.n <= 1
+	if !passed {
+		l.seen.update(occurrenceKeyOf(entry), func(value interface{}, found bool) interface{} {
+			now := l.now()
+			var times []time.Time
+			if found {
+				times = value.([]time.Time)
+			}
+
+			// only keep the n-1 most recent occurrences still in the window.
+			kept := times[:0]
+			for _, t := range times {
+				if now.Sub(t) < l.window {
+					kept = append(kept, t)
+				}
+			}
+			if len(kept) >= l.n {
+				kept = kept[len(kept)-l.n+1:]
+			}
+			kept = append(kept, now)
+			passed = len(kept) >= l.n
+			return kept
+		})
+	}
+	if passed {
+		atomic.AddUint64(&l.passed, 1)
+	} else {
+		atomic.AddUint64(&l.dropped, 1)
+	}
+	return passed
+}
+
+// Reset forgets every occurrence and zeroes the counters.
+func (l *MinOccurrencesLimiter) Reset() {
+	l.seen.reset()
+	atomic.StoreUint64(&l.passed, 0)
+	atomic.StoreUint64(&l.dropped, 0)
+}
+
+// Stats returns the decisions taken since the creation of the limiter or its last
+// reset.
+func (l *MinOccurrencesLimiter) Stats() FilterStats {
+	return FilterStats{Passed: atomic.LoadUint64(&l.passed), Dropped: atomic.LoadUint64(&l.dropped)}
+}
+
+// Debounce passes an entry only if at least d elapsed since the last entry passed with
+// the same namespace and message, whatever its level, collapsing rapid repeats (i.e.,
+// a flapping condition) into one entry per interval.
+//
+// Up to 10000 distinct entries are tracked; the least recently seen are forgotten first.
+//
+// Use NewDebouncer to access the number of dropped entries.
+func Debounce(d time.Duration) FilterFunc {
+	return debounce(d, time.Now, defaultMaxKeys)
+}
+
+// DebounceWithClock is like Debounce, but gets the current time from now.
+func DebounceWithClock(d time.Duration, now func() time.Time) FilterFunc {
+	return debounce(d, now, defaultMaxKeys)
+}
+
+// DebounceWithMaxKeys is like Debounce, but tracks up to maxKeys distinct entries, 10000
+// if maxKeys <= 0.
+func DebounceWithMaxKeys(d time.Duration, maxKeys int) FilterFunc {
+	return debounce(d, time.Now, maxKeys)
+}
+
+func debounce(d time.Duration, now func() time.Time, maxKeys int) FilterFunc {
+	info := filterInfo{desc: describeCall("Debounce", d.String()), ignoresFields: true}
+	if d <= 0 {
+		return describe(info, alwaysTrueFilter)
+	}
+	return describe(info, newDebouncer(d, now, maxKeys).Filter)
+}
+
+// Debouncer remembers when the entries last passed, see Debounce.
+type Debouncer struct {
+	passed     uint64 // first fields to guarantee 64-bit alignment for atomic operations
+	dropped    uint64
+	d          time.Duration
+	now        func() time.Time
+	lastPassed *boundedCache // debounceKey -> time of the entry that passed
+}
+
+// debounceKey identifies the entries collapsed by Debounce, whatever their level.
+type debounceKey struct {
+	namespace string
+	message   string
+}
+
+// NewDebouncer returns a Debouncer that has seen no entry yet; with d <= 0, every entry
+// passes.
+func NewDebouncer(d time.Duration) *Debouncer {
+	return newDebouncer(d, time.Now, defaultMaxKeys)
+}
+
+// NewDebouncerWithClock is like NewDebouncer, but gets the current time from now.
+func NewDebouncerWithClock(d time.Duration, now func() time.Time) *Debouncer {
+	return newDebouncer(d, now, defaultMaxKeys)
+}
+
+// NewDebouncerWithMaxKeys is like NewDebouncer, but tracks up to maxKeys distinct
+// entries, 10000 if maxKeys <= 0.
+func NewDebouncerWithMaxKeys(d time.Duration, maxKeys int) *Debouncer {
+	return newDebouncer(d, time.Now, maxKeys)
+}
+
+func newDebouncer(d time.Duration, now func() time.Time, maxKeys int) *Debouncer {
+	return &Debouncer{d: d, now: now, lastPassed: newBoundedCache(maxKeys)}
+}
+
+// Filter is a FilterFunc passing a written entry if d elapsed since the last one passed.
+func (b *Debouncer) Filter(entry zapcore.Entry, fields []zapcore.Field) bool {
+	if isCheckPhase(fields) {
+		return true
+	}
+
+	passed := b.d <= 0
+	if !passed {
+		key := debounceKey{namespace: entry.LoggerName, message: entry.Message}
+		b.lastPassed.update(key, func(last interface{}, found bool) interface{} {
+			now := b.now()
+			if found && now.Sub(last.(time.Time)) < b.d {
+				return last
+			}
+			passed = true
+			return now
+		})
+	}
+	if passed {
+		atomic.AddUint64(&b.passed, 1)
+	} else {
+		atomic.AddUint64(&b.dropped, 1)
+	}
+	return passed
+}
+
+// Reset forgets every entry, so their next occurrence passes.
+func (b *Debouncer) Reset() {
+	b.lastPassed.reset()
+	atomic.StoreUint64(&b.passed, 0)
+	atomic.StoreUint64(&b.dropped, 0)
+}
+
+// Stats returns the decisions taken since the creation of the debouncer or its last
+// reset.
+func (b *Debouncer) Stats() FilterStats {
+	return FilterStats{Passed: atomic.LoadUint64(&b.passed), Dropped: atomic.LoadUint64(&b.dropped)}
+}
+
+// Hysteresis starts passing the entries with the same namespace and message once they
+// occurred at least onThreshold times within the window, and stops passing them once
+// they occur less than offThreshold times within the window, so a condition flapping
+// around a single threshold doesn't make its logs flap too.
+//
+// offThreshold is meant to be lower than onThreshold, it is capped to onThreshold. The
+// occurrences within the window include the entry being written, so they are never
+// less than one: with an offThreshold <= 1, the entries never stop passing once they
+// started, until they are forgotten.
+//
+// Up to 10000 distinct entries are tracked; the least recently seen are forgotten first.
+//
+// Use NewHysteresisLimiter to access the number of dropped entries.
+func Hysteresis(onThreshold, offThreshold int, window time.Duration) FilterFunc {
+	return hysteresis(onThreshold, offThreshold, window, time.Now, defaultMaxKeys)
+}
+
+// HysteresisWithClock is like Hysteresis, but gets the current time from now.
+func HysteresisWithClock(onThreshold, offThreshold int, window time.Duration, now func() time.Time) FilterFunc {
+	return hysteresis(onThreshold, offThreshold, window, now, defaultMaxKeys)
+}
+
+// HysteresisWithMaxKeys is like Hysteresis, but tracks up to maxKeys distinct entries,
+// 10000 if maxKeys <= 0.
+func HysteresisWithMaxKeys(onThreshold, offThreshold int, window time.Duration, maxKeys int) FilterFunc {
+	return hysteresis(onThreshold, offThreshold, window, time.Now, maxKeys)
+}
+
+func hysteresis(onThreshold, offThreshold int, window time.Duration, now func() time.Time, maxKeys int) FilterFunc {
+	info := filterInfo{desc: describeCall("Hysteresis", strconv.Itoa(onThreshold), strconv.Itoa(offThreshold), window.String()), ignoresFields: true}
+	if onThreshold <= 1 { // offThreshold is capped to onThreshold
+		return describe(info, alwaysTrueFilter)
+	}
+	return describe(info, newHysteresisLimiter(onThreshold, offThreshold, window, now, maxKeys).Filter)
+}
+
+// HysteresisLimiter tracks the recent occurrences of each entry and whether it is
+// passing, see Hysteresis.
+type HysteresisLimiter struct {
+	passed       uint64 // first fields to guarantee 64-bit alignment for atomic operations
+	dropped      uint64
+	onThreshold  int
+	offThreshold int
+	window       time.Duration
+	now          func() time.Time
+	states       *boundedCache // debounceKey -> *hysteresisState
+}
+
+type hysteresisState struct {
+	times  []time.Time // the most recent occurrences, up to onThreshold
+	active bool
+}
+
+// NewHysteresisLimiter returns a HysteresisLimiter that has seen no entry yet.
+func NewHysteresisLimiter(onThreshold, offThreshold int, window time.Duration) *HysteresisLimiter {
+	return newHysteresisLimiter(onThreshold, offThreshold, window, time.Now, defaultMaxKeys)
+}
+
+// NewHysteresisLimiterWithClock is like NewHysteresisLimiter, but gets the current time
+// from now.
+func NewHysteresisLimiterWithClock(onThreshold, offThreshold int, window time.Duration, now func() time.Time) *HysteresisLimiter {
+	return newHysteresisLimiter(onThreshold, offThreshold, window, now, defaultMaxKeys)
+}
+
+// NewHysteresisLimiterWithMaxKeys is like NewHysteresisLimiter, but tracks up to
+// maxKeys distinct entries, 10000 if maxKeys <= 0.
+func NewHysteresisLimiterWithMaxKeys(onThreshold, offThreshold int, window time.Duration, maxKeys int) *HysteresisLimiter {
+	return newHysteresisLimiter(onThreshold, offThreshold, window, time.Now, maxKeys)
+}
+
+func newHysteresisLimiter(onThreshold, offThreshold int, window time.Duration, now func() time.Time, maxKeys int) *HysteresisLimiter {
+	if onThreshold < 1 {
+		onThreshold = 1
+	}
+	if offThreshold > onThreshold {
+		offThreshold = onThreshold
+	}
+	return &HysteresisLimiter{
+		onThreshold:  onThreshold,
+		offThreshold: offThreshold,
+		window:       window,
+		now:          now,
+		states:       newBoundedCache(maxKeys),
+	}
+}
+
+// Filter is a FilterFunc recording an occurrence for each written entry.
+func (l *HysteresisLimiter) Filter(entry zapcore.Entry, fields []zapcore.Field) bool {
+	if isCheckPhase(fields) {
+		return true
+	}
+
+	var active bool
+	key := debounceKey{namespace: entry.LoggerName, message: entry.Message}
+	l.states.update(key, func(value interface{}, found bool) interface{} {
+		now := l.now()
+		state := &hysteresisState{}
+		if found {
+			state = value.(*hysteresisState)
+		}
+
+		kept := state.times[:0]
+		for _, t := range state.times {
+			if now.Sub(t) < l.window {
+				kept = append(kept, t)
+			}
+		}
+		if len(kept) >= l.onThreshold {
+			kept = kept[len(kept)-l.onThreshold+1:]
+		}
+		state.times = append(kept, now)
+
+		switch count := len(state.times); {
+		case !state.active && count >= l.onThreshold:
+			state.active = true
+		case state.active && count < l.offThreshold:
+			state.active = false
+		}
+		active = state.active
+		return state
+	})
+	if active {
+		atomic.AddUint64(&l.passed, 1)
+	} else {
+		atomic.AddUint64(&l.dropped, 1)
+	}
+	return active
+}
+
+// Reset forgets every entry, so they have to reach onThreshold again to pass.
+func (l *HysteresisLimiter) Reset() {
+	l.states.reset()
+	atomic.StoreUint64(&l.passed, 0)
+	atomic.StoreUint64(&l.dropped, 0)
+}
+
+// Stats returns the decisions taken since the creation of the limiter or its last
+// reset.
+func (l *HysteresisLimiter) Stats() FilterStats {
+	return FilterStats{Passed: atomic.LoadUint64(&l.passed), Dropped: atomic.LoadUint64(&l.dropped)}
+}
+
+// occurrenceKey identifies similar entries.
+type occurrenceKey struct {
+	namespace string
+	level     zapcore.Level
+	message   string
+}
+
+func occurrenceKeyOf(entry zapcore.Entry) occurrenceKey {
+	return occurrenceKey{
+		namespace: entry.LoggerName,
+		level:     entry.Level,
+		message:   entry.Message,
+	}
+}
@@ -0,0 +1,219 @@
+package zapfilter_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"moul.io/zapfilter"
+	"moul.io/zapfilter/zapfiltertest"
+)
+
+func TestMinOccurrences(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(zapfilter.NewFilteringCore(next, zapfilter.MinOccurrences(3, time.Minute)))
+
+	for i := 0; i < 5; i++ {
+		logger.Error("a")
+		logger.Named("foo").Error("a") // other namespace
+		logger.Warn("a")               // other level
+	}
+	logger.Error("b")
+	logger.Error("b")
+
+	gotLogs := []string{}
+	for _, log := range logs.All() {
+		gotLogs = append(gotLogs, log.LoggerName+":"+log.Level.String()+":"+log.Message)
+	}
+	require.Equal(t, strings.Join([]string{
+		":error:a", "foo:error:a", ":warn:a", // third occurrences
+		":error:a", "foo:error:a", ":warn:a",
+		":error:a", "foo:error:a", ":warn:a",
+	}, " "), strings.Join(gotLogs, " "))
+}
+
+func TestMinOccurrences_window(t *testing.T) {
+	t.Parallel()
+
+	filter := zapfilter.MinOccurrences(2, 50*time.Millisecond)
+	entry := zapfiltertest.Entry(zapcore.ErrorLevel, "", "blip")
+
+	require.Equal(t, []bool{false}, zapfiltertest.Record(filter, []zapcore.Entry{entry}))
+	time.Sleep(100 * time.Millisecond) // previous occurrence expires
+	require.Equal(t, []bool{false, true, true}, zapfiltertest.Record(filter, []zapcore.Entry{entry, entry, entry}))
+}
+
+func TestMinOccurrencesLimiter(t *testing.T) {
+	t.Parallel()
+
+	limiter := zapfilter.NewMinOccurrencesLimiter(2, time.Minute)
+	entry := zapfiltertest.Entry(zapcore.ErrorLevel, "", "blip")
+	require.True(t, limiter.Filter(entry, nil)) // check phase isn't an occurrence
+	require.Equal(t, []bool{false, true, true}, zapfiltertest.Record(limiter.Filter, []zapcore.Entry{entry, entry, entry}))
+	require.Equal(t, zapfilter.FilterStats{Passed: 2, Dropped: 1}, limiter.Stats())
+
+	limiter.Reset()
+	require.Equal(t, zapfilter.FilterStats{}, limiter.Stats())
+	require.Equal(t, []bool{false, true}, zapfiltertest.Record(limiter.Filter, []zapcore.Entry{entry, entry}))
+
+	always := zapfilter.NewMinOccurrencesLimiter(1, time.Minute)
+	require.Equal(t, []bool{true, true}, zapfiltertest.Record(always.Filter, []zapcore.Entry{entry, entry}))
+	require.Equal(t, zapfilter.FilterStats{Passed: 2}, always.Stats())
+}
+
+func TestDebounce(t *testing.T) {
+	t.Parallel()
+
+	filter := zapfilter.Debounce(50 * time.Millisecond)
+	flap := zapfiltertest.Entry(zapcore.WarnLevel, "db", "connection lost")
+	flapError := zapfiltertest.Entry(zapcore.ErrorLevel, "db", "connection lost")
+	other := zapfiltertest.Entry(zapcore.WarnLevel, "api", "connection lost")
+
+	require.Equal(t,
+		[]bool{true, false, false, true, false},
+		zapfiltertest.Record(filter, []zapcore.Entry{flap, flap, flapError, other, flap}),
+	)
+	time.Sleep(100 * time.Millisecond)
+	require.Equal(t, []bool{true, false, true}, zapfiltertest.Record(filter, []zapcore.Entry{flap, flap, other}))
+
+	require.Equal(t, "Debounce(50ms)", zapfilter.Describe(filter))
+	require.Equal(t, []bool{true, true}, zapfiltertest.Record(zapfilter.Debounce(0), []zapcore.Entry{flap, flap}))
+}
+
+func TestDebouncer(t *testing.T) {
+	t.Parallel()
+
+	debouncer := zapfilter.NewDebouncer(time.Minute)
+	flap := zapfiltertest.Entry(zapcore.WarnLevel, "db", "connection lost")
+	require.True(t, debouncer.Filter(flap, nil)) // check phase doesn't debounce
+	require.Equal(t, []bool{true, false, false}, zapfiltertest.Record(debouncer.Filter, []zapcore.Entry{flap, flap, flap}))
+	require.Equal(t, zapfilter.FilterStats{Passed: 1, Dropped: 2}, debouncer.Stats())
+
+	debouncer.Reset()
+	require.Equal(t, zapfilter.FilterStats{}, debouncer.Stats())
+	require.Equal(t, []bool{true, false}, zapfiltertest.Record(debouncer.Filter, []zapcore.Entry{flap, flap}))
+}
+
+func TestHysteresis(t *testing.T) {
+	t.Parallel()
+
+	var now time.Time
+	clock := func() time.Time { return now }
+	filter := zapfilter.HysteresisWithClock(3, 2, 10*time.Second, clock)
+	flap := zapfiltertest.Entry(zapcore.WarnLevel, "db", "connection lost")
+	other := zapfiltertest.Entry(zapcore.WarnLevel, "api", "connection lost")
+
+	steps := []struct {
+		at       time.Duration
+		expected bool
+	}{
+		{0, false},
+		{1 * time.Second, false},
+		{2 * time.Second, true}, // 3 occurrences in the window: on
+		{3 * time.Second, true},
+		{9 * time.Second, true},
+		{12500 * time.Millisecond, true},
+		{20 * time.Second, true},  // 2 occurrences: below on, but not below off
+		{35 * time.Second, false}, // 1 occurrence: off
+		{36 * time.Second, false},
+		{37 * time.Second, true},
+	}
+	start := time.Unix(1600000000, 0)
+	for _, step := range steps {
+		now = start.Add(step.at)
+		require.Equal(t, []bool{step.expected}, zapfiltertest.Record(filter, []zapcore.Entry{flap}), step.at.String())
+	}
+	require.Equal(t, []bool{false}, zapfiltertest.Record(filter, []zapcore.Entry{other}))
+
+	require.Equal(t, "Hysteresis(3, 2, 10s)", zapfilter.Describe(filter))
+	require.True(t, zapfilter.IsAlwaysTrue(zapfilter.Hysteresis(1, 0, time.Second)))
+	require.True(t, zapfilter.IsAlwaysTrue(zapfilter.Hysteresis(1, 5, time.Second)))
+}
+
+func TestHysteresis_offThreshold(t *testing.T) {
+	t.Parallel()
+
+	var now time.Time
+	clock := func() time.Time { return now }
+	flap := zapfiltertest.Entry(zapcore.WarnLevel, "db", "connection lost")
+	start := time.Unix(1600000000, 0)
+
+	// the occurrence being written always counts, so an offThreshold of 1 (or less)
+	// never switches off
+	for _, offThreshold := range []int{1, 0, -1} {
+		filter := zapfilter.HysteresisWithClock(2, offThreshold, 10*time.Second, clock)
+		got := []bool{}
+		for _, at := range []time.Duration{0, time.Second, time.Minute, time.Hour, 24 * time.Hour} {
+			now = start.Add(at)
+			got = append(got, zapfiltertest.Record(filter, []zapcore.Entry{flap})...)
+		}
+		require.Equal(t, []bool{false, true, true, true, true}, got, offThreshold)
+	}
+
+	// whereas an offThreshold of 2 switches off once the entry is alone in the window
+	filter := zapfilter.HysteresisWithClock(2, 2, 10*time.Second, clock)
+	got := []bool{}
+	for _, at := range []time.Duration{0, time.Second, time.Minute} {
+		now = start.Add(at)
+		got = append(got, zapfiltertest.Record(filter, []zapcore.Entry{flap})...)
+	}
+	require.Equal(t, []bool{false, true, false}, got)
+}
+
+func TestHysteresisLimiter(t *testing.T) {
+	t.Parallel()
+
+	limiter := zapfilter.NewHysteresisLimiter(2, 1, time.Minute)
+	flap := zapfiltertest.Entry(zapcore.WarnLevel, "db", "connection lost")
+	require.True(t, limiter.Filter(flap, nil)) // check phase isn't an occurrence
+	require.Equal(t, []bool{false, true, true}, zapfiltertest.Record(limiter.Filter, []zapcore.Entry{flap, flap, flap}))
+	require.Equal(t, zapfilter.FilterStats{Passed: 2, Dropped: 1}, limiter.Stats())
+
+	limiter.Reset()
+	require.Equal(t, zapfilter.FilterStats{}, limiter.Stats())
+	require.Equal(t, []bool{false, true}, zapfiltertest.Record(limiter.Filter, []zapcore.Entry{flap, flap}))
+
+	always := zapfilter.NewHysteresisLimiter(0, 0, time.Minute)
+	require.Equal(t, []bool{true, true}, zapfiltertest.Record(always.Filter, []zapcore.Entry{flap, flap}))
+}
+
+func TestOccurrenceLimiters_clock(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1600000000, 0)
+	clock := func() time.Time { return now }
+	entry := zapfiltertest.Entry(zapcore.ErrorLevel, "db", "connection lost")
+
+	minOccurrences := zapfilter.NewMinOccurrencesLimiterWithClock(2, 10*time.Second, clock)
+	debouncer := zapfilter.NewDebouncerWithClock(10*time.Second, clock)
+	hysteresis := zapfilter.NewHysteresisLimiterWithClock(2, 2, 10*time.Second, clock)
+	minOccurrencesFilter := zapfilter.MinOccurrencesWithClock(2, 10*time.Second, clock)
+	debounceFilter := zapfilter.DebounceWithClock(10*time.Second, clock)
+	hysteresisFilter := zapfilter.HysteresisWithClock(2, 2, 10*time.Second, clock)
+	steps := []struct {
+		at                                   time.Duration
+		minOccurrences, debounce, hysteresis bool
+	}{
+		{0, false, true, false},
+		{5 * time.Second, true, false, true},
+		{10 * time.Second, true, true, true},
+		{30 * time.Second, false, true, false}, // the previous occurrence left the window
+		{39 * time.Second, true, false, true},
+	}
+	start := now
+	for _, step := range steps {
+		now = start.Add(step.at)
+		require.Equal(t, []bool{step.minOccurrences}, zapfiltertest.Record(minOccurrences.Filter, []zapcore.Entry{entry}), step.at.String())
+		require.Equal(t, []bool{step.debounce}, zapfiltertest.Record(debouncer.Filter, []zapcore.Entry{entry}), step.at.String())
+		require.Equal(t, []bool{step.hysteresis}, zapfiltertest.Record(hysteresis.Filter, []zapcore.Entry{entry}), step.at.String())
+		require.Equal(t, []bool{step.minOccurrences}, zapfiltertest.Record(minOccurrencesFilter, []zapcore.Entry{entry}), step.at.String())
+		require.Equal(t, []bool{step.debounce}, zapfiltertest.Record(debounceFilter, []zapcore.Entry{entry}), step.at.String())
+		require.Equal(t, []bool{step.hysteresis}, zapfiltertest.Record(hysteresisFilter, []zapcore.Entry{entry}), step.at.String())
+	}
+}
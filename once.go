@@ -0,0 +1,138 @@
+package zapfilter
+
+import (
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Once passes the first entry of each key and drops the next ones, i.e., to log a
+// deprecation warning once per process. The key of each entry is computed with keyFn;
+// with a nil keyFn, entries are identified by their namespace and message.
+//
+//   core := zapfilter.NewFilteringCore(next, zapfilter.Any(zapfilter.MinimumLevel(zapcore.ErrorLevel), zapfilter.Once(nil)))
+//
+// Up to 10000 keys are remembered; the least recently seen are forgotten first, and
+// their next entry passes again.
+//
+// Use NewOnceLimiter to access the number of dropped entries.
+func Once(keyFn func(zapcore.Entry) string) FilterFunc {
+	return OnceWithTTL(keyFn, 0)
+}
+
+// OnceWithTTL is like Once, but forgets the keys ttl after their entry passed, so the
+// entry passes again, i.e., at most once per hour. A ttl <= 0 never expires.
+func OnceWithTTL(keyFn func(zapcore.Entry) string, ttl time.Duration) FilterFunc {
+	return once(keyFn, ttl, time.Now, defaultMaxKeys)
+}
+
+// OnceWithClock is like OnceWithTTL, but gets the current time from now.
+func OnceWithClock(keyFn func(zapcore.Entry) string, ttl time.Duration, now func() time.Time) FilterFunc {
+	return once(keyFn, ttl, now, defaultMaxKeys)
+}
+
+// OnceWithMaxKeys is like OnceWithTTL, but remembers up to maxKeys keys, 10000 if
+// maxKeys <= 0.
+func OnceWithMaxKeys(keyFn func(zapcore.Entry) string, ttl time.Duration, maxKeys int) FilterFunc {
+	return once(keyFn, ttl, time.Now, maxKeys)
+}
+
+func once(keyFn func(zapcore.Entry) string, ttl time.Duration, now func() time.Time, maxKeys int) FilterFunc {
+	info := filterInfo{desc: describeCall("Once", describeKeyFn(keyFn), ttl.String()), ignoresFields: true}
+	return describe(info, newOnceLimiter(keyFn, ttl, now, maxKeys).Filter)
+}
+
+// OnceLimiter remembers the keys of the entries that passed, see Once.
+type OnceLimiter struct {
+	passed  uint64 // first fields to guarantee 64-bit alignment for atomic operations
+	dropped uint64
+
+	keyFn func(zapcore.Entry) string
+	ttl   time.Duration
+	now   func() time.Time
+	seen  *boundedCache // key -> time of the entry that passed
+}
+
+// NewOnceLimiter returns a OnceLimiter that has seen no entry yet.
+//
+//   once := zapfilter.NewOnceLimiter(nil)
+//   core := zapfilter.NewFilteringCore(next, zapfilter.Any(zapfilter.MinimumLevel(zapcore.ErrorLevel), once.Filter))
+func NewOnceLimiter(keyFn func(zapcore.Entry) string) *OnceLimiter {
+	return NewOnceLimiterWithTTL(keyFn, 0)
+}
+
+// NewOnceLimiterWithTTL is like NewOnceLimiter, but forgets the keys ttl after their
+// entry passed, see OnceWithTTL.
+func NewOnceLimiterWithTTL(keyFn func(zapcore.Entry) string, ttl time.Duration) *OnceLimiter {
+	return newOnceLimiter(keyFn, ttl, time.Now, defaultMaxKeys)
+}
+
+// NewOnceLimiterWithMaxKeys is like NewOnceLimiterWithTTL, but remembers up to maxKeys
+// keys, 10000 if maxKeys <= 0.
+func NewOnceLimiterWithMaxKeys(keyFn func(zapcore.Entry) string, ttl time.Duration, maxKeys int) *OnceLimiter {
+	return newOnceLimiter(keyFn, ttl, time.Now, maxKeys)
+}
+
+// NewOnceLimiterWithClock is like NewOnceLimiterWithTTL, but gets the current time from
+// now.
+func NewOnceLimiterWithClock(keyFn func(zapcore.Entry) string, ttl time.Duration, now func() time.Time) *OnceLimiter {
+	return newOnceLimiter(keyFn, ttl, now, defaultMaxKeys)
+}
+
+func newOnceLimiter(keyFn func(zapcore.Entry) string, ttl time.Duration, now func() time.Time, maxKeys int) *OnceLimiter {
+	if keyFn == nil {
+		keyFn = func(entry zapcore.Entry) string {
+			return entry.LoggerName + "\x00" + entry.Message
+		}
+	}
+	return &OnceLimiter{
+		keyFn: keyFn,
+		ttl:   ttl,
+		now:   now,
+		seen:  newBoundedCache(maxKeys),
+	}
+}
+
+// Filter is a FilterFunc passing the first written entry of each key.
+func (o *OnceLimiter) Filter(entry zapcore.Entry, fields []zapcore.Field) bool {
+	if isCheckPhase(fields) {
+		return true
+	}
+	var passed bool
+	o.seen.update(o.keyFn(entry), func(value interface{}, found bool) interface{} {
+		now := o.now()
+		if found && (o.ttl <= 0 || now.Sub(value.(time.Time)) < o.ttl) {
+			return value
+		}
+		passed = true
+		return now
+	})
+	if passed {
+		atomic.AddUint64(&o.passed, 1)
+	} else {
+		atomic.AddUint64(&o.dropped, 1)
+	}
+	return passed
+}
+
+// Reset forgets every key, so their next entry passes again.
+func (o *OnceLimiter) Reset() {
+	o.seen.reset()
+	atomic.StoreUint64(&o.passed, 0)
+	atomic.StoreUint64(&o.dropped, 0)
+}
+
+// Stats returns the decisions taken since the creation of the limiter or its last
+// reset.
+func (o *OnceLimiter) Stats() FilterStats {
+	return FilterStats{Passed: atomic.LoadUint64(&o.passed), Dropped: atomic.LoadUint64(&o.dropped)}
+}
+
+// describeKeyFn describes the key function of a keyed filter.
+func describeKeyFn(keyFn func(zapcore.Entry) string) string {
+	if keyFn == nil {
+		return "nil"
+	}
+	return "custom"
+}
@@ -0,0 +1,103 @@
+package zapfilter_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"moul.io/zapfilter"
+	"moul.io/zapfilter/zapfiltertest"
+)
+
+func TestOnce(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	once := zapfilter.NewOnceLimiter(nil)
+	logger := zap.New(zapfilter.NewFilteringCore(next, once.Filter))
+
+	for i := 0; i < 3; i++ {
+		logger.Warn("deprecated")
+		logger.Named("foo").Warn("deprecated") // other namespace
+		logger.Error("deprecated")             // same key, whatever the level
+		logger.Warn("other")
+	}
+
+	gotLogs := []string{}
+	for _, log := range logs.All() {
+		gotLogs = append(gotLogs, log.LoggerName+":"+log.Message)
+	}
+	require.Equal(t, []string{":deprecated", "foo:deprecated", ":other"}, gotLogs)
+	require.Equal(t, zapfilter.FilterStats{Passed: 3, Dropped: 9}, once.Stats())
+
+	once.Reset()
+	require.Equal(t, zapfilter.FilterStats{}, once.Stats())
+	logger.Warn("deprecated")
+	logger.Warn("deprecated")
+	require.Equal(t, 4, logs.Len())
+	require.Equal(t, zapfilter.FilterStats{Passed: 1, Dropped: 1}, once.Stats())
+}
+
+func TestOnce_filterFunc(t *testing.T) {
+	t.Parallel()
+
+	entries := []zapcore.Entry{
+		zapfiltertest.Entry(zapcore.InfoLevel, "a", "hello"),
+		zapfiltertest.Entry(zapcore.WarnLevel, "a", "hello"),
+		zapfiltertest.Entry(zapcore.InfoLevel, "b", "hello"),
+	}
+	require.Equal(t, []bool{true, false, true}, zapfiltertest.Record(zapfilter.Once(nil), entries))
+	byLevel := zapfilter.OnceWithTTL(func(entry zapcore.Entry) string { return entry.Level.String() }, time.Hour)
+	require.Equal(t, []bool{true, true, false}, zapfiltertest.Record(byLevel, entries))
+
+	require.Equal(t, "Once(nil, 0s)", zapfilter.Describe(zapfilter.Once(nil)))
+	require.Equal(t, "Once(custom, 1h0m0s)", zapfilter.Describe(byLevel))
+	require.True(t, zapfilter.Once(nil)(zapcore.Entry{}, nil)) // Check passes
+}
+
+func TestOnce_keyFn(t *testing.T) {
+	t.Parallel()
+
+	byLevel := zapfilter.NewOnceLimiter(func(entry zapcore.Entry) string { return entry.Level.String() })
+	entries := []zapcore.Entry{
+		zapfiltertest.Entry(zapcore.InfoLevel, "a", "hello"),
+		zapfiltertest.Entry(zapcore.InfoLevel, "b", "world"),
+		zapfiltertest.Entry(zapcore.WarnLevel, "a", "hello"),
+	}
+	require.Equal(t, []bool{true, false, true}, zapfiltertest.Record(byLevel.Filter, entries))
+}
+
+func TestOnce_ttl(t *testing.T) {
+	t.Parallel()
+
+	once := zapfilter.NewOnceLimiterWithTTL(nil, 50*time.Millisecond)
+	entry := zapfiltertest.Entry(zapcore.WarnLevel, "", "deprecated")
+
+	require.Equal(t, []bool{true, false}, zapfiltertest.Record(once.Filter, []zapcore.Entry{entry, entry}))
+	time.Sleep(100 * time.Millisecond) // the key expires
+	require.Equal(t, []bool{true, false}, zapfiltertest.Record(once.Filter, []zapcore.Entry{entry, entry}))
+}
+
+func TestOnce_clock(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1600000000, 0)
+	clock := func() time.Time { return now }
+	entry := zapfiltertest.Entry(zapcore.WarnLevel, "", "deprecated")
+
+	start := now
+	for _, filter := range []zapfilter.FilterFunc{
+		zapfilter.NewOnceLimiterWithClock(nil, time.Hour, clock).Filter,
+		zapfilter.OnceWithClock(nil, time.Hour, clock),
+	} {
+		now = start
+		require.Equal(t, []bool{true, false}, zapfiltertest.Record(filter, []zapcore.Entry{entry, entry}))
+		now = now.Add(59 * time.Minute)
+		require.Equal(t, []bool{false}, zapfiltertest.Record(filter, []zapcore.Entry{entry}))
+		now = now.Add(time.Minute) // the key expires
+		require.Equal(t, []bool{true, false}, zapfiltertest.Record(filter, []zapcore.Entry{entry, entry}))
+	}
+}
@@ -0,0 +1,76 @@
+package zapfilter
+
+import (
+	"context"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// overrideKey is the key of the fields created by ContextOverride.
+const overrideKey = "zapfilter.override"
+
+type overrideContextKey struct{}
+
+// WithOverride returns a copy of ctx carrying a filter that replaces the filter of the
+// cores configured with WithContextOverride, for the work done under ctx, i.e., to log
+// everything while handling a single request:
+//
+//   ctx = zapfilter.WithOverride(ctx, zapfilter.AllowAll())
+//
+// zap never passes a context to its cores, so the override has to be attached to the
+// logger with ContextOverride.
+func WithOverride(ctx context.Context, filter FilterFunc) context.Context {
+	return context.WithValue(ctx, overrideContextKey{}, filter)
+}
+
+// ContextOverride returns a field carrying the override set on ctx with WithOverride,
+// or a no-op field if there is none.
+//
+// It is meant to be attached to a logger with With:
+//
+//   logger := logger.With(zapfilter.ContextOverride(ctx))
+//
+// The field is never encoded.
+func ContextOverride(ctx context.Context) zapcore.Field {
+	filter, ok := ctx.Value(overrideContextKey{}).(FilterFunc)
+	if !ok || filter == nil {
+		return zapcore.Field{Type: zapcore.SkipType}
+	}
+	return zapcore.Field{Key: overrideKey, Type: zapcore.SkipType, Interface: filter}
+}
+
+// WithContextOverride makes the core honor the ContextOverride fields: the filter of
+// the most recent one replaces the filter of the core.
+//
+// An override added with With applies to Check and Enabled, so it can either tighten
+// or loosen the filtering. An override passed to the logging call itself is only seen
+// by Write, after Check already let the entry pass, so it can only tighten it.
+func WithContextOverride() Option {
+	return func(core *filteringCore) {
+		core.contextOverride = true
+	}
+}
+
+// overrideOf returns the filter of the last ContextOverride field of fields, if any.
+func overrideOf(fields []zapcore.Field) (FilterFunc, bool) {
+	for i := len(fields) - 1; i >= 0; i-- {
+		if fields[i].Type == zapcore.SkipType && fields[i].Key == overrideKey {
+			filter, ok := fields[i].Interface.(FilterFunc)
+			return filter, ok
+		}
+	}
+	return nil, false
+}
+
+// setFilter replaces the filter of the core, keeping its options.
+func (core *filteringCore) setFilter(filter FilterFunc) {
+	if filter == nil {
+		filter = alwaysFalseFilter
+	}
+	core.filter = filter
+	core.levels = levelsOf(filter)
+	core.ignoresFields = ignoresFields(filter)
+	if core.explain != nil {
+		core.explain = explainerOf(filter)
+	}
+}
@@ -0,0 +1,69 @@
+package zapfilter_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"moul.io/zapfilter"
+)
+
+func TestWithOverride(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	core := zapfilter.NewFilteringCore(next, zapfilter.MustParseRules("info+:*"), zapfilter.WithContextOverride())
+	logger := zap.New(core)
+
+	handle := func(ctx context.Context, id string) {
+		logger := logger.With(zapfilter.ContextOverride(ctx), zap.String("request", id))
+		logger.Debug("debug " + id)
+		logger.Info("info " + id)
+		logger.Warn("warn " + id)
+	}
+
+	handle(context.Background(), "a")
+	handle(zapfilter.WithOverride(context.Background(), zapfilter.AllowAll()), "b")
+	handle(zapfilter.WithOverride(context.Background(), zapfilter.MinimumLevel(zapcore.WarnLevel)), "c")
+	handle(context.Background(), "d")
+
+	// an override passed to the logging call can only tighten the filtering
+	strict := zapfilter.WithOverride(context.Background(), zapfilter.DenyAll())
+	logger.Info("e", zapfilter.ContextOverride(strict))
+	loose := zapfilter.WithOverride(context.Background(), zapfilter.AllowAll())
+	logger.Debug("f", zapfilter.ContextOverride(loose))
+
+	gotLogs := []string{}
+	for _, log := range logs.All() {
+		gotLogs = append(gotLogs, log.Message)
+	}
+	require.Equal(t, []string{
+		"info a", "warn a",
+		"debug b", "info b", "warn b",
+		"warn c",
+		"info d", "warn d",
+	}, gotLogs)
+	require.Equal(t, map[string]interface{}{"request": "b"}, logs.All()[2].ContextMap())
+
+	overridden := logger.With(zapfilter.ContextOverride(zapfilter.WithOverride(context.Background(), zapfilter.AllowAll())))
+	require.False(t, logger.Core().Enabled(zapcore.DebugLevel))
+	require.True(t, overridden.Core().Enabled(zapcore.DebugLevel))
+}
+
+func TestWithOverride_disabled(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(zapfilter.NewFilteringCore(next, zapfilter.MustParseRules("info+:*")))
+
+	ctx := zapfilter.WithOverride(context.Background(), zapfilter.AllowAll())
+	logger.With(zapfilter.ContextOverride(ctx)).Debug("a")
+	logger.With(zapfilter.ContextOverride(ctx)).Info("b")
+
+	require.Equal(t, 1, logs.Len())
+	require.Equal(t, "b", logs.All()[0].Message)
+	require.Empty(t, logs.All()[0].ContextMap())
+}
@@ -0,0 +1,25 @@
+package zapfilter
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// lockedRand serializes the calls to a *rand.Rand, which isn't safe for concurrent use
+// contrary to the global source.
+type lockedRand struct {
+	mutex sync.Mutex
+	rand  *rand.Rand
+}
+
+func (r *lockedRand) Intn(n int) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.rand.Intn(n)
+}
+
+func (r *lockedRand) Float64() float64 {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.rand.Float64()
+}
@@ -0,0 +1,296 @@
+package zapfilter
+
+import (
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// RateLimitByLevel limits the number of entries per second for each configured level.
+//
+// Levels absent from the map are not limited; a limit of zero (or less) drops every
+// entry of the level. Each level has its own token bucket holding up to one second
+// of entries.
+//
+// Like every stateful filter, the decision is taken from Write; Check always passes.
+//
+// Use NewLevelRateLimiter to access the number of dropped entries.
+func RateLimitByLevel(limits map[zapcore.Level]int) FilterFunc {
+	info := filterInfo{desc: describeCall("RateLimitByLevel", describeLimits(limits)), ignoresFields: true}
+	return describe(info, NewLevelRateLimiter(limits).Filter)
+}
+
+// LevelRateLimiter holds a token bucket per limited level, see RateLimitByLevel.
+type LevelRateLimiter struct {
+	passed  uint64 // first fields to guarantee 64-bit alignment for atomic operations
+	dropped uint64
+	buckets map[zapcore.Level]*tokenBucket
+}
+
+// NewLevelRateLimiter returns a LevelRateLimiter whose buckets start full.
+func NewLevelRateLimiter(limits map[zapcore.Level]int) *LevelRateLimiter {
+	return NewLevelRateLimiterWithClock(limits, time.Now)
+}
+
+// NewLevelRateLimiterWithClock is like NewLevelRateLimiter, but gets the current time
+// from now.
+func NewLevelRateLimiterWithClock(limits map[zapcore.Level]int, now func() time.Time) *LevelRateLimiter {
+	buckets := make(map[zapcore.Level]*tokenBucket, len(limits))
+	for level, limit := range limits {
+		buckets[level] = newTokenBucket(limit, limit, now)
+	}
+	return &LevelRateLimiter{buckets: buckets}
+}
+
+// Filter is a FilterFunc consuming a token of the bucket of the level for each written
+// entry.
+func (l *LevelRateLimiter) Filter(entry zapcore.Entry, fields []zapcore.Field) bool {
+	if isCheckPhase(fields) {
+		return true
+	}
+	if bucket, found := l.buckets[entry.Level]; found && !bucket.allow() {
+		atomic.AddUint64(&l.dropped, 1)
+		return false
+	}
+	atomic.AddUint64(&l.passed, 1)
+	return true
+}
+
+// Dropped returns the number of entries dropped so far.
+func (l *LevelRateLimiter) Dropped() uint64 {
+	return atomic.LoadUint64(&l.dropped)
+}
+
+// Reset refills every bucket and zeroes the counters.
+func (l *LevelRateLimiter) Reset() {
+	for _, bucket := range l.buckets {
+		bucket.reset()
+	}
+	atomic.StoreUint64(&l.passed, 0)
+	atomic.StoreUint64(&l.dropped, 0)
+}
+
+// Stats returns the decisions taken since the creation of the limiter or its last
+// reset.
+func (l *LevelRateLimiter) Stats() FilterStats {
+	return FilterStats{Passed: atomic.LoadUint64(&l.passed), Dropped: atomic.LoadUint64(&l.dropped)}
+}
+
+// tokenBucket is a concurrency-safe token bucket refilled over time.
+type tokenBucket struct {
+	mutex  sync.Mutex
+	rate   float64 // tokens per second
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newTokenBucket(perSecond, burst int, now func() time.Time) *tokenBucket {
+	if perSecond < 0 {
+		perSecond = 0
+	}
+	if burst < 0 {
+		burst = 0
+	}
+	return &tokenBucket{
+		rate:   float64(perSecond),
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now(),
+		now:    now,
+	}
+}
+
+// reset refills the bucket.
+func (b *tokenBucket) reset() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	b.tokens = b.burst
+	b.last = b.now()
+}
+
+// allow consumes a token if one is available.
+func (b *tokenBucket) allow() bool {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	now := b.now()
+	if elapsed := now.Sub(b.last); elapsed > 0 {
+		b.tokens += elapsed.Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+		b.last = now
+	}
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// GlobalRateLimit limits the number of entries per second with a single token bucket
+// shared by every entry, whatever its level or namespace.
+//
+// Use NewGlobalRateLimiter to access the number of dropped entries.
+func GlobalRateLimit(perSecond, burst int) FilterFunc {
+	info := filterInfo{desc: describeCall("GlobalRateLimit", strconv.Itoa(perSecond), strconv.Itoa(burst)), ignoresFields: true}
+	return describe(info, NewGlobalRateLimiter(perSecond, burst).Filter)
+}
+
+// GlobalRateLimiter is a token bucket shared by every entry, refilled with perSecond
+// tokens per second up to burst tokens.
+type GlobalRateLimiter struct {
+	passed  uint64 // first fields to guarantee 64-bit alignment for atomic operations
+	dropped uint64
+	bucket  *tokenBucket
+}
+
+// NewGlobalRateLimiter returns a GlobalRateLimiter that starts with a full bucket.
+func NewGlobalRateLimiter(perSecond, burst int) *GlobalRateLimiter {
+	return NewGlobalRateLimiterWithClock(perSecond, burst, time.Now)
+}
+
+// NewGlobalRateLimiterWithClock is like NewGlobalRateLimiter, but gets the current time
+// from now.
+func NewGlobalRateLimiterWithClock(perSecond, burst int, now func() time.Time) *GlobalRateLimiter {
+	return &GlobalRateLimiter{bucket: newTokenBucket(perSecond, burst, now)}
+}
+
+// Filter is a FilterFunc consuming a token for each written entry.
+func (l *GlobalRateLimiter) Filter(entry zapcore.Entry, fields []zapcore.Field) bool {
+	if isCheckPhase(fields) {
+		return true
+	}
+	if l.bucket.allow() {
+		atomic.AddUint64(&l.passed, 1)
+		return true
+	}
+	atomic.AddUint64(&l.dropped, 1)
+	return false
+}
+
+// Dropped returns the number of entries dropped so far.
+func (l *GlobalRateLimiter) Dropped() uint64 {
+	return atomic.LoadUint64(&l.dropped)
+}
+
+// Reset refills the bucket and zeroes the counters.
+func (l *GlobalRateLimiter) Reset() {
+	l.bucket.reset()
+	atomic.StoreUint64(&l.passed, 0)
+	atomic.StoreUint64(&l.dropped, 0)
+}
+
+// Stats returns the decisions taken since the creation of the limiter or its last
+// reset.
+func (l *GlobalRateLimiter) Stats() FilterStats {
+	return FilterStats{Passed: atomic.LoadUint64(&l.passed), Dropped: atomic.LoadUint64(&l.dropped)}
+}
+
+// LeakyBucket limits the output to a steady rate: the bucket leaks ratePerSecond
+// entries per second, each admitted entry fills it by one, and entries overflowing
+// capacity are dropped.
+//
+// Contrary to GlobalRateLimit, whose burst usually holds a second of entries, the
+// capacity is meant to stay small to protect downstream systems sensitive to bursts;
+// with a capacity of one, admitted entries are at least 1/ratePerSecond apart.
+//
+// Use NewLeakyBucketLimiter to access the number of dropped entries.
+func LeakyBucket(ratePerSecond int, capacity int) FilterFunc {
+	info := filterInfo{desc: describeCall("LeakyBucket", strconv.Itoa(ratePerSecond), strconv.Itoa(capacity)), ignoresFields: true}
+	return describe(info, NewLeakyBucketLimiter(ratePerSecond, capacity).Filter)
+}
+
+// LeakyBucketLimiter is a leaky bucket shared by every entry, see LeakyBucket.
+type LeakyBucketLimiter struct {
+	passed   uint64 // first fields to guarantee 64-bit alignment for atomic operations
+	dropped  uint64
+	mutex    sync.Mutex
+	rate     float64 // leaked entries per second
+	capacity float64
+	level    float64
+	last     time.Time
+	now      func() time.Time
+}
+
+// NewLeakyBucketLimiter returns a LeakyBucketLimiter that starts empty.
+func NewLeakyBucketLimiter(ratePerSecond int, capacity int) *LeakyBucketLimiter {
+	return NewLeakyBucketLimiterWithClock(ratePerSecond, capacity, time.Now)
+}
+
+// NewLeakyBucketLimiterWithClock is like NewLeakyBucketLimiter, but gets the current
+// time from now.
+func NewLeakyBucketLimiterWithClock(ratePerSecond int, capacity int, now func() time.Time) *LeakyBucketLimiter {
+	if ratePerSecond < 0 {
+		ratePerSecond = 0
+	}
+	if capacity < 0 {
+		capacity = 0
+	}
+	return &LeakyBucketLimiter{
+		rate:     float64(ratePerSecond),
+		capacity: float64(capacity),
+		last:     now(),
+		now:      now,
+	}
+}
+
+// Filter is a FilterFunc filling the bucket with each written entry.
+func (l *LeakyBucketLimiter) Filter(entry zapcore.Entry, fields []zapcore.Field) bool {
+	if isCheckPhase(fields) {
+		return true
+	}
+	if l.add() {
+		atomic.AddUint64(&l.passed, 1)
+		return true
+	}
+	atomic.AddUint64(&l.dropped, 1)
+	return false
+}
+
+// add leaks the bucket, then fills it by one if there is room.
+func (l *LeakyBucketLimiter) add() bool {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := l.now()
+	if elapsed := now.Sub(l.last); elapsed > 0 {
+		l.level -= elapsed.Seconds() * l.rate
+		if l.level < 0 {
+			l.level = 0
+		}
+		l.last = now
+	}
+	if l.level+1 > l.capacity {
+		return false
+	}
+	l.level++
+	return true
+}
+
+// Dropped returns the number of entries dropped so far.
+func (l *LeakyBucketLimiter) Dropped() uint64 {
+	return atomic.LoadUint64(&l.dropped)
+}
+
+// Reset empties the bucket and zeroes the counters.
+func (l *LeakyBucketLimiter) Reset() {
+	l.mutex.Lock()
+	l.level = 0
+	l.last = l.now()
+	l.mutex.Unlock()
+	atomic.StoreUint64(&l.passed, 0)
+	atomic.StoreUint64(&l.dropped, 0)
+}
+
+// Stats returns the decisions taken since the creation of the limiter or its last
+// reset.
+func (l *LeakyBucketLimiter) Stats() FilterStats {
+	return FilterStats{Passed: atomic.LoadUint64(&l.passed), Dropped: atomic.LoadUint64(&l.dropped)}
+}
@@ -0,0 +1,206 @@
+package zapfilter_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"moul.io/zapfilter"
+	"moul.io/zapfilter/zapfiltertest"
+)
+
+func TestRateLimitByLevel(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	// the clock is frozen, so no token is refilled while flooding
+	now := time.Unix(1600000000, 0)
+	limiter := zapfilter.NewLevelRateLimiterWithClock(map[zapcore.Level]int{
+		zapcore.DebugLevel: 5,
+		zapcore.InfoLevel:  2,
+		zapcore.WarnLevel:  0,
+	}, func() time.Time { return now })
+	logger := zap.New(zapfilter.NewFilteringCore(next, limiter.Filter))
+
+	const flood = 500
+	var wg sync.WaitGroup
+	for _, level := range []zapcore.Level{zapcore.DebugLevel, zapcore.InfoLevel, zapcore.WarnLevel, zapcore.ErrorLevel} {
+		wg.Add(1)
+		go func(level zapcore.Level) {
+			defer wg.Done()
+			for i := 0; i < flood; i++ {
+				if ce := logger.Check(level, "flood"); ce != nil {
+					ce.Write()
+				}
+			}
+		}(level)
+	}
+	wg.Wait()
+
+	require.Equal(t, 5, countLevel(logs, zapcore.DebugLevel))
+	require.Equal(t, 2, countLevel(logs, zapcore.InfoLevel))
+	require.Equal(t, 0, countLevel(logs, zapcore.WarnLevel))
+	require.Equal(t, flood, countLevel(logs, zapcore.ErrorLevel))
+	require.Equal(t, zapfilter.FilterStats{Passed: 5 + 2 + flood, Dropped: 3*flood - 5 - 2}, limiter.Stats())
+
+	filter := zapfilter.RateLimitByLevel(map[zapcore.Level]int{zapcore.InfoLevel: 2})
+	require.Equal(t, "RateLimitByLevel(info=2)", zapfilter.Describe(filter))
+}
+
+func TestLevelRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	limiter := zapfilter.NewLevelRateLimiter(map[zapcore.Level]int{zapcore.InfoLevel: 2})
+	info := zapcore.Entry{Level: zapcore.InfoLevel}
+	require.True(t, limiter.Filter(info, nil)) // check phase never consumes tokens
+	require.Equal(t,
+		[]bool{true, true, false, false},
+		zapfiltertest.Record(limiter.Filter, []zapcore.Entry{info, info, info, info}),
+	)
+	require.True(t, limiter.Filter(zapcore.Entry{Level: zapcore.ErrorLevel}, []zapcore.Field{}))
+	require.Equal(t, uint64(2), limiter.Dropped())
+	require.Equal(t, zapfilter.FilterStats{Passed: 3, Dropped: 2}, limiter.Stats())
+
+	limiter.Reset()
+	require.Equal(t, zapfilter.FilterStats{}, limiter.Stats())
+	require.Equal(t, []bool{true, true, false}, zapfiltertest.Record(limiter.Filter, []zapcore.Entry{info, info, info}))
+}
+
+func countLevel(logs *observer.ObservedLogs, level zapcore.Level) int {
+	count := 0
+	for _, entry := range logs.All() {
+		if entry.Level == level {
+			count++
+		}
+	}
+	return count
+}
+
+func TestGlobalRateLimiter(t *testing.T) {
+	t.Parallel()
+
+	limiter := zapfilter.NewGlobalRateLimiter(1, 100)
+	for i := 0; i < 1000; i++ {
+		require.True(t, limiter.Filter(zapcore.Entry{}, nil)) // check phase never consumes tokens
+	}
+
+	const (
+		workers = 8
+		calls   = 1000
+	)
+	var (
+		wg     sync.WaitGroup
+		mutex  sync.Mutex
+		passed uint64
+	)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var local uint64
+			for j := 0; j < calls; j++ {
+				entry := zapcore.Entry{Level: zapcore.Level(j%4 - 1), LoggerName: "worker"}
+				if limiter.Filter(entry, []zapcore.Field{}) {
+					local++
+				}
+			}
+			mutex.Lock()
+			passed += local
+			mutex.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	require.InDelta(t, 100, passed, 1)
+	require.Equal(t, uint64(workers*calls), passed+limiter.Dropped())
+}
+
+func TestGlobalRateLimit(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(zapfilter.NewFilteringCore(next, zapfilter.GlobalRateLimit(1, 3)))
+	for i := 0; i < 10; i++ {
+		logger.Info("a")
+		logger.Named("foo").Error("b")
+	}
+	require.InDelta(t, 3, logs.Len(), 1)
+}
+
+func TestLeakyBucket(t *testing.T) {
+	t.Parallel()
+
+	burst := func(filter zapfilter.FilterFunc, n int) int {
+		next, logs := observer.New(zapcore.DebugLevel)
+		logger := zap.New(zapfilter.NewFilteringCore(next, filter))
+		for i := 0; i < n; i++ {
+			logger.Info("burst")
+		}
+		return logs.Len()
+	}
+
+	// the clock is frozen during the bursts, so nothing leaks nor is refilled
+	now := time.Unix(1600000000, 0)
+	clock := func() time.Time { return now }
+
+	// the token bucket lets a full second of entries through at once
+	require.Equal(t, 10, burst(zapfilter.NewGlobalRateLimiterWithClock(10, 10, clock).Filter, 100))
+	// the leaky bucket only lets its capacity through
+	require.Equal(t, 1, burst(zapfilter.NewLeakyBucketLimiterWithClock(10, 1, clock).Filter, 100))
+	require.Equal(t, 3, burst(zapfilter.NewLeakyBucketLimiterWithClock(10, 3, clock).Filter, 100))
+	require.Equal(t, 0, burst(zapfilter.NewLeakyBucketLimiterWithClock(10, 0, clock).Filter, 100))
+	require.Equal(t, 0, burst(zapfilter.LeakyBucket(10, 0), 100))
+
+	limiter := zapfilter.NewLeakyBucketLimiterWithClock(20, 1, clock)
+	entry := zapcore.Entry{Level: zapcore.InfoLevel}
+	require.True(t, limiter.Filter(entry, nil)) // check phase never fills the bucket
+	require.True(t, limiter.Filter(entry, []zapcore.Field{}))
+	require.False(t, limiter.Filter(entry, []zapcore.Field{}))
+	require.Equal(t, uint64(1), limiter.Dropped())
+
+	// leaked after 1/20s
+	now = now.Add(50 * time.Millisecond)
+	require.True(t, limiter.Filter(entry, []zapcore.Field{}))
+	require.Equal(t, uint64(1), limiter.Dropped())
+}
+
+func TestLimiters_clock(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1600000000, 0)
+	clock := func() time.Time { return now }
+	entry := zapcore.Entry{Level: zapcore.InfoLevel}
+	record := func(filter zapfilter.FilterFunc, n int) []bool {
+		entries := make([]zapcore.Entry, n)
+		for i := range entries {
+			entries[i] = entry
+		}
+		return zapfiltertest.Record(filter, entries)
+	}
+
+	levelLimiter := zapfilter.NewLevelRateLimiterWithClock(map[zapcore.Level]int{zapcore.InfoLevel: 2}, clock)
+	globalLimiter := zapfilter.NewGlobalRateLimiterWithClock(2, 2, clock)
+	for _, filter := range []zapfilter.FilterFunc{levelLimiter.Filter, globalLimiter.Filter} {
+		require.Equal(t, []bool{true, true, false}, record(filter, 3))
+	}
+	now = now.Add(500 * time.Millisecond) // one token refilled
+	for _, filter := range []zapfilter.FilterFunc{levelLimiter.Filter, globalLimiter.Filter} {
+		require.Equal(t, []bool{true, false}, record(filter, 2))
+	}
+	now = now.Add(time.Hour) // refilled up to the burst
+	for _, filter := range []zapfilter.FilterFunc{levelLimiter.Filter, globalLimiter.Filter} {
+		require.Equal(t, []bool{true, true, false}, record(filter, 3))
+	}
+
+	leaky := zapfilter.NewLeakyBucketLimiterWithClock(10, 1, clock)
+	require.Equal(t, []bool{true, false}, record(leaky.Filter, 2))
+	now = now.Add(50 * time.Millisecond) // half leaked
+	require.Equal(t, []bool{false}, record(leaky.Filter, 1))
+	now = now.Add(50 * time.Millisecond)
+	require.Equal(t, []bool{true, false}, record(leaky.Filter, 2))
+	require.Equal(t, zapfilter.FilterStats{Passed: 2, Dropped: 3}, leaky.Stats())
+}
@@ -0,0 +1,249 @@
+package zapfilter
+
+import (
+	"math/rand"
+
+	"go.uber.org/multierr"
+	"go.uber.org/zap/zapcore"
+)
+
+// WeightedRoute associates a core with its share of the traffic.
+type WeightedRoute struct {
+	Weight int
+	Core   zapcore.Core
+}
+
+// NewWeightedCore returns a core that sends each entry to exactly one of the routes,
+// chosen randomly proportionally to its weight.
+//
+// Routes with a weight of zero (or less) never receive entries.
+//
+// The route is picked using the global math/rand source, see NewWeightedCoreWithRand
+// to get a deterministic distribution in tests.
+func NewWeightedCore(routes []WeightedRoute) zapcore.Core {
+	return newWeightedCore(routes, rand.Intn)
+}
+
+// NewWeightedCoreWithRand is like NewWeightedCore, but picks the routes using r, i.e.,
+// rand.New(rand.NewSource(42)) for a deterministic distribution. The core serializes its
+// calls to r, which must not be used elsewhere.
+func NewWeightedCoreWithRand(routes []WeightedRoute, r *rand.Rand) zapcore.Core {
+	return newWeightedCore(routes, (&lockedRand{rand: r}).Intn)
+}
+
+func newWeightedCore(routes []WeightedRoute, intn func(int) int) zapcore.Core {
+	core := &weightedCore{intn: intn}
+	for _, route := range routes {
+		if route.Weight <= 0 || route.Core == nil {
+			continue
+		}
+		core.routes = append(core.routes, route)
+		core.total += route.Weight
+	}
+	return core
+}
+
+type weightedCore struct {
+	routes []WeightedRoute
+	total  int
+	intn   func(int) int
+}
+
+// pick randomly selects a core, or returns nil if there is no route.
+func (core *weightedCore) pick() zapcore.Core {
+	if core.total == 0 {
+		return nil
+	}
+	n := core.intn(core.total)
+	for _, route := range core.routes {
+		if n < route.Weight {
+			return route.Core
+		}
+		n -= route.Weight
+	}
+	return nil // unreachable
+}
+
+// Check picks a route and lets its core decide whether the supplied zapcore.Entry
+// should be logged.
+func (core *weightedCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if next := core.pick(); next != nil {
+		return next.Check(entry, ce)
+	}
+	return ce
+}
+
+// Write picks a route and writes the zapcore.Entry to its core.
+func (core *weightedCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	if next := core.pick(); next != nil {
+		return next.Write(entry, fields)
+	}
+	return nil
+}
+
+// With adds structured context to every route.
+func (core *weightedCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := &weightedCore{
+		routes: make([]WeightedRoute, len(core.routes)),
+		total:  core.total,
+		intn:   core.intn,
+	}
+	for i, route := range core.routes {
+		clone.routes[i] = WeightedRoute{Weight: route.Weight, Core: route.Core.With(fields)}
+	}
+	return clone
+}
+
+// Enabled returns true if at least one route is enabled for the given level.
+func (core *weightedCore) Enabled(level zapcore.Level) bool {
+	for _, route := range core.routes {
+		if route.Core.Enabled(level) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sync flushes every route.
+func (core *weightedCore) Sync() error {
+	var err error
+	for _, route := range core.routes {
+		err = multierr.Append(err, route.Core.Sync())
+	}
+	return err
+}
+
+// NewFilteringTee returns a core evaluating filter once per entry, then writing the
+// entries it matches to every core enabling their level.
+//
+// Contrary to wrapping a zapcore.NewTee with NewFilteringCore, the level of each core
+// is respected, and contrary to wrapping each core, stateful filters see each entry
+// once and every core gets the same decision.
+func NewFilteringTee(filter FilterFunc, cores ...zapcore.Core) zapcore.Core {
+	return NewFilteringCore(teeCore(cores), filter)
+}
+
+// teeCore writes entries to every core enabling their level.
+type teeCore []zapcore.Core
+
+// Check adds every core enabling the level of the entry.
+func (tee teeCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if tee.Enabled(entry.Level) {
+		ce = ce.AddCore(entry, tee)
+	}
+	return ce
+}
+
+// Write writes the entry to every core enabling its level.
+func (tee teeCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	var err error
+	for _, core := range tee {
+		if core.Enabled(entry.Level) {
+			err = multierr.Append(err, core.Write(entry, fields))
+		}
+	}
+	return err
+}
+
+// With adds structured context to every core.
+func (tee teeCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := make(teeCore, len(tee))
+	for i, core := range tee {
+		clone[i] = core.With(fields)
+	}
+	return clone
+}
+
+// Enabled returns true if at least one core is enabled for the given level.
+func (tee teeCore) Enabled(level zapcore.Level) bool {
+	for _, core := range tee {
+		if core.Enabled(level) {
+			return true
+		}
+	}
+	return false
+}
+
+// Sync flushes every core.
+func (tee teeCore) Sync() error {
+	var err error
+	for _, core := range tee {
+		err = multierr.Append(err, core.Sync())
+	}
+	return err
+}
+
+// NewFilteringCoreWithFallback returns a core writing the entries matched by filter to
+// primary, and the other entries to fallback instead of dropping them, i.e., to send
+// some namespaces to a detailed sink and everything else to a coarse one.
+//
+// Like with NewFilteringCore, the fields added with With are passed to the filter, and
+// each destination only receives the levels it enables.
+func NewFilteringCoreWithFallback(primary, fallback zapcore.Core, filter FilterFunc) zapcore.Core {
+	if filter == nil {
+		filter = alwaysFalseFilter
+	}
+	return &fallbackCore{primary: primary, fallback: fallback, filter: filter, ignoresFields: ignoresFields(filter)}
+}
+
+type fallbackCore struct {
+	primary  zapcore.Core
+	fallback zapcore.Core
+	filter   FilterFunc
+
+	// fields added with With, passed to the filter before the fields of each entry,
+	// unless it ignores the fields
+	filterContext []zapcore.Field
+	ignoresFields bool
+}
+
+// Check lets fallback decide for the entries the filter drops; the others are decided
+// by Write, once their fields are known.
+func (core *fallbackCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !core.filter(entry, nil) {
+		return core.fallback.Check(entry, ce)
+	}
+	if core.Enabled(entry.Level) {
+		ce = ce.AddCore(entry, core)
+	}
+	return ce
+}
+
+// Write writes the entry to primary if the filter matches it, else to fallback.
+func (core *fallbackCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	if fields == nil {
+		// nil fields are reserved to the Check phase.
+		fields = []zapcore.Field{}
+	}
+	filterFields := fields
+	if len(core.filterContext) > 0 && !core.ignoresFields {
+		filterFields = append(core.filterContext[:len(core.filterContext):len(core.filterContext)], fields...)
+	}
+	next := core.fallback
+	if core.filter(entry, filterFields) {
+		next = core.primary
+	}
+	if !next.Enabled(entry.Level) {
+		return nil
+	}
+	return next.Write(entry, fields)
+}
+
+// With adds structured context to both destinations.
+func (core *fallbackCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *core
+	clone.primary = core.primary.With(fields)
+	clone.fallback = core.fallback.With(fields)
+	clone.filterContext = append(core.filterContext[:len(core.filterContext):len(core.filterContext)], fields...)
+	return &clone
+}
+
+// Enabled returns true if at least one destination is enabled for the given level.
+func (core *fallbackCore) Enabled(level zapcore.Level) bool {
+	return core.primary.Enabled(level) || core.fallback.Enabled(level)
+}
+
+// Sync flushes both destinations.
+func (core *fallbackCore) Sync() error {
+	return multierr.Append(core.primary.Sync(), core.fallback.Sync())
+}
@@ -0,0 +1,149 @@
+package zapfilter_test
+
+import (
+	"errors"
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/multierr"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"moul.io/zapfilter"
+)
+
+func TestNewWeightedCore(t *testing.T) {
+	t.Parallel()
+
+	stable, stableLogs := observer.New(zapcore.DebugLevel)
+	canary, canaryLogs := observer.New(zapcore.DebugLevel)
+	disabled, disabledLogs := observer.New(zapcore.DebugLevel)
+	core := zapfilter.NewWeightedCore([]zapfilter.WeightedRoute{
+		{Weight: 9, Core: stable},
+		{Weight: 1, Core: canary},
+		{Weight: 0, Core: disabled},
+	})
+	logger := zap.New(core).With(zap.String("lorem", "ipsum"))
+
+	const total = 10000
+	for i := 0; i < total; i++ {
+		logger.Info("hello")
+	}
+
+	require.Equal(t, total, stableLogs.Len()+canaryLogs.Len())
+	require.InDelta(t, total/10, canaryLogs.Len(), total/50)
+	require.Equal(t, 0, disabledLogs.Len())
+	require.Equal(t, []zapcore.Field{zap.String("lorem", "ipsum")}, canaryLogs.All()[0].Context)
+	require.NoError(t, logger.Sync())
+}
+
+func TestNewWeightedCoreWithRand(t *testing.T) {
+	t.Parallel()
+
+	// the messages routed to the first of two even routes
+	route := func(seed int64) []string {
+		a, aLogs := observer.New(zapcore.DebugLevel)
+		b, _ := observer.New(zapcore.DebugLevel)
+		core := zapfilter.NewWeightedCoreWithRand([]zapfilter.WeightedRoute{
+			{Weight: 1, Core: a},
+			{Weight: 1, Core: b},
+		}, rand.New(rand.NewSource(seed)))
+		logger := zap.New(core)
+		for i := 0; i < 100; i++ {
+			logger.Info(strconv.Itoa(i))
+		}
+
+		messages := []string{}
+		for _, log := range aLogs.All() {
+			messages = append(messages, log.Message)
+		}
+		return messages
+	}
+	require.Equal(t, route(42), route(42))
+	require.NotEqual(t, route(42), route(43))
+	require.InDelta(t, 50, len(route(42)), 20)
+}
+
+func TestNewWeightedCore_empty(t *testing.T) {
+	core := zapfilter.NewWeightedCore(nil)
+	require.False(t, core.Enabled(zapcore.ErrorLevel))
+	require.NoError(t, core.Write(zapcore.Entry{Message: "dropped"}, nil))
+	require.Nil(t, core.Check(zapcore.Entry{Message: "dropped"}, nil))
+}
+
+func TestNewFilteringTee(t *testing.T) {
+	t.Parallel()
+
+	verbose, verboseLogs := observer.New(zapcore.DebugLevel)
+	quiet, quietLogs := observer.New(zapcore.WarnLevel)
+	sampler := zapfilter.NewEveryN(2)
+	logger := zap.New(zapfilter.NewFilteringTee(sampler.Filter, verbose, quiet)).With(zap.String("foo", "bar"))
+
+	logger.Debug("a")
+	logger.Debug("b")
+	logger.Warn("c")
+	logger.Warn("d")
+	logger.Error("e")
+
+	messages := func(logs *observer.ObservedLogs) []string {
+		gotLogs := []string{}
+		for _, log := range logs.All() {
+			gotLogs = append(gotLogs, log.Message)
+		}
+		return gotLogs
+	}
+	// the filter sees each entry once, and the cores get the same decisions
+	require.Equal(t, []string{"a", "c", "e"}, messages(verboseLogs))
+	require.Equal(t, []string{"c", "e"}, messages(quietLogs))
+	require.Equal(t, zapfilter.FilterStats{Passed: 3, Dropped: 2}, sampler.Stats())
+	require.Equal(t, map[string]interface{}{"foo": "bar"}, quietLogs.All()[0].ContextMap())
+
+	tee := zapfilter.NewFilteringTee(zapfilter.AllowAll(), failingSyncCore{quiet}, verbose, failingSyncCore{quiet})
+	require.True(t, tee.Enabled(zapcore.DebugLevel))
+	require.Len(t, multierr.Errors(tee.Sync()), 2)
+	require.False(t, zapfilter.NewFilteringTee(zapfilter.AllowAll(), quiet).Enabled(zapcore.InfoLevel))
+}
+
+type failingSyncCore struct {
+	zapcore.Core
+}
+
+func (failingSyncCore) Sync() error {
+	return errors.New("sync failed")
+}
+
+func TestNewFilteringCoreWithFallback(t *testing.T) {
+	t.Parallel()
+
+	primary, primaryLogs := observer.New(zapcore.DebugLevel)
+	fallback, fallbackLogs := observer.New(zapcore.InfoLevel)
+	filter := zapfilter.Any(zapfilter.MustParseRules("debug+:api.*"), zapfilter.ByFieldValue("tenant", "acme"))
+	logger := zap.New(zapfilter.NewFilteringCoreWithFallback(primary, fallback, filter))
+
+	logger.Named("api.users").Debug("a")
+	logger.Named("api.users").Info("b")
+	logger.Named("db").Debug("c") // dropped by the level of fallback
+	logger.Named("db").Info("d")
+	logger.Named("db").Info("e", zap.String("tenant", "acme"))
+	logger.Named("db").With(zap.String("tenant", "acme")).Debug("f")
+	logger.Named("db").With(zap.String("tenant", "other")).Warn("g")
+
+	messages := func(logs *observer.ObservedLogs) []string {
+		gotLogs := []string{}
+		for _, log := range logs.All() {
+			gotLogs = append(gotLogs, log.Message)
+		}
+		return gotLogs
+	}
+	require.Equal(t, []string{"a", "b", "e", "f"}, messages(primaryLogs))
+	require.Equal(t, []string{"d", "g"}, messages(fallbackLogs))
+	require.Equal(t, map[string]interface{}{"tenant": "other"}, fallbackLogs.All()[1].ContextMap())
+
+	quiet, _ := observer.New(zapcore.WarnLevel)
+	core := zapfilter.NewFilteringCoreWithFallback(failingSyncCore{quiet}, failingSyncCore{quiet}, nil)
+	require.False(t, core.Enabled(zapcore.InfoLevel))
+	require.True(t, core.Enabled(zapcore.WarnLevel))
+	require.Len(t, multierr.Errors(core.Sync()), 2)
+}
@@ -0,0 +1,610 @@
+package zapfilter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Rules is the compiled form of a set of rules, see ParseRules for the syntax.
+type Rules struct {
+	input   string
+	rules   []Rule
+	negated bool
+	base    FilterFunc // matches the rules, ignoring negated
+	filter  FilterFunc
+}
+
+// Rule is a single LEVELS:NAMESPACES clause of Rules.
+type Rule struct {
+	Levels     LevelSet
+	Namespaces []string // patterns, see ByNamespaces
+	Exclude    bool     // subtracts the entries it matches, i.e., "-debug:vendor.*"
+	Stacktrace bool     // only matches the entries with a stacktrace, i.e., "stack:error:*"
+}
+
+// stackFlag is the prefix of the rules only matching the entries with a stacktrace.
+const stackFlag = "stack:"
+
+// String returns the rule using the ParseRules syntax.
+func (r Rule) String() string {
+	rule := LevelSetToken(r.Levels) + ":" + strings.Join(r.Namespaces, ",")
+	if r.Stacktrace {
+		rule = stackFlag + rule
+	}
+	if r.Exclude {
+		return "-" + rule
+	}
+	return rule
+}
+
+// CompileRules parses rules like ParseRules, but returns their compiled representation.
+//
+// Each call builds its own filters, and each rule owns its state, i.e., its namespace
+// cache: parsing the same rules twice, or repeating a rule, never makes two filters
+// share state.
+func CompileRules(pattern string) (*Rules, error) {
+	// rules are separated by spaces, tabs or \n
+	return compileRules(pattern, strings.Fields(pattern))
+}
+
+// ParseRulesSep is like ParseRules, but the rules are separated by sep instead of
+// whitespace, i.e., "info:api.*;error:*" with ';', so namespace patterns can contain
+// spaces. Whitespace around each rule is ignored.
+func ParseRulesSep(pattern string, sep rune) (FilterFunc, error) {
+	rules, err := compileRules(pattern, strings.Split(pattern, string(sep)))
+	if err != nil {
+		return nil, err
+	}
+	return rules.FilterFunc(), nil
+}
+
+func compileRules(pattern string, tokens []string) (*Rules, error) {
+	parsed, err := parseRuleTokens(tokens)
+	if err != nil {
+		return nil, err
+	}
+	rules := &Rules{input: pattern, rules: parsed}
+	rules.compile()
+	return rules, nil
+}
+
+// parseRuleTokens parses each LEVELS:NAMESPACES rule, see ParseRules.
+func parseRuleTokens(tokens []string) ([]Rule, error) {
+	var rules []Rule
+	for _, token := range tokens {
+		// split rule into parts (separated by ':')
+		token = strings.TrimSpace(token)
+		if token == "" {
+			continue
+		}
+		// a leading '-' subtracts a whole LEVELS:NAMESPACES rule, whereas "-foo" alone
+		// is a namespace exclusion and "-2:*" a negative level
+		exclude := strings.HasPrefix(token, "-") && strings.Contains(token, ":") && !startsWithDigit(token[1:])
+		if exclude {
+			token = token[1:]
+		}
+		// a leading "stack:" flag only matches the entries with a stacktrace
+		stacktrace := strings.HasPrefix(token, stackFlag)
+		if stacktrace {
+			token = token[len(stackFlag):]
+			if token == "" {
+				return nil, fmt.Errorf("bad syntax")
+			}
+		}
+		parts := strings.Split(token, ":")
+		var left string
+		var namespaces []string
+		switch len(parts) {
+		case 1:
+			// if no separator, left stays empty
+			namespaces = strings.Split(parts[0], ",")
+		default:
+			// further ':' separated clauses extend the namespaces, i.e.,
+			// "info:api.*:-api.health" is "info:api.*,-api.health"
+			for _, part := range parts {
+				if part == "" {
+					return nil, fmt.Errorf("bad syntax")
+				}
+			}
+			left = parts[0]
+			for _, clause := range parts[1:] {
+				namespaces = append(namespaces, strings.Split(clause, ",")...)
+			}
+		}
+
+		levels, err := parseLevels(left)
+		if err != nil {
+			return nil, err
+		}
+		// a list of empty patterns, i.e., "info:,", would silently match nothing
+		empty := true
+		for _, namespace := range namespaces {
+			if namespace == "-" {
+				return nil, fmt.Errorf("bad syntax")
+			}
+			if namespace != "" {
+				empty = false
+			}
+		}
+		if empty {
+			return nil, fmt.Errorf("bad syntax")
+		}
+		rules = append(rules, Rule{
+			Levels:     levels,
+			Namespaces: namespaces,
+			Exclude:    exclude,
+			Stacktrace: stacktrace,
+		})
+	}
+	return rules, nil
+}
+
+// compile builds the filters matching the rules.
+func (r *Rules) compile() {
+	var includes, excludes []Rule
+	for _, rule := range r.rules {
+		if rule.Exclude {
+			excludes = append(excludes, rule)
+		} else {
+			includes = append(includes, rule)
+		}
+	}
+
+	info := filterInfo{desc: describeCall("ParseRules", fmt.Sprintf("%q", r.input)), levels: r.levels(), ignoresFields: true}
+	switch {
+	case len(includes) == 0:
+		r.base = describe(info, alwaysFalseFilter)
+	case len(includes) == 1 && len(excludes) == 0:
+		// fast path for the most common configurations, i.e., "info:*" or "debug:mypkg.*"
+		r.base = describe(info, singleRuleFilter(includes[0]))
+	default:
+		topFilter := rulesFilter(includes)
+		if len(excludes) > 0 {
+			topFilter = All(topFilter, subtractFilter(excludes))
+		}
+		info.op, info.children = opAll, []FilterFunc{topFilter}
+		r.base = describe(info, topFilter)
+	}
+	r.filter = r.base
+}
+
+// rulesFilter returns a filter matching any of the rules.
+func rulesFilter(rules []Rule) FilterFunc {
+	var filter FilterFunc
+	for _, rule := range rules {
+		namespaceFilter := ByNamespaces(strings.Join(rule.Namespaces, ","))
+		if rule.Stacktrace {
+			filter = Any(filter, All(byLevelSet(rule.Levels), namespaceFilter, HasStacktrace()))
+			continue
+		}
+		filter = Any(filter, All(byLevelSet(rule.Levels), namespaceFilter))
+	}
+	return filter
+}
+
+// subtractFilter returns a filter dropping the entries matched by any of the
+// subtracting rules.
+//
+// The stacktrace is unknown during Check, so the "stack:" rules only subtract from
+// Write.
+func subtractFilter(rules []Rule) FilterFunc {
+	var plain, stacktrace []Rule
+	for _, rule := range rules {
+		if rule.Stacktrace {
+			stacktrace = append(stacktrace, rule)
+		} else {
+			plain = append(plain, rule)
+		}
+	}
+	var filter FilterFunc
+	if len(plain) > 0 {
+		filter = Reverse(rulesFilter(plain))
+	}
+	if len(stacktrace) > 0 {
+		matched := rulesFilter(stacktrace)
+		filter = All(filter, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+			return isCheckPhase(fields) || !matched(entry, fields)
+		})
+	}
+	return filter
+}
+
+// singleRuleFilter returns a flat filter for a rule, without the indirections of the
+// All and Any combinators.
+func singleRuleFilter(rule Rule) FilterFunc {
+	levels := rule.Levels
+	matcher := newNamespaceMatcher(rule.Namespaces)
+	var filter FilterFunc
+	if matcher.alwaysMatch() {
+		filter = func(entry zapcore.Entry, fields []zapcore.Field) bool {
+			return levels.Has(entry.Level)
+		}
+	} else {
+		namespaces := matcher.cachedFilter()
+		filter = func(entry zapcore.Entry, fields []zapcore.Field) bool {
+			return levels.Has(entry.Level) && namespaces(entry, fields)
+		}
+	}
+	if rule.Stacktrace {
+		matched := filter
+		filter = func(entry zapcore.Entry, fields []zapcore.Field) bool {
+			return matched(entry, fields) && hasStacktrace(entry, fields)
+		}
+	}
+	return filter
+}
+
+// ParseRulesInverse is like ParseRules, but the returned filter matches every entry
+// the rules don't match.
+func ParseRulesInverse(pattern string) (FilterFunc, error) {
+	rules, err := CompileRules(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return rules.Not().FilterFunc(), nil
+}
+
+// ParseRulesAll is like ParseRules, but an entry must match every rule to pass, i.e.,
+// to narrow the rules progressively: "info+:* api.*" matches the entries of level info
+// or above AND of namespaces matching 'api.*'.
+//
+// Subtracting rules still remove the entries they match. Without rules, no entry
+// passes, like with ParseRules.
+func ParseRulesAll(pattern string) (FilterFunc, error) {
+	rules, err := CompileRules(pattern)
+	if err != nil {
+		return nil, err
+	}
+	var includes, excludes []Rule
+	for _, rule := range rules.rules {
+		if rule.Exclude {
+			excludes = append(excludes, rule)
+		} else {
+			includes = append(includes, rule)
+		}
+	}
+	if len(includes) == 0 {
+		info := filterInfo{desc: describeCall("ParseRulesAll", fmt.Sprintf("%q", pattern)), levels: &LevelSet{}, ignoresFields: true}
+		return describe(info, alwaysFalseFilter), nil
+	}
+
+	filters := make([]FilterFunc, 0, len(includes)+1)
+	for _, rule := range includes {
+		filters = append(filters, singleRuleFilter(rule))
+	}
+	if len(excludes) > 0 {
+		filters = append(filters, subtractFilter(excludes))
+	}
+	topFilter := All(filters...)
+	levels := allLevelSet
+	for _, rule := range includes {
+		levels = levels.Intersect(rule.Levels)
+	}
+	for _, rule := range excludes {
+		if !rule.Stacktrace && newNamespaceMatcher(rule.Namespaces).alwaysMatch() {
+			levels = levels.Difference(rule.Levels)
+		}
+	}
+	info := filterInfo{
+		desc:          describeCall("ParseRulesAll", fmt.Sprintf("%q", pattern)),
+		levels:        &levels,
+		ignoresFields: true,
+		op:            opAll,
+		children:      []FilterFunc{topFilter},
+	}
+	return describe(info, topFilter), nil
+}
+
+// QuickRules is like ParseRules, but matches the namespace patterns for each entry
+// instead of memoizing the decisions, without any cache nor mutex, like
+// ByNamespacesNoCache.
+//
+// It is meant for the short-lived cores, i.e., one per request, which are discarded
+// before a cache would pay off: building the filter only parses the rules. Prefer
+// ParseRules for long-lived cores, which log many entries with the same namespaces.
+func QuickRules(pattern string) (FilterFunc, error) {
+	parsed, err := parseRuleTokens(strings.Fields(pattern))
+	if err != nil {
+		return nil, err
+	}
+	type quickRule struct {
+		levels     LevelSet
+		matcher    namespaceMatcher
+		always     bool // matches every namespace
+		stacktrace bool
+	}
+	var includes, excludes []quickRule
+	for _, rule := range parsed {
+		if strings.Join(rule.Namespaces, ",") == "" {
+			continue // matches nothing, like ByNamespaces("")
+		}
+		matcher := newNamespaceMatcher(rule.Namespaces)
+		quick := quickRule{levels: rule.Levels, matcher: matcher, always: matcher.alwaysMatch(), stacktrace: rule.Stacktrace}
+		if rule.Exclude {
+			excludes = append(excludes, quick)
+		} else {
+			includes = append(includes, quick)
+		}
+	}
+	info := filterInfo{desc: describeCall("QuickRules", fmt.Sprintf("%q", pattern)), levels: (&Rules{rules: parsed}).levels(), ignoresFields: true}
+	if len(includes) == 0 {
+		return describe(info, alwaysFalseFilter), nil
+	}
+
+	matches := func(rule quickRule, entry zapcore.Entry, fields []zapcore.Field) bool {
+		return rule.levels.Has(entry.Level) &&
+			(rule.always || rule.matcher.match(entry.LoggerName)) &&
+			(!rule.stacktrace || hasStacktrace(entry, fields))
+	}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		for _, rule := range excludes {
+			if rule.stacktrace && isCheckPhase(fields) {
+				continue // only subtracts from Write
+			}
+			if matches(rule, entry, fields) {
+				return false
+			}
+		}
+		for _, rule := range includes {
+			if matches(rule, entry, fields) {
+				return true
+			}
+		}
+		return false
+	}), nil
+}
+
+// Not returns rules matching every entry the rules don't match.
+//
+// Contrary to wrapping the filter with Reverse, the levels of the resulting filter
+// stay known, i.e., for EffectiveMinLevel: only the levels enabled for every namespace
+// by the original rules are excluded.
+func (r *Rules) Not() *Rules {
+	inverse := &Rules{
+		input:   r.input,
+		rules:   r.rules,
+		negated: !r.negated,
+		base:    r.base,
+	}
+	if !inverse.negated {
+		inverse.filter = r.base
+		return inverse
+	}
+
+	info := filterInfo{desc: describeCall("Not", Describe(r.base)), levels: inverse.levels(), ignoresFields: true}
+	base := r.base
+	// the "stack:" rules can only be decided from Write
+	deferred := false
+	for _, rule := range r.rules {
+		deferred = deferred || rule.Stacktrace
+	}
+	inverse.filter = describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		if deferred && isCheckPhase(fields) {
+			return true
+		}
+		return !base(entry, fields)
+	})
+	return inverse
+}
+
+// FilterFunc returns the filter matching the entries selected by the rules.
+func (r *Rules) FilterFunc() FilterFunc {
+	return r.filter
+}
+
+// Decompose returns the clauses of the rules, in order, i.e., to display the active
+// policy. It doesn't reflect Not.
+func (r *Rules) Decompose() []Rule {
+	rules := make([]Rule, len(r.rules))
+	for i, rule := range r.rules {
+		rules[i] = Rule{
+			Levels:     rule.Levels,
+			Namespaces: append([]string(nil), rule.Namespaces...),
+			Exclude:    rule.Exclude,
+			Stacktrace: rule.Stacktrace,
+		}
+	}
+	return rules
+}
+
+// String returns the rules as they were written, it doesn't reflect Not.
+func (r *Rules) String() string {
+	return r.input
+}
+
+// levels returns a superset of the levels that can pass the rules.
+func (r *Rules) levels() *LevelSet {
+	var included, alwaysIncluded, excluded, alwaysExcluded LevelSet
+	for _, rule := range r.rules {
+		alwaysMatch := !rule.Stacktrace && newNamespaceMatcher(rule.Namespaces).alwaysMatch()
+		switch {
+		case rule.Exclude:
+			excluded = excluded.Union(rule.Levels)
+			if alwaysMatch {
+				alwaysExcluded = alwaysExcluded.Union(rule.Levels)
+			}
+		default:
+			included = included.Union(rule.Levels)
+			if alwaysMatch {
+				alwaysIncluded = alwaysIncluded.Union(rule.Levels)
+			}
+		}
+	}
+
+	if r.negated {
+		// levels enabled for every namespace can't pass
+		levels := allLevelSet.Difference(alwaysIncluded.Difference(excluded))
+		return &levels
+	}
+	levels := included.Difference(alwaysExcluded)
+	return &levels
+}
+
+// MergeRules combines several sets of rules, i.e., base rules, environment overrides
+// and a per-request override, where later sets take precedence over earlier ones.
+//
+// A set of rules covers a namespace when one of its namespace patterns, include or
+// exclude, matches it. An entry is decided by the last set covering its namespace,
+// the other sets are ignored; it is dropped if no set covers it. For example, with
+// "info:*" then "error:noisy", info entries of noisy are dropped while info entries
+// of other namespaces pass.
+func MergeRules(patterns ...string) (FilterFunc, error) {
+	type layer struct {
+		covers FilterFunc
+		filter FilterFunc
+	}
+	layers := make([]layer, 0, len(patterns))
+	filters := make([]FilterFunc, 0, len(patterns))
+	quoted := make([]string, len(patterns))
+	for i, pattern := range patterns {
+		quoted[i] = fmt.Sprintf("%q", pattern)
+		rules, err := CompileRules(pattern)
+		if err != nil {
+			return nil, err
+		}
+		var namespaces []string
+		for _, rule := range rules.rules {
+			for _, namespace := range rule.Namespaces {
+				namespaces = append(namespaces, strings.TrimPrefix(namespace, "-"))
+			}
+		}
+		matcher := newNamespaceMatcher(namespaces)
+		if len(matcher.patterns) == 0 {
+			continue // covers nothing
+		}
+		layers = append(layers, layer{covers: matcher.cachedFilter(), filter: rules.FilterFunc()})
+		filters = append(filters, rules.FilterFunc())
+	}
+
+	info := filterInfo{desc: describeCall("MergeRules", quoted...), levels: anyFiltersLevels(filters), ignoresFields: true}
+	if len(layers) == 0 {
+		return describe(info, alwaysFalseFilter), nil
+	}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		for i := len(layers) - 1; i >= 0; i-- {
+			if layers[i].covers(entry, fields) {
+				return layers[i].filter(entry, fields)
+			}
+		}
+		return false
+	}), nil
+}
+
+// RulesEqual returns true if both patterns select the same entries, even when written
+// differently, i.e., "info,info:* info:*" and "info:*".
+//
+// The comparison is done on the decomposed rules: the rules having the same namespace
+// patterns, in any order, are merged by level. Rules which can't match are ignored.
+// It is conservative: equivalent rules split differently over the namespaces, like
+// "info:a,b" and "info:a info:b", are reported as different.
+func RulesEqual(a, b string) (bool, error) {
+	rulesA, err := CompileRules(a)
+	if err != nil {
+		return false, err
+	}
+	rulesB, err := CompileRules(b)
+	if err != nil {
+		return false, err
+	}
+
+	normalizedA, normalizedB := normalizeRules(rulesA.rules), normalizeRules(rulesB.rules)
+	if len(normalizedA) != len(normalizedB) {
+		return false, nil
+	}
+	for namespaces, levels := range normalizedA {
+		if other, found := normalizedB[namespaces]; !found || other != levels {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// MatchingNamespaces returns the candidates, in order, whose entries of the given level
+// pass the rules, i.e., for a configuration UI to preview the loggers affected by
+// rules. Use "" for the root logger.
+//
+// The entries are decided like by Check, without fields nor stacktrace, so the
+// "stack:" rules are assumed to match.
+func MatchingNamespaces(rules string, candidates []string, level zapcore.Level) ([]string, error) {
+	filter, err := ParseRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	matching := []string{}
+	for _, namespace := range candidates {
+		if filter(zapcore.Entry{Level: level, LoggerName: namespace}, nil) {
+			matching = append(matching, namespace)
+		}
+	}
+	return matching, nil
+}
+
+// normalizeRules merges the levels of the rules by namespace patterns, sorted and
+// deduplicated.
+func normalizeRules(rules []Rule) map[string]LevelSet {
+	normalized := map[string]LevelSet{}
+	for _, rule := range rules {
+		if rule.Levels.IsEmpty() {
+			continue
+		}
+		key := "*"
+		matcher := newNamespaceMatcher(rule.Namespaces)
+		if !matcher.alwaysMatch() {
+			seen := map[string]bool{}
+			patterns := []string{}
+			for _, pattern := range rule.Namespaces {
+				if pattern == "" || seen[pattern] {
+					continue
+				}
+				seen[pattern] = true
+				patterns = append(patterns, pattern)
+			}
+			sort.Strings(patterns)
+			key = strings.Join(patterns, ",")
+		}
+		if rule.Stacktrace {
+			key = stackFlag + key
+		}
+		if rule.Exclude {
+			key = "-" + key
+		}
+		normalized[key] = normalized[key].Union(rule.Levels)
+	}
+	return normalized
+}
+
+// ParseRulesStrict is like ParseRules, but also rejects the rules that are certainly
+// mistakes, i.e., a copy-paste gone wrong:
+//
+//   - a clause including and excluding the same pattern, like "info:foo,-foo"
+//   - a clause with no level
+func ParseRulesStrict(pattern string) (FilterFunc, error) {
+	rules, err := CompileRules(pattern)
+	if err != nil {
+		return nil, err
+	}
+	for _, rule := range rules.rules {
+		if rule.Levels.IsEmpty() {
+			return nil, fmt.Errorf("useless rule %q: no level", rule)
+		}
+		includes := map[string]bool{}
+		for _, namespace := range rule.Namespaces {
+			if namespace != "" && namespace[0] != '-' {
+				includes[namespace] = true
+			}
+		}
+		for _, namespace := range rule.Namespaces {
+			if strings.HasPrefix(namespace, "-") && includes[namespace[1:]] {
+				return nil, fmt.Errorf("useless rule %q: %q is both included and excluded", rule, namespace[1:])
+			}
+		}
+	}
+	return rules.FilterFunc(), nil
+}
+
+func startsWithDigit(s string) bool {
+	return s != "" && s[0] >= '0' && s[0] <= '9'
+}
@@ -0,0 +1,641 @@
+package zapfilter_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"moul.io/zapfilter"
+	"moul.io/zapfilter/zapfiltertest"
+)
+
+func TestRulesNot(t *testing.T) {
+	t.Parallel()
+
+	entries := []zapcore.Entry{
+		zapfiltertest.Entry(zapcore.DebugLevel, "foo", ""),
+		zapfiltertest.Entry(zapcore.InfoLevel, "foo", ""),
+		zapfiltertest.Entry(zapcore.InfoLevel, "bar", ""),
+		zapfiltertest.Entry(zapcore.ErrorLevel, "bar", ""),
+		zapfiltertest.Entry(zapcore.PanicLevel, "bar", ""),
+		zapfiltertest.Entry(zapcore.FatalLevel, "foo", ""),
+	}
+	cases := []struct {
+		rules    string
+		expected []bool
+	}{
+		{"info:foo", []bool{true, false, true, true, true, true}},
+		{"error+:*", []bool{true, true, true, false, false, false}},
+		{"debug,info,warn,error,dpanic:*", []bool{false, false, false, false, true, true}},
+		{"*", []bool{false, false, false, false, false, false}},
+		{"", []bool{true, true, true, true, true, true}},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.rules, func(t *testing.T) {
+			t.Parallel()
+
+			rules, err := zapfilter.CompileRules(tc.rules)
+			require.NoError(t, err)
+			inverse := rules.Not()
+			require.Equal(t, tc.expected, zapfiltertest.Record(inverse.FilterFunc(), entries))
+
+			// the inverse of the inverse matches the original rules
+			original := zapfiltertest.Record(rules.FilterFunc(), entries)
+			require.Equal(t, original, zapfiltertest.Record(inverse.Not().FilterFunc(), entries))
+
+			filter, err := zapfilter.ParseRulesInverse(tc.rules)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, zapfiltertest.Record(filter, entries))
+		})
+	}
+}
+
+func TestRulesNot_levels(t *testing.T) {
+	t.Parallel()
+
+	builtins := []zapcore.Level{
+		zapcore.DebugLevel, zapcore.InfoLevel, zapcore.WarnLevel, zapcore.ErrorLevel,
+		zapcore.DPanicLevel, zapcore.PanicLevel, zapcore.FatalLevel,
+	}
+	cases := []struct {
+		rules    string
+		expected []zapcore.Level // built-in levels that can pass the inverse
+	}{
+		{"info:foo", builtins},
+		{"info+:*", []zapcore.Level{zapcore.DebugLevel}},
+		{"debug,info,warn,error,dpanic:*", []zapcore.Level{zapcore.PanicLevel, zapcore.FatalLevel}},
+		{"error+:*,-foo", builtins},
+		{"*", nil},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.rules, func(t *testing.T) {
+			t.Parallel()
+
+			filter, err := zapfilter.ParseRulesInverse(tc.rules)
+			require.NoError(t, err)
+			for _, level := range builtins {
+				_, found := zapfilter.EffectiveMinLevel(zapfilter.All(zapfilter.ExactLevel(level), filter))
+				require.Equal(t, containsLevel(tc.expected, level), found, level.String())
+			}
+
+			// custom levels are only matched by the original rules covering every level
+			_, found := zapfilter.EffectiveMinLevel(zapfilter.All(zapfilter.ExactLevel(zapcore.Level(-2)), filter))
+			require.Equal(t, tc.rules != "*", found)
+		})
+	}
+	require.Equal(t, `Not(ParseRules("info:foo"))`, zapfilter.Describe(mustParseRulesInverse("info:foo")))
+}
+
+func TestParseRulesAll(t *testing.T) {
+	t.Parallel()
+
+	entries := []zapcore.Entry{
+		zapfiltertest.Entry(zapcore.DebugLevel, "api.users", ""),
+		zapfiltertest.Entry(zapcore.InfoLevel, "api.users", ""),
+		zapfiltertest.Entry(zapcore.InfoLevel, "api.health", ""),
+		zapfiltertest.Entry(zapcore.InfoLevel, "db", ""),
+		zapfiltertest.Entry(zapcore.ErrorLevel, "db", ""),
+	}
+	cases := []struct {
+		rules       string
+		expectedAny []bool // ParseRules
+		expectedAll []bool // ParseRulesAll
+	}{
+		{"info+:* api.*", []bool{true, true, true, true, true}, []bool{false, true, true, false, false}},
+		{"info+:* api.* *:-api.health", []bool{true, true, true, true, true}, []bool{false, true, false, false, false}},
+		{"info+:* *:-api.health -error:*", []bool{true, true, true, true, false}, []bool{false, true, false, true, false}},
+		{"debug:* info:*", []bool{true, true, true, true, false}, []bool{false, false, false, false, false}},
+		{"info:api.*", []bool{false, true, true, false, false}, []bool{false, true, true, false, false}},
+		{"", []bool{false, false, false, false, false}, []bool{false, false, false, false, false}},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.rules, func(t *testing.T) {
+			t.Parallel()
+
+			anyFilter, err := zapfilter.ParseRules(tc.rules)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedAny, zapfiltertest.Record(anyFilter, entries))
+
+			allFilter, err := zapfilter.ParseRulesAll(tc.rules)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedAll, zapfiltertest.Record(allFilter, entries))
+		})
+	}
+
+	filter, err := zapfilter.ParseRulesAll("info+:* api.*")
+	require.NoError(t, err)
+	require.Equal(t, `ParseRulesAll("info+:* api.*")`, zapfilter.Describe(filter))
+	min, found := zapfilter.EffectiveMinLevel(filter)
+	require.True(t, found)
+	require.Equal(t, zapcore.InfoLevel, min)
+
+	_, err = zapfilter.ParseRulesAll("invalid:*")
+	require.EqualError(t, err, `unsupported keyword: "invalid"`)
+}
+
+func containsLevel(levels []zapcore.Level, level zapcore.Level) bool {
+	for _, candidate := range levels {
+		if candidate == level {
+			return true
+		}
+	}
+	return false
+}
+
+// everyLevel returns the levels matched by "*", custom ones included.
+func everyLevel() zapfilter.LevelSet {
+	var set zapfilter.LevelSet
+	for level := math.MinInt8; level <= math.MaxInt8; level++ {
+		set.Add(zapcore.Level(level))
+	}
+	return set
+}
+
+func mustParseRulesInverse(pattern string) zapfilter.FilterFunc {
+	filter, err := zapfilter.ParseRulesInverse(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return filter
+}
+
+func TestRulesDecompose(t *testing.T) {
+	t.Parallel()
+
+	rules, err := zapfilter.CompileRules("*:foo debug:foo.* info,warn:bar error+:*,-baz")
+	require.NoError(t, err)
+	require.Equal(t, []zapfilter.Rule{
+		{
+			Levels:     everyLevel(),
+			Namespaces: []string{"foo"},
+		},
+		{Levels: zapfilter.NewLevelSet(zapcore.DebugLevel), Namespaces: []string{"foo.*"}},
+		{Levels: zapfilter.NewLevelSet(zapcore.InfoLevel, zapcore.WarnLevel), Namespaces: []string{"bar"}},
+		{
+			Levels:     zapfilter.NewLevelSet(zapcore.ErrorLevel, zapcore.DPanicLevel, zapcore.PanicLevel, zapcore.FatalLevel),
+			Namespaces: []string{"*", "-baz"},
+		},
+	}, rules.Decompose())
+
+	// the result is a copy
+	rules.Decompose()[1].Namespaces[0] = "mutated"
+	require.Equal(t, []string{"foo.*"}, rules.Decompose()[1].Namespaces)
+}
+
+func TestQuickRules(t *testing.T) {
+	t.Parallel()
+
+	var entries []zapcore.Entry
+	for _, namespace := range []string{"", "foo", "foo.bar", "foo.baz", "bar", "vendor.grpc"} {
+		for _, level := range []zapcore.Level{zapcore.Level(-2), zapcore.DebugLevel, zapcore.InfoLevel, zapcore.WarnLevel, zapcore.ErrorLevel, zapcore.FatalLevel} {
+			entries = append(entries, zapfiltertest.Entry(level, namespace, ""))
+		}
+	}
+	inputs := []string{
+		"",
+		"*",
+		"info:*",
+		"info+:foo*,-foo.bar",
+		"debug:foo.* error+:*",
+		"* -debug:vendor.* -info:-vendor.*",
+		"info:foo*:-foo.baz warn:<root>",
+		"-2+:foo.*",
+		"*:foo, info:bar",
+		"info:foo,-foo",
+		"stack:error+:*",
+		"* -stack:*",
+	}
+	for _, input := range inputs {
+		input := input
+		t.Run(input, func(t *testing.T) {
+			t.Parallel()
+
+			expected, err := zapfilter.ParseRules(input)
+			require.NoError(t, err)
+			filter, err := zapfilter.QuickRules(input)
+			require.NoError(t, err)
+			require.Equal(t, zapfiltertest.Record(expected, entries), zapfiltertest.Record(filter, entries))
+
+			expectedMin, expectedFound := zapfilter.EffectiveMinLevel(expected)
+			min, found := zapfilter.EffectiveMinLevel(filter)
+			require.Equal(t, expectedFound, found)
+			require.Equal(t, expectedMin, min)
+		})
+	}
+
+	filter, err := zapfilter.QuickRules("info:*")
+	require.NoError(t, err)
+	require.Equal(t, `QuickRules("info:*")`, zapfilter.Describe(filter))
+	_, err = zapfilter.QuickRules("invalid:*")
+	require.EqualError(t, err, `unsupported keyword: "invalid"`)
+	_, err = zapfilter.QuickRules("*:,")
+	require.EqualError(t, err, `bad syntax`)
+}
+
+func TestMatchingNamespaces(t *testing.T) {
+	t.Parallel()
+
+	candidates := []string{"", "api", "api.users", "api.health", "db", "vendor.grpc"}
+	cases := []struct {
+		rules    string
+		level    zapcore.Level
+		expected []string
+	}{
+		{"info:api.*,-api.health", zapcore.InfoLevel, []string{"api.users"}},
+		{"info:api.*,-api.health", zapcore.DebugLevel, []string{}},
+		{"debug:api* error+:*", zapcore.DebugLevel, []string{"api", "api.users", "api.health"}},
+		{"debug:api* error+:*", zapcore.ErrorLevel, candidates},
+		{"* -*:vendor.*", zapcore.WarnLevel, []string{"", "api", "api.users", "api.health", "db"}},
+		{"info:<root>,db", zapcore.InfoLevel, []string{"", "db"}},
+		{"stack:error:db", zapcore.ErrorLevel, []string{"db"}},
+		{"", zapcore.InfoLevel, []string{}},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.rules+"@"+tc.level.String(), func(t *testing.T) {
+			t.Parallel()
+
+			matching, err := zapfilter.MatchingNamespaces(tc.rules, candidates, tc.level)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, matching)
+		})
+	}
+
+	_, err := zapfilter.MatchingNamespaces("invalid:*", candidates, zapcore.InfoLevel)
+	require.EqualError(t, err, `unsupported keyword: "invalid"`)
+}
+
+func TestParseRules_stacktrace(t *testing.T) {
+	t.Parallel()
+
+	rules, err := zapfilter.CompileRules("stack:error+:api.* -stack:warn:* stack:* stack")
+	require.NoError(t, err)
+	require.Equal(t, []string{"stack:error+:api.*", "-stack:warn:*", "stack:*:*", "*:stack"}, rulesStrings(rules))
+
+	for _, input := range []string{"stack:", "-stack:", "stack::*", "stack:invalid:*"} {
+		_, err := zapfilter.ParseRules(input)
+		require.Error(t, err, input)
+	}
+
+	cases := []struct {
+		rules        string
+		expectedLogs []string
+	}{
+		{"stack:error+:*", []string{"api-error-stack", "db-error-stack"}},
+		{"stack:error+:api.*", []string{"api-error-stack"}},
+		{"stack:*", []string{"api-info-stack", "api-warn-stack", "api-error-stack", "db-error-stack"}},
+		{"warn+:* -stack:warn:*", []string{"api-error-stack", "api-error", "db-error-stack"}},
+		{"warn+:* -stack:*", []string{"api-error"}},
+		{"stack:error:*:-db", []string{"api-error-stack"}},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.rules, func(t *testing.T) {
+			t.Parallel()
+
+			next, logs := observer.New(zapcore.DebugLevel)
+			logger := zap.New(zapfilter.NewFilteringCore(next, zapfilter.MustParseRules(tc.rules)))
+			withStack := logger.WithOptions(zap.AddStacktrace(zapcore.DebugLevel))
+
+			withStack.Named("api.users").Info("api-info-stack")
+			withStack.Named("api.users").Warn("api-warn-stack")
+			withStack.Named("api.users").Error("api-error-stack")
+			logger.Named("api.users").Error("api-error")
+			withStack.Named("db").Error("db-error-stack")
+
+			gotLogs := []string{}
+			for _, log := range logs.All() {
+				gotLogs = append(gotLogs, log.Message)
+			}
+			require.Equal(t, tc.expectedLogs, gotLogs)
+		})
+	}
+
+	// the stacktrace is unknown during Check
+	inverse, err := zapfilter.ParseRulesInverse("stack:error:*")
+	require.NoError(t, err)
+	entry := zapfiltertest.Entry(zapcore.ErrorLevel, "api", "oops")
+	require.True(t, inverse(entry, nil))
+	require.True(t, inverse(entry, []zapcore.Field{}))
+	entry.Stack = "goroutine 1 [running]:"
+	require.False(t, inverse(entry, []zapcore.Field{}))
+}
+
+func rulesStrings(rules *zapfilter.Rules) []string {
+	parts := []string{}
+	for _, rule := range rules.Decompose() {
+		parts = append(parts, rule.String())
+	}
+	return parts
+}
+
+func TestRulesDecompose_roundTrip(t *testing.T) {
+	t.Parallel()
+
+	inputs := []string{
+		"",
+		"*",
+		"info:*",
+		"info,warn:myns.* error+:*",
+		"*:foo debug:foo.* info,warn:bar error:*",
+		"info:test,foo*,-foo.foo",
+		"panic+:* dpanic:a,b,c",
+		"* -debug:vendor.* -info:-vendor.api",
+		"stack:error+:api.* * -stack:warn:*",
+		"-2:* -2+:api.* 3+:db",
+		"-3,error+,10:api.* --3:vendor.*",
+		"[debug,-1,100+]:*",
+	}
+	for _, input := range inputs {
+		rules, err := zapfilter.CompileRules(input)
+		require.NoError(t, err)
+
+		parts := []string{}
+		for _, rule := range rules.Decompose() {
+			parts = append(parts, rule.String())
+		}
+		recompiled, err := zapfilter.CompileRules(strings.Join(parts, " "))
+		require.NoError(t, err, input)
+		require.Equal(t, rules.Decompose(), recompiled.Decompose(), input)
+	}
+	require.Equal(t, "info,warn:myns.*", mustCompileRules("info,warn:myns.*").Decompose()[0].String())
+	require.Equal(t, "-2:*", mustCompileRules("-2:*").Decompose()[0].String())
+	require.Equal(t, "-2+:*", mustCompileRules("-2+:*").Decompose()[0].String())
+	require.Equal(t, "--3:vendor.*", mustCompileRules("* --3:vendor.*").Decompose()[1].String())
+	_, err := zapfilter.ParseRules(mustCompileRules("-2+:*").Decompose()[0].String())
+	require.NoError(t, err)
+}
+
+func mustCompileRules(pattern string) *zapfilter.Rules {
+	rules, err := zapfilter.CompileRules(pattern)
+	if err != nil {
+		panic(err)
+	}
+	return rules
+}
+
+func BenchmarkParseRules_singleRule(b *testing.B) {
+	chained := func(levels, namespaces string) zapfilter.FilterFunc {
+		levelFilter, err := zapfilter.ByLevels(levels)
+		if err != nil {
+			b.Fatal(err)
+		}
+		return zapfilter.Any(nil, zapfilter.All(levelFilter, zapfilter.ByNamespaces(namespaces)))
+	}
+	entry := zapcore.Entry{Level: zapcore.DebugLevel, LoggerName: "mypkg.foo"}
+
+	b.Run("compile/chained", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			chained("debug", "mypkg.*")
+		}
+	})
+	b.Run("compile/flat", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			mustCompileRules("debug:mypkg.*")
+		}
+	})
+	for _, rules := range []string{"info:*", "debug:mypkg.*"} {
+		parts := strings.SplitN(rules, ":", 2)
+		filters := map[string]zapfilter.FilterFunc{
+			"chained": chained(parts[0], parts[1]),
+			"flat":    zapfilter.MustParseRules(rules),
+		}
+		for _, name := range []string{"chained", "flat"} {
+			filter := filters[name]
+			b.Run("eval/"+rules+"/"+name, func(b *testing.B) {
+				b.ReportAllocs()
+				for i := 0; i < b.N; i++ {
+					filter(entry, nil)
+				}
+			})
+		}
+	}
+}
+
+func TestMergeRules(t *testing.T) {
+	t.Parallel()
+
+	filter, err := zapfilter.MergeRules("info:*", "error:noisy,noisy.*", "", "debug:noisy.important")
+	require.NoError(t, err)
+	require.Equal(t, `MergeRules("info:*", "error:noisy,noisy.*", "", "debug:noisy.important")`, zapfilter.Describe(filter))
+
+	zapfiltertest.AssertPasses(t, filter,
+		zapfiltertest.Entry(zapcore.InfoLevel, "api", "base"),
+		zapfiltertest.Entry(zapcore.ErrorLevel, "noisy", "first override"),
+		zapfiltertest.Entry(zapcore.ErrorLevel, "noisy.foo", "first override"),
+		zapfiltertest.Entry(zapcore.DebugLevel, "noisy.important", "last override"),
+	)
+	zapfiltertest.AssertDrops(t, filter,
+		zapfiltertest.Entry(zapcore.DebugLevel, "api", "base"),
+		// the override suppresses the base include
+		zapfiltertest.Entry(zapcore.InfoLevel, "noisy", "first override"),
+		zapfiltertest.Entry(zapcore.WarnLevel, "noisy.foo", "first override"),
+	)
+
+	// excludes cover namespaces too
+	filter, err = zapfilter.MergeRules("debug:*", "*:-secret")
+	require.NoError(t, err)
+	zapfiltertest.AssertPasses(t, filter, zapfiltertest.Entry(zapcore.DebugLevel, "api", "hello"))
+	zapfiltertest.AssertDrops(t, filter, zapfiltertest.Entry(zapcore.ErrorLevel, "secret", "hello"))
+
+	// nothing covered
+	filter, err = zapfilter.MergeRules()
+	require.NoError(t, err)
+	zapfiltertest.AssertDrops(t, filter, zapfiltertest.Entry(zapcore.ErrorLevel, "api", "hello"))
+
+	_, err = zapfilter.MergeRules("info:*", ":bad")
+	require.Error(t, err)
+}
+
+func TestRulesEqual(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		a, b     string
+		expected bool
+	}{
+		{"info:*", "info:*", true},
+		{"info,info:* info:*", "info:*", true},
+		{"info:a debug:a", "debug,info:a", true},
+		{"info:a,b", "info:b,a,a", true},
+		{"info:*,foo", "info:*", true},
+		{"info:a warn:b", "warn:b\ninfo:a", true},
+		{"", "", true},
+		{"info:*", "info:*,-foo", false},
+		{"info:*", "warn:*", false},
+		{"info:a", "info:a debug:b", false},
+	}
+	for _, tc := range cases {
+		equal, err := zapfilter.RulesEqual(tc.a, tc.b)
+		require.NoError(t, err)
+		require.Equal(t, tc.expected, equal, "%q vs %q", tc.a, tc.b)
+	}
+
+	_, err := zapfilter.RulesEqual("info:*", ":bad")
+	require.Error(t, err)
+}
+
+func TestParseRulesStrict(t *testing.T) {
+	t.Parallel()
+
+	for _, valid := range []string{"", "info:*", "info:foo.*,-foo.bar", "*:-foo debug:foo"} {
+		_, err := zapfilter.ParseRulesStrict(valid)
+		require.NoError(t, err, valid)
+	}
+
+	cases := []struct {
+		pattern  string
+		expected string
+	}{
+		{"info:foo,-foo", `useless rule "info:foo,-foo": "foo" is both included and excluded`},
+		{"debug:* info:a.*,b,-a.*", `useless rule "info:a.*,b,-a.*": "a.*" is both included and excluded`},
+		{"-foo,foo", `useless rule "*:-foo,foo": "foo" is both included and excluded`},
+	}
+	for _, tc := range cases {
+		_, err := zapfilter.ParseRulesStrict(tc.pattern)
+		require.EqualError(t, err, tc.expected, tc.pattern)
+
+		// ParseRules stays lenient
+		_, err = zapfilter.ParseRules(tc.pattern)
+		require.NoError(t, err, tc.pattern)
+	}
+
+	_, err := zapfilter.ParseRulesStrict(":bad")
+	require.Error(t, err)
+}
+
+func TestParseRules_subtract(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		pattern string
+		passes  []zapcore.Entry
+		drops   []zapcore.Entry
+	}{
+		{
+			"* -debug:vendor.*",
+			[]zapcore.Entry{
+				zapfiltertest.Entry(zapcore.DebugLevel, "api", "hello"),
+				zapfiltertest.Entry(zapcore.InfoLevel, "vendor.lib", "hello"),
+			},
+			[]zapcore.Entry{zapfiltertest.Entry(zapcore.DebugLevel, "vendor.lib", "hello")},
+		},
+		{
+			// the position doesn't matter
+			"-debug:vendor.* info+:* debug:vendor.*,api",
+			[]zapcore.Entry{
+				zapfiltertest.Entry(zapcore.DebugLevel, "api", "hello"),
+				zapfiltertest.Entry(zapcore.WarnLevel, "vendor.lib", "hello"),
+			},
+			[]zapcore.Entry{
+				zapfiltertest.Entry(zapcore.DebugLevel, "vendor.lib", "hello"),
+				zapfiltertest.Entry(zapcore.DebugLevel, "db", "hello"),
+			},
+		},
+		{
+			"info+:* -info,warn:* -*:noisy",
+			[]zapcore.Entry{zapfiltertest.Entry(zapcore.ErrorLevel, "api", "hello")},
+			[]zapcore.Entry{
+				zapfiltertest.Entry(zapcore.WarnLevel, "api", "hello"),
+				zapfiltertest.Entry(zapcore.ErrorLevel, "noisy", "hello"),
+			},
+		},
+		{
+			// nothing to subtract from
+			"-debug:vendor.*",
+			nil,
+			[]zapcore.Entry{zapfiltertest.Entry(zapcore.InfoLevel, "api", "hello")},
+		},
+		{
+			// without levels, a leading '-' is a namespace exclusion
+			"-vendor.*",
+			[]zapcore.Entry{zapfiltertest.Entry(zapcore.DebugLevel, "api", "hello")},
+			[]zapcore.Entry{zapfiltertest.Entry(zapcore.ErrorLevel, "vendor.lib", "hello")},
+		},
+	}
+	for _, tc := range cases {
+		filter := zapfilter.MustParseRules(tc.pattern)
+		zapfiltertest.AssertPasses(t, filter, tc.passes...)
+		zapfiltertest.AssertDrops(t, filter, tc.drops...)
+	}
+
+	rules := mustCompileRules("* -debug:vendor.*")
+	require.Equal(t, []zapfilter.Rule{
+		{Levels: everyLevel(), Namespaces: []string{"*"}},
+		{Levels: zapfilter.NewLevelSet(zapcore.DebugLevel), Namespaces: []string{"vendor.*"}, Exclude: true},
+	}, rules.Decompose())
+	require.Equal(t, "-debug:vendor.*", rules.Decompose()[1].String())
+
+	level, ok := zapfilter.EffectiveMinLevel(zapfilter.MustParseRules("debug+:* -debug:*"))
+	require.True(t, ok)
+	require.Equal(t, zapcore.InfoLevel, level)
+
+	_, err := zapfilter.ParseRules("* -:vendor")
+	require.Error(t, err)
+}
+
+func TestParseRulesSep(t *testing.T) {
+	t.Parallel()
+
+	filter, err := zapfilter.ParseRulesSep("info:api.* ; error:*;;debug:my service", ';')
+	require.NoError(t, err)
+	zapfiltertest.AssertPasses(t, filter,
+		zapfiltertest.Entry(zapcore.InfoLevel, "api.users", "hello"),
+		zapfiltertest.Entry(zapcore.ErrorLevel, "db", "hello"),
+		zapfiltertest.Entry(zapcore.DebugLevel, "my service", "hello"),
+	)
+	zapfiltertest.AssertDrops(t, filter,
+		zapfiltertest.Entry(zapcore.DebugLevel, "api.users", "hello"),
+		zapfiltertest.Entry(zapcore.DebugLevel, "my", "hello"),
+	)
+
+	filter, err = zapfilter.ParseRulesSep("debug:a|warn+:*", '|')
+	require.NoError(t, err)
+	zapfiltertest.AssertPasses(t, filter, zapfiltertest.Entry(zapcore.DebugLevel, "a", "hello"))
+	zapfiltertest.AssertDrops(t, filter, zapfiltertest.Entry(zapcore.InfoLevel, "b", "hello"))
+
+	_, err = zapfilter.ParseRulesSep("info:*;:bad", ';')
+	require.Error(t, err)
+}
+
+func TestCompileRules_independentState(t *testing.T) {
+	t.Parallel()
+
+	const rules = "debug:api.* debug:api.* warn+:*"
+	first, err := zapfilter.CompileRules(rules)
+	require.NoError(t, err)
+	second, err := zapfilter.CompileRules(rules)
+	require.NoError(t, err)
+
+	// two cores from the same rules, each with its own sampler
+	firstSampler, secondSampler := zapfilter.NewEveryN(2), zapfilter.NewEveryN(2)
+	firstFilter := zapfilter.All(first.FilterFunc(), firstSampler.Filter)
+	secondFilter := zapfilter.All(second.FilterFunc(), secondSampler.Filter)
+
+	entries := []zapcore.Entry{
+		zapfiltertest.Entry(zapcore.DebugLevel, "api.users", "hello"),
+		zapfiltertest.Entry(zapcore.DebugLevel, "api.users", "hello"),
+		zapfiltertest.Entry(zapcore.DebugLevel, "api.users", "hello"),
+		zapfiltertest.Entry(zapcore.DebugLevel, "db", "hello"),
+	}
+	require.Equal(t, []bool{true, false, true, false}, zapfiltertest.Record(firstFilter, entries))
+	require.Equal(t, []bool{true}, zapfiltertest.Record(secondFilter, entries[:1]))
+	require.Equal(t, zapfilter.FilterStats{Passed: 2, Dropped: 1}, firstSampler.Stats())
+	require.Equal(t, zapfilter.FilterStats{Passed: 1}, secondSampler.Stats())
+
+	// repeated rules are kept apart, and changing a decomposed rule changes nothing
+	decomposed := first.Decompose()
+	require.Len(t, decomposed, 3)
+	decomposed[0].Namespaces[0] = "db"
+	require.Equal(t, "debug:api.*", first.Decompose()[0].String())
+	require.Equal(t, []bool{true, false}, zapfiltertest.Record(second.FilterFunc(), entries[2:]))
+}
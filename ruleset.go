@@ -0,0 +1,93 @@
+package zapfilter
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// RuleSet holds rules that can be enabled and disabled individually at runtime, i.e.,
+// to turn on a verbose rule during an incident without resubmitting every rule.
+//
+// The filter is recompiled on each change and swapped atomically, so RuleSet.Filter
+// can be used concurrently with Enable and Disable.
+type RuleSet struct {
+	mutex   sync.Mutex
+	rules   []Rule
+	enabled []bool
+	filter  AtomicFilter
+}
+
+// NewRuleSet parses rules like ParseRules, every rule being enabled.
+func NewRuleSet(pattern string) (*RuleSet, error) {
+	rules, err := CompileRules(pattern)
+	if err != nil {
+		return nil, err
+	}
+	set := &RuleSet{
+		rules:   rules.Decompose(),
+		enabled: make([]bool, len(rules.rules)),
+	}
+	for i := range set.enabled {
+		set.enabled[i] = true
+	}
+	set.filter.Store(rules.FilterFunc())
+	return set, nil
+}
+
+// Enable enables the rule at index, as ordered in the pattern.
+func (s *RuleSet) Enable(index int) error {
+	return s.toggle(index, true)
+}
+
+// Disable disables the rule at index, as ordered in the pattern.
+func (s *RuleSet) Disable(index int) error {
+	return s.toggle(index, false)
+}
+
+func (s *RuleSet) toggle(index int, enabled bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if index < 0 || index >= len(s.rules) {
+		return fmt.Errorf("no rule at index %d (%d rules)", index, len(s.rules))
+	}
+	if s.enabled[index] == enabled {
+		return nil
+	}
+	s.enabled[index] = enabled
+
+	active := &Rules{}
+	tokens := []string{}
+	for i, rule := range s.rules {
+		if s.enabled[i] {
+			active.rules = append(active.rules, rule)
+			tokens = append(tokens, rule.String())
+		}
+	}
+	active.input = strings.Join(tokens, " ")
+	active.compile()
+	s.filter.Store(active.FilterFunc())
+	return nil
+}
+
+// Enabled returns true if the rule at index is enabled.
+func (s *RuleSet) Enabled(index int) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return index >= 0 && index < len(s.enabled) && s.enabled[index]
+}
+
+// Rules returns every rule of the set, enabled or not.
+func (s *RuleSet) Rules() []Rule {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return (&Rules{rules: s.rules}).Decompose()
+}
+
+// Filter is a FilterFunc matching the entries selected by the enabled rules.
+func (s *RuleSet) Filter(entry zapcore.Entry, fields []zapcore.Field) bool {
+	return s.filter.Filter(entry, fields)
+}
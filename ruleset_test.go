@@ -0,0 +1,49 @@
+package zapfilter_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"moul.io/zapfilter"
+	"moul.io/zapfilter/zapfiltertest"
+)
+
+func TestRuleSet(t *testing.T) {
+	t.Parallel()
+
+	set, err := zapfilter.NewRuleSet("warn+:* debug:api.* info:db")
+	require.NoError(t, err)
+
+	apiDebug := zapfiltertest.Entry(zapcore.DebugLevel, "api.users", "hello")
+	dbInfo := zapfiltertest.Entry(zapcore.InfoLevel, "db", "hello")
+	warn := zapfiltertest.Entry(zapcore.WarnLevel, "cache", "hello")
+
+	zapfiltertest.AssertPasses(t, set.Filter, apiDebug, dbInfo, warn)
+
+	require.NoError(t, set.Disable(1))
+	require.False(t, set.Enabled(1))
+	zapfiltertest.AssertPasses(t, set.Filter, dbInfo, warn)
+	zapfiltertest.AssertDrops(t, set.Filter, apiDebug)
+
+	require.NoError(t, set.Disable(0))
+	require.NoError(t, set.Disable(0)) // no-op
+	zapfiltertest.AssertPasses(t, set.Filter, dbInfo)
+	zapfiltertest.AssertDrops(t, set.Filter, apiDebug, warn)
+
+	require.NoError(t, set.Disable(2))
+	zapfiltertest.AssertDrops(t, set.Filter, apiDebug, dbInfo, warn)
+
+	require.NoError(t, set.Enable(1))
+	require.True(t, set.Enabled(1))
+	zapfiltertest.AssertPasses(t, set.Filter, apiDebug)
+	zapfiltertest.AssertDrops(t, set.Filter, dbInfo, warn)
+
+	require.EqualError(t, set.Enable(3), "no rule at index 3 (3 rules)")
+	require.Error(t, set.Disable(-1))
+	require.False(t, set.Enabled(3))
+	require.Len(t, set.Rules(), 3)
+
+	_, err = zapfilter.NewRuleSet(":bad")
+	require.Error(t, err)
+}
@@ -0,0 +1,89 @@
+package zapfilter
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ParseRulesFile reads rules from a file, see ParseRules for the syntax.
+//
+// Rules can be spread over several lines, and everything after a '#' is a comment. A
+// line like `@include base.rules` inserts the rules of another file, resolved relative
+// to the directory of the including file, so services can layer their own rules over
+// shared ones. Missing files and include cycles are reported as errors.
+func ParseRulesFile(filename string) (FilterFunc, error) {
+	pattern, err := readRulesFile(filename, nil)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRules(pattern)
+}
+
+// ParseRulesFromReader reads rules from r, with the same document syntax as
+// ParseRulesFile; includes are resolved relative to the working directory.
+func ParseRulesFromReader(r io.Reader) (FilterFunc, error) {
+	pattern, err := readRulesDocument(r, "<reader>", ".", nil)
+	if err != nil {
+		return nil, err
+	}
+	return ParseRules(pattern)
+}
+
+// readRulesFile reads a rules file and its includes; stack holds the absolute paths
+// of the files being included, to detect cycles.
+func readRulesFile(filename string, stack []string) (string, error) {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return "", err
+	}
+	for i, parent := range stack {
+		if parent == path {
+			cycle := append(stack[i:len(stack):len(stack)], path)
+			return "", fmt.Errorf("include cycle: %s", strings.Join(cycle, " -> "))
+		}
+	}
+
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	return readRulesDocument(f, filename, filepath.Dir(filename), append(stack[:len(stack):len(stack)], path))
+}
+
+// readRulesDocument strips the comments of a rules document and resolves its includes
+// relative to dir; name is used in error messages.
+func readRulesDocument(r io.Reader, name, dir string, stack []string) (string, error) {
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	for lineno := 1; scanner.Scan(); lineno++ {
+		line := scanner.Text()
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		tokens := strings.Fields(line)
+		if len(tokens) > 0 && tokens[0] == "@include" {
+			if len(tokens) != 2 {
+				return "", fmt.Errorf("%s:%d: @include expects exactly one file", name, lineno)
+			}
+			included := tokens[1]
+			if !filepath.IsAbs(included) {
+				included = filepath.Join(dir, included)
+			}
+			var err error
+			line, err = readRulesFile(included, stack)
+			if err != nil {
+				return "", fmt.Errorf("%s:%d: %w", name, lineno, err)
+			}
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return strings.Join(lines, "\n"), nil
+}
@@ -0,0 +1,77 @@
+package zapfilter_test
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"moul.io/zapfilter"
+	"moul.io/zapfilter/zapfiltertest"
+)
+
+func TestParseRulesFile(t *testing.T) {
+	t.Parallel()
+
+	filter, err := zapfilter.ParseRulesFile("testdata/include/service.rules")
+	require.NoError(t, err)
+
+	zapfiltertest.AssertPasses(t, filter,
+		zapfiltertest.Entry(zapcore.DebugLevel, "service.api", "own code"),
+		zapfiltertest.Entry(zapcore.InfoLevel, "http", "from common.rules"),
+		zapfiltertest.Entry(zapcore.WarnLevel, "db", "from base.rules"),
+	)
+	zapfiltertest.AssertDrops(t, filter,
+		zapfiltertest.Entry(zapcore.DebugLevel, "http", "hello"),
+		zapfiltertest.Entry(zapcore.InfoLevel, "db", "hello"),
+	)
+}
+
+func TestParseRulesFile_errors(t *testing.T) {
+	t.Parallel()
+
+	_, err := zapfilter.ParseRulesFile("testdata/include/not-found.rules")
+	require.True(t, errors.Is(err, os.ErrNotExist))
+
+	_, err = zapfilter.ParseRulesFile("testdata/include/missing.rules")
+	require.True(t, errors.Is(err, os.ErrNotExist))
+	require.Contains(t, err.Error(), "missing.rules:1: ")
+
+	_, err = zapfilter.ParseRulesFile("testdata/cycle/a.rules")
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "include cycle: ")
+	require.Regexp(t, `a\.rules -> .*b\.rules -> .*a\.rules$`, err.Error())
+}
+
+func TestParseRulesFromReader(t *testing.T) {
+	t.Parallel()
+
+	document := `
+# verbose for the api
+
+debug:api.*   # every level
+	error:*
+
+@include testdata/include/shared/common.rules
+`
+	filter, err := zapfilter.ParseRulesFromReader(strings.NewReader(document))
+	require.NoError(t, err)
+
+	zapfiltertest.AssertPasses(t, filter,
+		zapfiltertest.Entry(zapcore.DebugLevel, "api.users", "hello"),
+		zapfiltertest.Entry(zapcore.ErrorLevel, "db", "hello"),
+		zapfiltertest.Entry(zapcore.InfoLevel, "http", "hello"),
+	)
+	zapfiltertest.AssertDrops(t, filter,
+		zapfiltertest.Entry(zapcore.DebugLevel, "db", "hello"),
+		zapfiltertest.Entry(zapcore.WarnLevel, "verbose", "hello"),
+	)
+
+	_, err = zapfilter.ParseRulesFromReader(strings.NewReader("info:*\n@include\n"))
+	require.EqualError(t, err, "<reader>:2: @include expects exactly one file")
+
+	_, err = zapfilter.ParseRulesFromReader(strings.NewReader("info:*\n:bad\n"))
+	require.Error(t, err)
+}
@@ -0,0 +1,192 @@
+package zapfilter
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync/atomic"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// FilterStats counts the decisions taken by a stateful filter since its creation or
+// its last reset.
+type FilterStats struct {
+	Passed  uint64
+	Dropped uint64
+}
+
+// EveryN passes the first entry, then one entry every n written entries.
+//
+//   sampler := zapfilter.NewEveryN(10)
+//   core := zapfilter.NewFilteringCore(next, sampler.Filter)
+type EveryN struct {
+	count   uint64 // first fields to guarantee 64-bit alignment for atomic operations
+	passed  uint64
+	dropped uint64
+	n       uint64
+}
+
+// NewEveryN returns an EveryN sampler; with n <= 1, every entry passes.
+func NewEveryN(n int) *EveryN {
+	if n < 1 {
+		n = 1
+	}
+	return &EveryN{n: uint64(n)}
+}
+
+// Filter is a FilterFunc counting the written entries.
+func (s *EveryN) Filter(entry zapcore.Entry, fields []zapcore.Field) bool {
+	if isCheckPhase(fields) {
+		return true
+	}
+	if (atomic.AddUint64(&s.count, 1)-1)%s.n == 0 {
+		atomic.AddUint64(&s.passed, 1)
+		return true
+	}
+	atomic.AddUint64(&s.dropped, 1)
+	return false
+}
+
+// Reset zeroes the counter, so the next entry passes.
+func (s *EveryN) Reset() {
+	atomic.StoreUint64(&s.count, 0)
+	atomic.StoreUint64(&s.passed, 0)
+	atomic.StoreUint64(&s.dropped, 0)
+}
+
+// Stats returns the decisions taken since the creation of the sampler or its last
+// reset.
+func (s *EveryN) Stats() FilterStats {
+	return FilterStats{Passed: atomic.LoadUint64(&s.passed), Dropped: atomic.LoadUint64(&s.dropped)}
+}
+
+// RandomSample passes each written entry with the probability rate, i.e., 0.01 keeps
+// about 1% of the entries. Entries always pass with a rate >= 1, and never with a rate
+// <= 0.
+//
+// The entries are sampled using the global math/rand source, see RandomSampleWithRand
+// to get a deterministic sampling in tests. Check always passes, so each entry is only
+// sampled once, by Write.
+func RandomSample(rate float64) FilterFunc {
+	return randomSample(rate, rand.Float64)
+}
+
+// RandomSampleWithRand is like RandomSample, but samples the entries using r, i.e.,
+// rand.New(rand.NewSource(42)) for a deterministic sampling. The filter serializes its
+// calls to r, which must not be used elsewhere.
+func RandomSampleWithRand(rate float64, r *rand.Rand) FilterFunc {
+	return randomSample(rate, (&lockedRand{rand: r}).Float64)
+}
+
+func randomSample(rate float64, random func() float64) FilterFunc {
+	info := filterInfo{desc: describeCall("RandomSample", strconv.FormatFloat(rate, 'g', -1, 64)), levels: &allLevelSet, ignoresFields: true}
+	switch {
+	case rate >= 1:
+		return describe(info, alwaysTrueFilter)
+	case rate <= 0:
+		info.levels = &LevelSet{}
+		return describe(info, alwaysFalseFilter)
+	}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		return sample(rate, fields, random)
+	})
+}
+
+// sample returns the RandomSample decision for rate, drawing with random.
+func sample(rate float64, fields []zapcore.Field, random func() float64) bool {
+	switch {
+	case rate >= 1:
+		return true
+	case rate <= 0:
+		return false
+	case isCheckPhase(fields):
+		return true
+	}
+	return random() < rate
+}
+
+// namespaceRate is a pattern of SampleByNamespace.
+type namespaceRate struct {
+	matcher     namespaceMatcher
+	specificity int
+	rate        float64
+}
+
+// SampleByNamespace samples the entries with the rate of their namespace, like
+// RandomSample, i.e., to keep 1% of the vendor entries and every other entry:
+//
+//   zapfilter.SampleByNamespace(map[string]float64{"vendor.*": 0.01})
+//
+// The keys are ByNamespaces patterns, without the '-' prefix. When several patterns
+// match a namespace, the most specific one wins, like with ByNamespaces; on equal
+// specificity, the lowest rate wins. The entries of the namespaces matching no pattern
+// always pass. Malformed patterns match no namespace.
+//
+// The rate of each namespace is memoized, for up to 10000 namespaces. The entries are
+// sampled using the global math/rand source, see SampleByNamespaceWithRand to get a
+// deterministic sampling in tests.
+func SampleByNamespace(rates map[string]float64) FilterFunc {
+	return sampleByNamespace(rates, rand.Float64)
+}
+
+// SampleByNamespaceWithRand is like SampleByNamespace, but samples the entries using r,
+// like RandomSampleWithRand. The filter serializes its calls to r, which must not be
+// used elsewhere.
+func SampleByNamespaceWithRand(rates map[string]float64, r *rand.Rand) FilterFunc {
+	return sampleByNamespace(rates, (&lockedRand{rand: r}).Float64)
+}
+
+func sampleByNamespace(rates map[string]float64, random func() float64) FilterFunc {
+	patterns := make([]string, 0, len(rates))
+	for pattern := range rates {
+		patterns = append(patterns, pattern)
+	}
+	sort.Strings(patterns)
+	args := make([]string, 0, len(patterns))
+	candidates := make([]namespaceRate, 0, len(patterns))
+	for _, pattern := range patterns {
+		args = append(args, fmt.Sprintf("%q: %s", pattern, strconv.FormatFloat(rates[pattern], 'g', -1, 64)))
+		var matcher namespaceMatcher
+		matcher.add(pattern, false)
+		if len(matcher.patterns) == 0 {
+			continue
+		}
+		candidates = append(candidates, namespaceRate{
+			matcher:     matcher,
+			specificity: matcher.patterns[0].specificity,
+			rate:        rates[pattern],
+		})
+	}
+	info := filterInfo{desc: describeCall("SampleByNamespace", args...), levels: &allLevelSet, ignoresFields: true}
+	if len(candidates) == 0 {
+		return describe(info, alwaysTrueFilter)
+	}
+
+	rateOf := func(namespace string) float64 {
+		rate, best := 1.0, -1
+		for _, candidate := range candidates {
+			if candidate.specificity < best || (candidate.specificity == best && candidate.rate >= rate) {
+				continue // cannot change the rate
+			}
+			if candidate.matcher.match(namespace) {
+				rate, best = candidate.rate, candidate.specificity
+			}
+		}
+		return rate
+	}
+	cache := newBoundedCache(defaultMaxKeys)
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		var rate float64
+		cache.update(entry.LoggerName, func(value interface{}, found bool) interface{} {
+			if found {
+				rate = value.(float64)
+			} else {
+				rate = rateOf(entry.LoggerName)
+			}
+			return rate
+		})
+		return sample(rate, fields, random)
+	})
+}
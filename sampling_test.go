@@ -0,0 +1,133 @@
+package zapfilter_test
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"moul.io/zapfilter"
+	"moul.io/zapfilter/zapfiltertest"
+)
+
+func TestEveryN(t *testing.T) {
+	t.Parallel()
+
+	sampler := zapfilter.NewEveryN(3)
+	entry := zapfiltertest.Entry(zapcore.InfoLevel, "", "hello")
+	entries := []zapcore.Entry{entry, entry, entry, entry, entry}
+
+	require.Equal(t, []bool{true, false, false, true, false}, zapfiltertest.Record(sampler.Filter, entries))
+	require.Equal(t, zapfilter.FilterStats{Passed: 2, Dropped: 3}, sampler.Stats())
+
+	// reset mid-stream, the next entry passes
+	sampler.Reset()
+	require.Equal(t, zapfilter.FilterStats{}, sampler.Stats())
+	require.Equal(t, []bool{true, false, false, true}, zapfiltertest.Record(sampler.Filter, entries[:4]))
+	require.Equal(t, zapfilter.FilterStats{Passed: 2, Dropped: 2}, sampler.Stats())
+
+	require.Equal(t, []bool{true, true}, zapfiltertest.Record(zapfilter.NewEveryN(0).Filter, entries[:2]))
+}
+
+func TestStatefulFilters_reset(t *testing.T) {
+	t.Parallel()
+
+	entry := zapfiltertest.Entry(zapcore.InfoLevel, "", "hello")
+	entries := []zapcore.Entry{entry, entry, entry}
+
+	global := zapfilter.NewGlobalRateLimiter(0, 2)
+	require.Equal(t, []bool{true, true, false}, zapfiltertest.Record(global.Filter, entries))
+	require.Equal(t, zapfilter.FilterStats{Passed: 2, Dropped: 1}, global.Stats())
+	global.Reset()
+	require.Equal(t, zapfilter.FilterStats{}, global.Stats())
+	require.Equal(t, []bool{true, true, false}, zapfiltertest.Record(global.Filter, entries))
+
+	leaky := zapfilter.NewLeakyBucketLimiter(0, 1)
+	require.Equal(t, []bool{true, false, false}, zapfiltertest.Record(leaky.Filter, entries))
+	require.Equal(t, zapfilter.FilterStats{Passed: 1, Dropped: 2}, leaky.Stats())
+	leaky.Reset()
+	require.Equal(t, zapfilter.FilterStats{}, leaky.Stats())
+	require.Equal(t, []bool{true, false, false}, zapfiltertest.Record(leaky.Filter, entries))
+	require.Equal(t, uint64(2), leaky.Dropped())
+}
+
+func TestRandomSample(t *testing.T) {
+	t.Parallel()
+
+	entry := zapfiltertest.Entry(zapcore.InfoLevel, "", "hello")
+	sampler := zapfilter.RandomSample(0.1)
+	passed := 0
+	for i := 0; i < 10000; i++ {
+		require.True(t, sampler(entry, nil)) // Check always passes
+		if sampler(entry, []zapcore.Field{}) {
+			passed++
+		}
+	}
+	require.InDelta(t, 1000, passed, 300)
+
+	require.True(t, zapfilter.IsAlwaysTrue(zapfilter.RandomSample(1)))
+	require.True(t, zapfilter.IsAlwaysFalse(zapfilter.RandomSample(0)))
+	require.Equal(t, "RandomSample(0.1)", zapfilter.Describe(sampler))
+}
+
+func TestRandomSampleWithRand(t *testing.T) {
+	t.Parallel()
+
+	entry := zapfiltertest.Entry(zapcore.InfoLevel, "", "hello")
+	entries := make([]zapcore.Entry, 100)
+	for i := range entries {
+		entries[i] = entry
+	}
+	record := func(seed int64) []bool {
+		return zapfiltertest.Record(zapfilter.RandomSampleWithRand(0.5, rand.New(rand.NewSource(seed))), entries)
+	}
+	require.Equal(t, record(42), record(42))
+	require.NotEqual(t, record(42), record(43))
+
+	require.True(t, zapfilter.IsAlwaysTrue(zapfilter.RandomSampleWithRand(1, rand.New(rand.NewSource(42)))))
+	require.Equal(t, "RandomSample(0.5)", zapfilter.Describe(zapfilter.RandomSampleWithRand(0.5, rand.New(rand.NewSource(42)))))
+}
+
+func TestSampleByNamespace(t *testing.T) {
+	t.Parallel()
+
+	rates := map[string]float64{
+		"vendor.*":        0,
+		"vendor.critical": 1,   // more specific than vendor.*
+		"api.*":           0,   // same specificity as api?*, the lowest rate wins
+		"api?*":           0.5, // ...
+		"<root>":          0,
+		"debug.*":         0.1,
+		"malformed[":      0,
+	}
+	filter := zapfilter.SampleByNamespaceWithRand(rates, rand.New(rand.NewSource(42)))
+	entry := func(namespace string) zapcore.Entry {
+		return zapfiltertest.Entry(zapcore.InfoLevel, namespace, "hello")
+	}
+	zapfiltertest.AssertPasses(t, filter, entry("vendor.critical"), entry("db"), entry("vendorfoo"), entry("malformed["))
+	zapfiltertest.AssertDrops(t, filter, entry("vendor.foo"), entry("api.users"), entry(""))
+
+	// only the entries sampled with a rate between 0 and 1 draw from the source
+	source := rand.New(rand.NewSource(42))
+	for i := 0; i < 1000; i++ {
+		require.Equal(t, source.Float64() < 0.1, filter(entry("debug.foo"), []zapcore.Field{}))
+	}
+	require.True(t, filter(entry("debug.foo"), nil)) // Check never draws
+
+	record := func(seed int64) []bool {
+		entries := make([]zapcore.Entry, 100)
+		for i := range entries {
+			entries[i] = entry("api.users")
+		}
+		return zapfiltertest.Record(zapfilter.SampleByNamespaceWithRand(map[string]float64{"api.*": 0.5}, rand.New(rand.NewSource(seed))), entries)
+	}
+	require.Equal(t, record(42), record(42))
+	require.NotEqual(t, record(42), record(43))
+
+	// unlisted namespaces are kept
+	require.True(t, zapfilter.IsAlwaysTrue(zapfilter.SampleByNamespace(nil)))
+	require.Equal(t,
+		`SampleByNamespace("foo": 0.5, "vendor.*": 0.01)`,
+		zapfilter.Describe(zapfilter.SampleByNamespace(map[string]float64{"vendor.*": 0.01, "foo": 0.5})),
+	)
+}
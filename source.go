@@ -0,0 +1,113 @@
+package zapfilter
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// RuleSource provides rules, in the ParseRules syntax, that can change at runtime,
+// i.e., backed by a file, Consul, etcd or a Kubernetes ConfigMap.
+type RuleSource interface {
+	// Current returns the current rules.
+	Current() (string, error)
+
+	// Watch returns a channel receiving the new rules after each change, closed once
+	// ctx is done.
+	Watch(ctx context.Context) <-chan string
+}
+
+// WatchRules installs the current rules of source into target, then each update, until
+// ctx is done or the updates channel is closed. It is meant to run in its own goroutine:
+//
+//   filter := zapfilter.NewAtomicFilter(zapfilter.MustParseRules("info+:*"))
+//   go zapfilter.WatchRules(ctx, source, filter, func(err error) { log.Print(err) })
+//
+// Invalid updates are reported to onError, which may be nil, and target keeps the last
+// valid rules. If the current rules can't be read or parsed, the error is returned
+// right away and target is left untouched.
+func WatchRules(ctx context.Context, source RuleSource, target *AtomicFilter, onError func(error)) error {
+	current, err := source.Current()
+	if err != nil {
+		return err
+	}
+	filter, err := ParseRules(current)
+	if err != nil {
+		return fmt.Errorf("invalid rules: %w", err)
+	}
+	target.Store(filter)
+
+	updates := source.Watch(ctx)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case rules, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			filter, err := ParseRules(rules)
+			if err != nil {
+				if onError != nil {
+					onError(fmt.Errorf("invalid rules update, keeping the previous rules: %w", err))
+				}
+				continue
+			}
+			target.Store(filter)
+		}
+	}
+}
+
+// FileRuleSource is a RuleSource reading a rules file, with the syntax of
+// ParseRulesFile, and polling it for changes.
+type FileRuleSource struct {
+	filename string
+	interval time.Duration
+}
+
+// NewFileRuleSource returns a FileRuleSource polling filename every interval, every
+// second if interval <= 0.
+func NewFileRuleSource(filename string, interval time.Duration) *FileRuleSource {
+	if interval <= 0 {
+		interval = time.Second
+	}
+	return &FileRuleSource{filename: filename, interval: interval}
+}
+
+// Current reads the file and its includes.
+func (s *FileRuleSource) Current() (string, error) {
+	return readRulesFile(s.filename, nil)
+}
+
+// Watch polls the file, and sends its rules at the first poll, so no change is missed
+// since Current, then each time they change. Unreadable files are skipped until they
+// are readable again.
+func (s *FileRuleSource) Watch(ctx context.Context) <-chan string {
+	updates := make(chan string)
+	go func() {
+		defer close(updates)
+
+		var last string
+		sent := false
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			rules, err := s.Current()
+			if err != nil || (sent && rules == last) {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case updates <- rules:
+				last, sent = rules, true
+			}
+		}
+	}()
+	return updates
+}
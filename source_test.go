@@ -0,0 +1,112 @@
+package zapfilter_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"moul.io/zapfilter"
+	"moul.io/zapfilter/zapfiltertest"
+)
+
+// fakeSource is a RuleSource whose updates are sent by the test.
+type fakeSource struct {
+	current string
+	err     error
+	updates chan string
+}
+
+func (s *fakeSource) Current() (string, error)            { return s.current, s.err }
+func (s *fakeSource) Watch(context.Context) <-chan string { return s.updates }
+
+func TestWatchRules(t *testing.T) {
+	t.Parallel()
+
+	debug := zapfiltertest.Entry(zapcore.DebugLevel, "api", "hello")
+	info := zapfiltertest.Entry(zapcore.InfoLevel, "api", "hello")
+	warn := zapfiltertest.Entry(zapcore.WarnLevel, "api", "hello")
+
+	source := &fakeSource{current: "warn+:*", updates: make(chan string)}
+	filter := zapfilter.NewAtomicFilter(zapfilter.DenyAll())
+	var errs []error
+	done := make(chan error)
+	go func() {
+		done <- zapfilter.WatchRules(context.Background(), source, filter, func(err error) { errs = append(errs, err) })
+	}()
+
+	source.updates <- "info+:*"
+	source.updates <- "invalid:*" // the previous rules are kept
+	source.updates <- "info+:* [debug:api"
+	source.updates <- ""
+	close(source.updates)
+	require.NoError(t, <-done)
+
+	// the last update is valid, and matches nothing
+	zapfiltertest.AssertDrops(t, filter.Filter, debug, info, warn)
+	require.Len(t, errs, 2)
+	require.EqualError(t, errs[0], `invalid rules update, keeping the previous rules: unsupported keyword: "invalid"`)
+
+	// the updates in between were installed in order
+	source = &fakeSource{current: "warn+:*", updates: make(chan string)}
+	go func() {
+		done <- zapfilter.WatchRules(context.Background(), source, filter, nil)
+	}()
+	source.updates <- "info+:*"
+	source.updates <- "invalid:*"
+	close(source.updates)
+	require.NoError(t, <-done)
+	zapfiltertest.AssertPasses(t, filter.Filter, info, warn)
+	zapfiltertest.AssertDrops(t, filter.Filter, debug)
+}
+
+func TestWatchRules_invalidCurrent(t *testing.T) {
+	t.Parallel()
+
+	filter := zapfilter.NewAtomicFilter(zapfilter.AllowAll())
+	err := zapfilter.WatchRules(context.Background(), &fakeSource{current: "invalid:*"}, filter, nil)
+	require.EqualError(t, err, `invalid rules: unsupported keyword: "invalid"`)
+	err = zapfilter.WatchRules(context.Background(), &fakeSource{err: errors.New("unavailable")}, filter, nil)
+	require.EqualError(t, err, "unavailable")
+	require.True(t, zapfilter.IsAlwaysTrue(filter.Load()))
+
+	// canceling ctx stops watching
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = zapfilter.WatchRules(ctx, &fakeSource{current: "info:*", updates: make(chan string)}, filter, nil)
+	require.Equal(t, context.Canceled, err)
+}
+
+func TestFileRuleSource(t *testing.T) {
+	t.Parallel()
+
+	dir, err := ioutil.TempDir("", "zapfilter")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "service.rules")
+	require.NoError(t, ioutil.WriteFile(filename, []byte("warn+:* # quiet\n"), 0600))
+
+	source := zapfilter.NewFileRuleSource(filename, 10*time.Millisecond)
+	current, err := source.Current()
+	require.NoError(t, err)
+	require.Equal(t, "warn+:*", strings.TrimSpace(current))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	updates := source.Watch(ctx)
+	require.Equal(t, current, <-updates) // first poll
+
+	require.NoError(t, ioutil.WriteFile(filename, []byte("debug:api.*\n"), 0600))
+	require.Equal(t, "debug:api.*", strings.TrimSpace(<-updates))
+
+	cancel()
+	for range updates {
+		// drain until closed
+	}
+}
@@ -0,0 +1,34 @@
+package zapfilter
+
+import (
+	"go.uber.org/zap/zapcore"
+)
+
+// NewFilteringWriteSyncer returns a WriteSyncer only writing to next the formatted
+// lines for which f returns true, i.e., to tee a subset of the output to a secondary
+// file.
+//
+// Filtering cores are richer, as they see the level, the namespace and the fields;
+// filtering bytes is convenient when only the encoded output is available.
+func NewFilteringWriteSyncer(next zapcore.WriteSyncer, f func([]byte) bool) zapcore.WriteSyncer {
+	return &filteringWriteSyncer{next: next, f: f}
+}
+
+type filteringWriteSyncer struct {
+	next zapcore.WriteSyncer
+	f    func([]byte) bool
+}
+
+// Write writes p to the next WriteSyncer if it matches, else discards it while
+// reporting success.
+func (ws *filteringWriteSyncer) Write(p []byte) (int, error) {
+	if !ws.f(p) {
+		return len(p), nil
+	}
+	return ws.next.Write(p)
+}
+
+// Sync flushes the next WriteSyncer.
+func (ws *filteringWriteSyncer) Sync() error {
+	return ws.next.Sync()
+}
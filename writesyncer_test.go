@@ -0,0 +1,34 @@
+package zapfilter_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"moul.io/zapfilter"
+)
+
+func TestNewFilteringWriteSyncer(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	ws := zapfilter.NewFilteringWriteSyncer(zapcore.AddSync(&buf), func(line []byte) bool {
+		return bytes.Contains(line, []byte(`"audit":true`))
+	})
+	encoder := zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "msg", LineEnding: "\n"})
+	logger := zap.New(zapcore.NewCore(encoder, ws, zapcore.DebugLevel))
+
+	logger.Info("a")
+	logger.Info("b", zap.Bool("audit", true))
+	logger.Info("c", zap.Bool("audit", false))
+	require.NoError(t, logger.Sync())
+
+	require.Equal(t, `{"msg":"b","audit":true}`+"\n", buf.String())
+
+	n, err := ws.Write([]byte("dropped\n"))
+	require.NoError(t, err)
+	require.Equal(t, 8, n)
+	require.Equal(t, `{"msg":"b","audit":true}`+"\n", buf.String())
+}
@@ -2,24 +2,41 @@ package zapfilter
 
 import (
 	"fmt"
-	"path"
+	"os"
+	"strconv"
 	"strings"
-	"sync"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
 
 // FilterFunc is used to check whether to filter the given entry and filters out.
+//
+// A filtering core calls the filter twice per entry: once from Check, with nil fields,
+// and once from Write, with a non-nil (possibly empty) slice of fields. From Write, the
+// fields added to the logger with With come first, followed by the fields of the entry.
 type FilterFunc func(zapcore.Entry, []zapcore.Field) bool
 
 // NewFilteringCore returns a core middleware that uses the given filter function to
 // determine whether to actually call Write on the next core in the chain.
-func NewFilteringCore(next zapcore.Core, filter FilterFunc) zapcore.Core {
-	if filter == nil {
-		filter = alwaysFalseFilter
+func NewFilteringCore(next zapcore.Core, filter FilterFunc, opts ...Option) zapcore.Core {
+	core := &filteringCore{next: next, bypass: new(uint32)}
+	core.setFilter(filter)
+	for _, opt := range opts {
+		opt(core)
 	}
-	return &filteringCore{next, filter}
+	return core
+}
+
+// Option configures a filtering core created with NewFilteringCore.
+type Option func(*filteringCore)
+
+// NopFilteringCore is equivalent to NewFilteringCore(next, AllowAll()), i.e., to disable
+// filtering without restructuring code.
+//
+// It has no overhead: next is returned as is.
+func NopFilteringCore(next zapcore.Core) zapcore.Core {
+	return next
 }
 
 // CheckAnyLevel determines whether at least one log level isn't filtered-out by the logger.
@@ -35,14 +52,82 @@ func CheckAnyLevel(logger *zap.Logger) bool {
 	return false
 }
 
+// CheckAnyLevelIncludingFatal is like CheckAnyLevel, but also probes the dpanic, panic
+// and fatal levels.
+//
+// Probing is safe: nothing is logged, nor does the program panic or exit, because the
+// checked entries are never written.
+func CheckAnyLevelIncludingFatal(logger *zap.Logger) bool {
+	for _, level := range allLevels {
+		if checkCoreLevel(logger, level) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkCoreLevel determines whether the cores of the logger accept a specific level.
+//
+// Contrary to logger.Check alone, it isn't fooled by the entries zap always returns
+// for levels that may panic or exit.
+func checkCoreLevel(logger *zap.Logger, level zapcore.Level) bool {
+	ce := logger.Check(level, "")
+	if ce == nil {
+		return false
+	}
+	return logger.Core().Check(ce.Entry, nil) != nil
+}
+
 // CheckLevel determines whether a specific log level would produce log or not.
 func CheckLevel(logger *zap.Logger, level zapcore.Level) bool {
 	return logger.Check(level, "") != nil
 }
 
+// SetRules returns a copy of the logger filtered with the rules, see ParseRules; the
+// logger itself is left untouched, as zap loggers are immutable.
+//
+// If the core of the logger is a filtering core, its filter is replaced, keeping its
+// options, next core and fields; otherwise the core is wrapped with a new filtering
+// core. Invalid rules are returned as an error.
+//
+// The loggers already derived from the logger keep their filter; to change the rules
+// of every logger in place, build the core once with an AtomicFilter and Store the
+// new rules into it.
+func SetRules(logger *zap.Logger, rules string) (*zap.Logger, error) {
+	filter, err := ParseRules(rules)
+	if err != nil {
+		return nil, err
+	}
+	return logger.WithOptions(zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+		if filtering, ok := core.(*filteringCore); ok {
+			clone := *filtering
+			clone.setFilter(filter)
+			return &clone
+		}
+		return NewFilteringCore(core, filter)
+	})), nil
+}
+
 type filteringCore struct {
 	next   zapcore.Core
 	filter FilterFunc
+	levels *LevelSet // superset of the levels that can pass the filter, nil if unknown
+
+	// fields added with With, passed to the filter before the fields of each entry,
+	// unless it ignores the fields
+	filterContext []zapcore.Field
+	ignoresFields bool
+
+	// attribution
+	attributionKey string
+	explain        explainFunc
+	decisionField  bool
+
+	onPanic         func(interface{}) // set by WithRecover
+	contextOverride bool              // set by WithContextOverride
+	internal        bool              // set by With(Internal())
+	counters        *levelCounters    // set by WithCounters
+	bypass          *uint32           // set by SetBypass, shared by the derived cores
 }
 
 // Check determines whether the supplied zapcore.Entry should be logged.
@@ -51,8 +136,13 @@ type filteringCore struct {
 func (core *filteringCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
 	// FIXME: consider calling downstream core.Check too, but need to document how to
 	// properly set logging level.
-	if core.filter(entry, nil) {
+	if core.internal || core.bypassed() {
+		return ce.AddCore(entry, core)
+	}
+	if passed, _ := core.decide(entry, nil); passed {
 		ce = ce.AddCore(entry, core)
+	} else if core.counters != nil {
+		core.counters.add(entry.Level, false)
 	}
 	return ce
 }
@@ -60,26 +150,122 @@ func (core *filteringCore) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry)
 // Write determines whether the supplied zapcore.Entry with provided []zapcore.Field should
 // be logged, then calls the wrapped zapcore.Write.
 func (core *filteringCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
-	if !core.filter(entry, fields) {
+	if fields == nil {
+		// nil fields are reserved to the Check phase.
+		fields = []zapcore.Field{}
+	}
+	if core.internal || core.bypassed() {
+		return core.next.Write(entry, fields)
+	}
+	if core.contextOverride {
+		if filter, ok := overrideOf(fields); ok {
+			clone := *core
+			clone.setFilter(filter)
+			core = &clone
+		}
+	}
+	filterFields := fields
+	if len(core.filterContext) > 0 && !core.ignoresFields {
+		filterFields = append(core.filterContext[:len(core.filterContext):len(core.filterContext)], fields...)
+	}
+	passed, name := core.decide(entry, filterFields)
+	if core.counters != nil {
+		core.counters.add(entry.Level, passed)
+	}
+	if !passed {
 		return nil
 	}
+	if name != "" {
+		fields = append(fields[:len(fields):len(fields)], zap.String(core.attributionKey, name))
+	}
+	if core.decisionField {
+		fields = append(fields[:len(fields):len(fields)], DecisionField(true))
+	}
 	return core.next.Write(entry, fields)
 }
 
+// decide calls the filter, or its explainer from Write, recovering from its panics if
+// configured with WithRecover.
+func (core *filteringCore) decide(entry zapcore.Entry, fields []zapcore.Field) (passed bool, name string) {
+	if core.onPanic != nil {
+		defer func() {
+			if r := recover(); r != nil {
+				core.onPanic(r)
+				passed, name = false, ""
+			}
+		}()
+	}
+	if core.explain != nil && !isCheckPhase(fields) {
+		return core.explain(entry, fields)
+	}
+	return core.filter(entry, fields), ""
+}
+
+// WithRecover recovers from the panics of the filter, i.e., a buggy custom filter,
+// instead of crashing the logging call; the entry is then dropped and onPanic is
+// called with the recovered value.
+//
+// onPanic must log with a logger marked with Internal if it logs with the logger it
+// filters.
+func WithRecover(onPanic func(interface{})) Option {
+	return func(core *filteringCore) {
+		core.onPanic = onPanic
+		if core.onPanic == nil {
+			core.onPanic = func(interface{}) {}
+		}
+	}
+}
+
 // With adds structured context to the wrapped zapcore.Core.
+//
+// The fields are also remembered and passed to the filter before the fields of each
+// written entry, so filters like ByFieldValue see the context of the logger.
 func (core *filteringCore) With(fields []zapcore.Field) zapcore.Core {
-	return &filteringCore{
-		next:   core.next.With(fields),
-		filter: core.filter,
+	clone := *core
+	clone.next = core.next.With(fields)
+	clone.filterContext = append(core.filterContext[:len(core.filterContext):len(core.filterContext)], fields...)
+	if core.contextOverride {
+		if filter, ok := overrideOf(fields); ok {
+			clone.setFilter(filter)
+		}
+	}
+	if hasInternal(fields) {
+		clone.internal = true
 	}
+	return &clone
+}
+
+// Cloner is implemented by the cores of this package that can be copied.
+type Cloner interface {
+	Clone() zapcore.Core
+}
+
+// Clone returns a shallow copy of the core, sharing the same filter and next core, i.e.,
+// to build parallel logger trees that diverge with With.
+//
+// The state of stateful filters (rate limiters, counters...) is shared between the
+// copies; build a new filter and a new core to get an independent state.
+func (core *filteringCore) Clone() zapcore.Core {
+	clone := *core
+	return &clone
 }
 
 // Enabled asks the wrapped zapcore.Core to decide whether a given logging level is enabled
 // when logging a message.
+//
+// When the levels that can pass the filter are known, i.e., for ParseRules, levels the
+// filter never enables, for any namespace, are reported as disabled, so callers can
+// skip building their fields. Check still does the namespace-precise filtering. With
+// WithCounters, every level enabled by the next core is reported as enabled, so the
+// entries of these levels are counted as dropped by Check; the same goes while the
+// filtering is bypassed with SetBypass.
+//
+// The core is a zapcore.LevelEnabler, so it can gate the levels of other cores, i.e.,
+// with zapcore.NewCore; see also LevelEnabler.
 func (core *filteringCore) Enabled(level zapcore.Level) bool {
-	// FIXME: Maybe it's better to always return true and only rely on the Check() func?
-	//        Another way to consider it is to keep the smaller log level configured on
-	//        zapfilter.
+	if core.levels != nil && !core.internal && core.counters == nil && !core.levels.Has(level) && !core.bypassed() {
+		return false
+	}
 	return core.next.Enabled(level)
 }
 
@@ -90,111 +276,349 @@ func (core *filteringCore) Sync() error {
 
 // ByNamespaces takes a list of patterns to filter out logs based on their namespaces.
 // Patterns are checked using path.Match.
+//
+// When several patterns match a namespace, the most specific one wins, i.e., the one
+// with the most literal characters (wildcards and character classes don't count).
+// On equal specificity, excludes win over includes. When no pattern matches, the
+// namespace is only accepted if there are no include patterns at all, so a list of
+// excludes means "everything else". Empty patterns, including a lone "-", are ignored;
+// without patterns, i.e., "" or ",", no entry passes.
+//
+// The reserved "<root>" pattern only matches the root (unnamed) logger, it is more
+// specific than any other pattern.
+//
+//   foo.*,-foo.bar     foo.baz is accepted, foo.bar is not
+//   -foo.*,foo.bar     foo.bar is accepted, foo.baz is not
+//   foo*,-foo          foo is rejected (same specificity, exclude wins)
+//   -foo               anything but foo
+//
+// The decision is memoized for up to 10000 namespaces, then arbitrary namespaces are
+// forgotten to make room; see ByNamespacesNoCache for unbounded dynamic namespaces.
 func ByNamespaces(input string) FilterFunc {
-	if input == "" {
-		return alwaysFalseFilter
-	}
-	patterns := strings.Split(input, ",")
-
-	// edge case optimization (always true)
-	{
-		hasIncludeWildcard := false
-		hasExclude := false
-		for _, pattern := range patterns {
-			if pattern == "" {
-				continue
-			}
-			if pattern == "*" {
-				hasIncludeWildcard = true
-			}
-			if pattern[0] == '-' {
-				hasExclude = true
-			}
-		}
-		if hasIncludeWildcard && !hasExclude {
-			return alwaysTrueFilter
-		}
+	info := filterInfo{desc: describeCall("ByNamespaces", fmt.Sprintf("%q", input)), levels: &allLevelSet, ignoresFields: true}
+	matcher := newNamespaceMatcher(strings.Split(input, ","))
+
+	// edge case optimizations (always false, always true)
+	switch {
+	case len(matcher.patterns) == 0:
+		info.levels = &LevelSet{}
+		return describe(info, alwaysFalseFilter)
+	case matcher.alwaysMatch():
+		return describe(info, alwaysTrueFilter)
 	}
 
-	var mutex sync.Mutex
-	matchMap := map[string]bool{}
-	return func(entry zapcore.Entry, fields []zapcore.Field) bool {
-		mutex.Lock()
-		defer mutex.Unlock()
-
-		if _, found := matchMap[entry.LoggerName]; !found {
-			matchMap[entry.LoggerName] = false
-			matchInclude := false
-			matchExclude := false
-			for _, pattern := range patterns {
-				switch {
-				case pattern[0] == '-' && !matchExclude:
-					if matched, _ := path.Match(pattern[1:], entry.LoggerName); matched {
-						matchExclude = true
-					}
-				case pattern[0] != '-' && !matchInclude:
-					if matched, _ := path.Match(pattern, entry.LoggerName); matched {
-						matchInclude = true
-					}
-				}
-			}
-			matchMap[entry.LoggerName] = matchInclude && !matchExclude
-		}
-		return matchMap[entry.LoggerName]
-	}
+	return describe(info, matcher.cachedFilter())
 }
 
 // ExactLevel filters out entries with an invalid level.
 func ExactLevel(level zapcore.Level) FilterFunc {
-	return func(entry zapcore.Entry, fields []zapcore.Field) bool {
+	levels := NewLevelSet(level)
+	info := filterInfo{desc: describeCall("ExactLevel", level.String()), levels: &levels, ignoresFields: true}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
 		return entry.Level == level
+	})
+}
+
+// ExactLevels filters out entries whose level is not one of the levels, i.e.,
+// ExactLevels(zapcore.DebugLevel, zapcore.ErrorLevel).
+//
+// It is a single set lookup, faster than combining ExactLevel filters with Any.
+// Without levels, no entry passes.
+func ExactLevels(levels ...zapcore.Level) FilterFunc {
+	set := NewLevelSet(levels...)
+	names := make([]string, 0, set.Len())
+	for _, level := range set.Levels() {
+		names = append(names, level.String())
+	}
+	info := filterInfo{desc: describeCall("ExactLevels", names...), levels: &set, ignoresFields: true}
+	if set.IsEmpty() {
+		return describe(info, alwaysFalseFilter)
 	}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		return set.Has(entry.Level)
+	})
+}
+
+// ExcludeLevels is the complement of ExactLevels: it filters out entries whose level is
+// one of the levels, i.e., ExcludeLevels(zapcore.DebugLevel, zapcore.InfoLevel) keeps
+// everything except debug and info. Without levels, every entry passes.
+func ExcludeLevels(levels ...zapcore.Level) FilterFunc {
+	excluded := NewLevelSet(levels...)
+	names := make([]string, 0, excluded.Len())
+	for _, level := range excluded.Levels() {
+		names = append(names, level.String())
+	}
+	set := allLevelSet.Difference(excluded)
+	info := filterInfo{desc: describeCall("ExcludeLevels", names...), levels: &set, ignoresFields: true}
+	if excluded.IsEmpty() {
+		return describe(info, alwaysTrueFilter)
+	}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		return set.Has(entry.Level)
+	})
+}
+
+// OnLevels only calls filter for the entries whose level is one of the levels, and
+// drops the other entries, i.e., to run an expensive filter on the error entries only.
+//
+// Contrary to All(ExactLevels(levels...), filter), it is guaranteed that filter, and
+// its side effects, never see the other entries.
+func OnLevels(levels []zapcore.Level, filter FilterFunc) FilterFunc {
+	set := NewLevelSet(levels...)
+	desc := describeCall("OnLevels", describeLevelSet(set), Describe(filter))
+	return onLevels(desc, set, filter, false)
+}
+
+// OnLevelsOrDefault is like OnLevels, but returns defaultPass for the entries whose
+// level isn't one of the levels.
+func OnLevelsOrDefault(levels []zapcore.Level, filter FilterFunc, defaultPass bool) FilterFunc {
+	set := NewLevelSet(levels...)
+	desc := describeCall("OnLevelsOrDefault", describeLevelSet(set), Describe(filter), strconv.FormatBool(defaultPass))
+	return onLevels(desc, set, filter, defaultPass)
+}
+
+func onLevels(desc string, set LevelSet, filter FilterFunc, defaultPass bool) FilterFunc {
+	if filter == nil {
+		filter = alwaysFalseFilter
+	}
+	// superset of the levels that can pass
+	passing := set
+	if filterLevels := levelsOf(filter); filterLevels != nil {
+		passing = passing.Intersect(*filterLevels)
+	}
+	if defaultPass {
+		passing = passing.Union(allLevelSet.Difference(set))
+	}
+	info := filterInfo{desc: desc, levels: &passing, ignoresFields: ignoresFields(filter)}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		if !set.Has(entry.Level) {
+			return defaultPass
+		}
+		return filter(entry, fields)
+	})
+}
+
+// describeLevelSet renders a set of levels as a list, i.e., "[debug, error]".
+func describeLevelSet(set LevelSet) string {
+	names := make([]string, 0, set.Len())
+	for _, level := range set.Levels() {
+		names = append(names, level.String())
+	}
+	return "[" + strings.Join(names, ", ") + "]"
 }
 
 // MinimumLevel filters out entries with a too low level.
 func MinimumLevel(level zapcore.Level) FilterFunc {
-	return func(entry zapcore.Entry, fields []zapcore.Field) bool {
+	levels := levelsFrom(level)
+	info := filterInfo{desc: describeCall("MinimumLevel", level.String()), levels: &levels, ignoresFields: true}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
 		return entry.Level >= level
+	})
+}
+
+// MaximumLevel filters out entries with a too high level, i.e., to send the entries
+// below warn to stdout.
+func MaximumLevel(level zapcore.Level) FilterFunc {
+	levels := levelsUpTo(level)
+	info := filterInfo{desc: describeCall("MaximumLevel", level.String()), levels: &levels, ignoresFields: true}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		return entry.Level <= level
+	})
+}
+
+// WillCrash filters the entries whose logging call panics or exits the program, i.e.,
+// to tee them to a durable sink before the process dies: panic and fatal entries, and
+// dpanic entries when development is true, matching the zap.Development option of the
+// logger.
+//
+// Fatal entries only exit the program with the default zap.OnFatal behavior.
+func WillCrash(development bool) FilterFunc {
+	levels := NewLevelSet(zapcore.PanicLevel, zapcore.FatalLevel)
+	if development {
+		levels.Add(zapcore.DPanicLevel)
 	}
+	info := filterInfo{desc: describeCall("WillCrash", strconv.FormatBool(development)), levels: &levels, ignoresFields: true}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		return levels.Has(entry.Level)
+	})
+}
+
+// VerbosityLevel maps the number of times a verbosity flag is repeated, i.e., -v, -vv
+// or -vvv, to a minimum level:
+//
+//   | Count | Filter               |
+//   | ----- | -------------------- |
+//   | <= 0  | MinimumLevel(warn)   |
+//   | 1     | MinimumLevel(info)   |
+//   | 2     | MinimumLevel(debug)  |
+//   | >= 3  | AllowAll()           |
+//
+// With 3 or more, custom levels below debug, i.e., trace, pass too.
+func VerbosityLevel(count int) FilterFunc {
+	switch {
+	case count <= 0:
+		return MinimumLevel(zapcore.WarnLevel)
+	case count == 1:
+		return MinimumLevel(zapcore.InfoLevel)
+	case count == 2:
+		return MinimumLevel(zapcore.DebugLevel)
+	default:
+		return AllowAll()
+	}
+}
+
+// DynamicMinimumLevel is like MinimumLevel, but reads the level from al for each entry,
+// so changing it with al.SetLevel, i.e., from its HTTP handler, applies immediately.
+func DynamicMinimumLevel(al *zap.AtomicLevel) FilterFunc {
+	info := filterInfo{desc: describeCall("DynamicMinimumLevel"), ignoresFields: true}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		return al.Enabled(entry.Level)
+	})
 }
 
 // Any checks if any filter returns true.
+//
+// It simplifies itself when possible: DenyAll filters are skipped, a single remaining
+// filter is returned as is, and any AllowAll filter makes the result AllowAll.
 func Any(filters ...FilterFunc) FilterFunc {
-	return func(entry zapcore.Entry, fields []zapcore.Field) bool {
-		for _, filter := range filters {
-			if filter == nil {
-				continue
+	remaining := make([]FilterFunc, 0, len(filters))
+	for _, filter := range filters {
+		if filter == nil {
+			continue
+		}
+		if value, isConstant := constantOf(filter); isConstant {
+			if value {
+				return AllowAll()
 			}
+			continue
+		}
+		remaining = append(remaining, filter)
+	}
+	switch len(remaining) {
+	case 0:
+		return DenyAll()
+	case 1:
+		return remaining[0]
+	}
+	filters = remaining
+
+	info := filterInfo{
+		desc:          describeCall("Any", describeFilters(filters)...),
+		levels:        anyFiltersLevels(filters),
+		ignoresFields: allIgnoreFields(filters),
+		op:            opAny,
+		children:      filters,
+	}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		for _, filter := range filters {
 			if filter(entry, fields) {
 				return true
 			}
 		}
 		return false
-	}
+	})
 }
 
 // Reverse checks is the passed filter returns false.
 func Reverse(filter FilterFunc) FilterFunc {
-	return func(entry zapcore.Entry, fields []zapcore.Field) bool {
+	info := filterInfo{desc: describeCall("Reverse", Describe(filter)), ignoresFields: ignoresFields(filter)}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
 		return !filter(entry, fields)
-	}
+	})
 }
 
 // All checks if all filters return true.
+//
+// It simplifies itself when possible: AllowAll filters are skipped, a single remaining
+// filter is returned as is, and any DenyAll filter makes the result DenyAll.
+//
+// Without filters, or with only nil filters, no entry passes; it takes an AllowAll
+// filter for every entry to pass.
 func All(filters ...FilterFunc) FilterFunc {
-	return func(entry zapcore.Entry, fields []zapcore.Field) bool {
-		var atLeastOneSuccessful bool
-		for _, filter := range filters {
-			if filter == nil {
-				continue
+	remaining := make([]FilterFunc, 0, len(filters))
+	allowAll := false
+	for _, filter := range filters {
+		if filter == nil {
+			continue
+		}
+		if value, isConstant := constantOf(filter); isConstant {
+			if !value {
+				return DenyAll()
 			}
+			allowAll = true
+			continue
+		}
+		remaining = append(remaining, filter)
+	}
+	switch len(remaining) {
+	case 0:
+		if !allowAll {
+			return DenyAll()
+		}
+		return AllowAll()
+	case 1:
+		return remaining[0]
+	}
+	filters = remaining
+
+	info := filterInfo{
+		desc:          describeCall("All", describeFilters(filters)...),
+		levels:        allFiltersLevels(filters),
+		ignoresFields: allIgnoreFields(filters),
+		op:            opAll,
+		children:      filters,
+	}
+	return describe(info, func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		for _, filter := range filters {
 			if !filter(entry, fields) {
 				return false
 			}
-			atLeastOneSuccessful = true
 		}
-		return atLeastOneSuccessful
+		return true
+	})
+}
+
+// AllowAll returns a filter matching every entry.
+//
+// The combinators recognize it and simplify themselves accordingly.
+func AllowAll() FilterFunc {
+	return alwaysTrueFilter
+}
+
+// IsAlwaysTrue returns true if the filter is known to match every entry, i.e., AllowAll
+// or a combination simplified to it, so installing a filtering core can be skipped.
+//
+// Custom filters are opaque and are never reported as always true.
+func IsAlwaysTrue(filter FilterFunc) bool {
+	value, isConstant := constantOf(filter)
+	return isConstant && value
+}
+
+// IsAlwaysFalse returns true if the filter is known to match no entry, i.e., DenyAll,
+// ParseRules("") or a combination simplified to it.
+//
+// Custom filters are opaque and are never reported as always false.
+func IsAlwaysFalse(filter FilterFunc) bool {
+	value, isConstant := constantOf(filter)
+	return isConstant && !value
+}
+
+// ByEnv returns AllowAll if the environment variable key equals value, else DenyAll.
+//
+// The environment is only read once, when calling ByEnv, so a whole class of logs can
+// be enabled at startup for free, i.e., All(ByEnv("VERBOSE", "1"), ExactLevel(DebugLevel)).
+func ByEnv(key, value string) FilterFunc {
+	if os.Getenv(key) == value {
+		return AllowAll()
 	}
+	return DenyAll()
+}
+
+// DenyAll returns a filter matching no entry.
+//
+// The combinators recognize it and simplify themselves accordingly.
+func DenyAll() FilterFunc {
+	return alwaysFalseFilter
 }
 
 // ParseRules takes a CLI-friendly set of rules to construct a filter.
@@ -203,20 +627,23 @@ func All(filters ...FilterFunc) FilterFunc {
 //
 //   pattern: RULE [RULE...]
 //   RULE: one of:
-//    - LEVELS:NAMESPACES
+//    - LEVELS:NAMESPACES[:NAMESPACES...]
 //    - NAMESPACES
+//    - -LEVELS:NAMESPACES  // subtracts the entries it matches
 //   LEVELS: LEVEL,[,LEVEL]
 //   LEVEL: see `Level Patterns`
 //   NAMESPACES: NAMESPACE[,NAMESPACE]
 //   NAMESPACE: one of:
 //    - namespace     // should be exactly this namespace
 //    - *mat*ch*      // should match
+//    - <root>        // should be the root (unnamed) logger
 //    - -NAMESPACE    // should not match
 //
 // Examples
 //
 //    *                            everything
 //    *:*                          everything
+//    all:api.*                    any level; namespaces matching 'api.*'
 //    info:*                       level info;  any namespace
 //    info+:*                      levels info, warn, error, dpanic, panic, and fatal; any namespace
 //    info,warn:*                  levels info, warn; any namespace
@@ -226,45 +653,39 @@ func All(filters ...FilterFunc) FilterFunc {
 //    *:ns1*                       any level; namespaces matching 'ns1*'
 //    *:ns1,ns2                    any level; namespaces 'ns1' and 'ns2'
 //    *:ns*,-ns3*                  any level; namespaces matching 'ns*' but not matching 'ns3*'
+//    *:-ns3*                      any level; namespaces not matching 'ns3*'
+//    *:-ns*,ns3                   any level; namespace 'ns3' (the most specific pattern wins, see ByNamespaces)
 //    info:ns1                     level info; namespace 'ns1'
 //    info,warn:ns1,ns2            levels info and warn; namespaces 'ns1' and 'ns2'
 //    info:ns1 warn:n2             level info + namespace 'ns1' OR level warn and namespace 'ns2'
 //    info,warn:myns* error+:*     levels info or warn and namespaces matching 'myns*' OR levels error, dpanic, panic or fatal for any namespace
+//    [debug,error]:api.*          levels debug and error; namespaces matching 'api.*'
+//    info:api.*:-api.health       level info; namespaces matching 'api.*' but not 'api.health'
+//    * -debug:vendor.*            everything, except the debug entries of namespaces matching 'vendor.*'
+//    -2+:api.*                    custom level -2 (i.e., trace) and above; namespaces matching 'api.*'
+//    stack:error+:*               levels error and above, with a stacktrace (see zap.AddStacktrace); any namespace
+//    * -stack:warn:*              everything, except the warn entries with a stacktrace
+//
+// Rules are combined with OR, except the subtracting rules (starting with '-' and
+// having LEVELS): whatever their position, they remove the entries they match from the
+// result of the other rules; see ParseRulesAll to combine the rules with AND. A '-'
+// followed by a digit starts a negative level rather than a subtracting rule: "-2:*"
+// matches the level -2, "--2:*" subtracts it.
+//
+// Additional ':' separated NAMESPACES are joined to the first ones, so the includes
+// and excludes of a rule can be written as separate clauses. Empty NAMESPACE elements
+// are ignored, but a rule needs at least one: "info:," is a syntax error.
+//
+// A rule prefixed with the "stack:" flag, after the subtracting '-', only matches the
+// entries with a stacktrace, i.e., "stack:error:*"; "stack:*" matches every entry with
+// a stacktrace. The stacktrace is captured after Check, so these rules are decided by
+// Write. A lone "stack", without ':', stays a namespace.
 func ParseRules(pattern string) (FilterFunc, error) {
-	var topFilter FilterFunc
-
-	// rules are separated by spaces, tabs or \n
-	for _, rule := range strings.Fields(pattern) {
-		// split rule into parts (separated by ':')
-		rule = strings.TrimSpace(rule)
-		if rule == "" {
-			continue
-		}
-		parts := strings.SplitN(rule, ":", 2)
-		var left, right string
-		switch len(parts) {
-		case 1:
-			// if no separator, left stays empty
-			right = parts[0]
-		case 2:
-			if parts[0] == "" || parts[1] == "" {
-				return nil, fmt.Errorf("bad syntax")
-			}
-			left = parts[0]
-			right = parts[1]
-		default:
-			return nil, fmt.Errorf("bad syntax")
-		}
-
-		levelFilter, err := ByLevels(left)
-		if err != nil {
-			return nil, err
-		}
-		namespaceFilter := ByNamespaces(right)
-		topFilter = Any(topFilter, All(levelFilter, namespaceFilter))
+	rules, err := CompileRules(pattern)
+	if err != nil {
+		return nil, err
 	}
-
-	return topFilter, nil
+	return rules.FilterFunc(), nil
 }
 
 // ByLevels creates a FilterFunc based on a pattern.
@@ -274,6 +695,8 @@ func ParseRules(pattern string) (FilterFunc, error) {
 //   | ------- | ----- | ---- | ---- | ----- | ------ | ----- | ----- |
 //   | <empty> | X     | X    | X    | X     | X      | X     | X     |
 //   | *       | X     | X    | X    | X     | x      | X     | X     |
+//   | all     | X     | X    | X    | X     | X      | X     | X     |
+//   | any     | X     | X    | X    | X     | X      | X     | X     |
 //   | debug   | X     |      |      |       |        |       |       |
 //   | info    |       | X    |      |       |        |       |       |
 //   | warn    |       |      | X    |       |        |       |       |
@@ -288,92 +711,220 @@ func ParseRules(pattern string) (FilterFunc, error) {
 //   | dpanic+ |       |      |      |       | X      | X     | X     |
 //   | panic+  |       |      |      |       |        | X     | X     |
 //   | fatal+  |       |      |      |       |        |       | X     |
+//   | crash   |       |      |      |       |        | X     | X     |
+//
+// Levels can also be written as a bracketed set, i.e., "[debug,error]", equivalent to
+// "debug,error".
+//
+// Custom levels, i.e., a trace level below debug, are written as numbers: "-2" matches
+// zapcore.Level(-2), and "-2+" matches every level greater than or equal to -2, custom
+// ones included, like MinimumLevel. "*", "all", "any" and an empty pattern match every
+// level, custom ones included; "debug+" and the other patterns only match zap's levels.
+//
+// "crash" selects the levels that always abort the program. DPanic entries keep their
+// level whatever the development flag of the logger, so "dpanic" matches them in both
+// modes; in development, the logger panics after writing them, even if they are
+// filtered out.
 func ByLevels(pattern string) (FilterFunc, error) {
-	// parse pattern
-	var enabled uint
+	levels, err := parseLevels(pattern)
+	if err != nil {
+		return nil, err
+	}
+	info := filterInfo{desc: describeCall("ByLevels", fmt.Sprintf("%q", pattern)), levels: &levels, ignoresFields: true}
+	return describe(info, byLevelSet(levels)), nil
+}
+
+// byLevelSet constructs a filter matching the levels of the set.
+func byLevelSet(levels LevelSet) FilterFunc {
+	return ExactLevels(levels.Levels()...)
+}
+
+// parseLevels parses a level pattern, see ByLevels.
+func parseLevels(pattern string) (LevelSet, error) {
+	raw := pattern
+	if strings.HasPrefix(pattern, "[") {
+		// bracketed set, i.e., "[debug,error]"
+		if !strings.HasSuffix(pattern, "]") {
+			return LevelSet{}, fmt.Errorf("unterminated level set: %q", pattern)
+		}
+		pattern = pattern[1 : len(pattern)-1]
+	}
+	var levels LevelSet
 	for _, part := range strings.Split(pattern, ",") {
+		if err := checkLevelOperators(part); err != nil {
+			return LevelSet{}, err
+		}
 		switch strings.ToLower(part) {
-		case "", "*", "debug+":
-			enabled |= debugLevel | infoLevel | warnLevel | errorLevel | dpanicLevel | panicLevel | fatalLevel
+		case "":
+			// only a whole empty pattern selects every level; an empty element of a
+			// set or a list, i.e., "[]", "[info,]" or "info,", selects none
+			if raw != "" {
+				return LevelSet{}, &EmptyLevelSetError{Levels: raw}
+			}
+			levels = levels.Union(allLevelSet)
+		case "*", "all", "any":
+			// every level, including the custom levels below debug
+			levels = levels.Union(allLevelSet)
+		case "debug+":
+			levels = levels.Union(builtinLevelsFrom(zapcore.DebugLevel))
 		case "debug":
-			enabled |= debugLevel
+			levels.Add(zapcore.DebugLevel)
 		case "info":
-			enabled |= infoLevel
+			levels.Add(zapcore.InfoLevel)
 		case "info+":
-			enabled |= infoLevel | warnLevel | errorLevel | dpanicLevel | panicLevel | fatalLevel
+			levels = levels.Union(builtinLevelsFrom(zapcore.InfoLevel))
 		case "warn":
-			enabled |= warnLevel
+			levels.Add(zapcore.WarnLevel)
 		case "warn+":
-			enabled |= warnLevel | errorLevel | dpanicLevel | panicLevel | fatalLevel
+			levels = levels.Union(builtinLevelsFrom(zapcore.WarnLevel))
 		case "error":
-			enabled |= errorLevel
+			levels.Add(zapcore.ErrorLevel)
 		case "error+":
-			enabled |= errorLevel | dpanicLevel | panicLevel | fatalLevel
+			levels = levels.Union(builtinLevelsFrom(zapcore.ErrorLevel))
 		case "dpanic":
-			enabled |= dpanicLevel
+			levels.Add(zapcore.DPanicLevel)
 		case "dpanic+":
-			enabled |= dpanicLevel | panicLevel | fatalLevel
+			levels = levels.Union(builtinLevelsFrom(zapcore.DPanicLevel))
 		case "panic":
-			enabled |= panicLevel
+			levels.Add(zapcore.PanicLevel)
 		case "panic+":
-			enabled |= panicLevel | fatalLevel
+			levels = levels.Union(builtinLevelsFrom(zapcore.PanicLevel))
+		case "crash":
+			levels = levels.Union(builtinLevelsFrom(zapcore.PanicLevel))
 		case "fatal", "fatal+":
-			enabled |= fatalLevel
+			levels.Add(zapcore.FatalLevel)
 		default:
-			return nil, fmt.Errorf("unsupported keyword: %q", pattern)
+			level, plus, ok := parseNumericLevel(part)
+			if !ok {
+				if suggestion := suggestLevelKeyword(part); suggestion != "" {
+					return LevelSet{}, fmt.Errorf("unsupported keyword: %q; did you mean %q?", pattern, suggestion)
+				}
+				return LevelSet{}, fmt.Errorf("unsupported keyword: %q", pattern)
+			}
+			if plus {
+				levels = levels.Union(levelsFrom(level))
+			} else {
+				levels.Add(level)
+			}
 		}
 	}
+	if levels.IsEmpty() {
+		return LevelSet{}, &EmptyLevelSetError{Levels: raw}
+	}
+	return levels, nil
+}
 
-	// if everything is enabled
-	if enabled == debugLevel&infoLevel&warnLevel&errorLevel&dpanicLevel&panicLevel&fatalLevel {
-		return alwaysTrueFilter, nil
+// checkLevelOperators rejects the malformed operators of a level, i.e., "info+-" or
+// "+info", which would otherwise be reported as unsupported keywords.
+func checkLevelOperators(part string) error {
+	name := strings.TrimSuffix(part, "+")
+	switch {
+	case strings.Contains(part, ".."):
+		return fmt.Errorf("level ranges are not supported: %q", part)
+	case strings.Contains(name, "+"):
+		return fmt.Errorf("misplaced '+' operator, it must end the level: %q", part)
+	case strings.HasPrefix(name, "-") && !startsWithDigit(name[1:]),
+		len(name) > 1 && strings.Contains(name[1:], "-"):
+		return fmt.Errorf("misplaced '-' operator, only numeric levels can be negative: %q", part)
 	}
+	return nil
+}
+
+// levelKeywords are the keywords of parseLevels, suggested for typos.
+var levelKeywords = []string{
+	"all", "any", "crash",
+	"debug", "info", "warn", "error", "dpanic", "panic", "fatal",
+	"debug+", "info+", "warn+", "error+", "dpanic+", "panic+", "fatal+",
+}
 
-	// construct custom filter
-	var filter FilterFunc
-	if enabled&debugLevel != 0 {
-		filter = Any(ExactLevel(zapcore.DebugLevel), filter)
+// suggestLevelKeyword returns the keyword closest to a misspelled one, or an empty
+// string if none is close enough.
+func suggestLevelKeyword(part string) string {
+	part = strings.ToLower(part)
+	// beyond, the token is more likely another word than a typo
+	maxDistance := len(part) / 2
+	if maxDistance > 2 {
+		maxDistance = 2
 	}
-	if enabled&infoLevel != 0 {
-		filter = Any(ExactLevel(zapcore.InfoLevel), filter)
+	best, bestDistance := "", maxDistance+1
+	for _, keyword := range levelKeywords {
+		if distance := editDistance(part, keyword); distance < bestDistance {
+			best, bestDistance = keyword, distance
+		}
+	}
+	return best
+}
+
+// editDistance returns the Levenshtein distance between a and b.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
 	}
-	if enabled&warnLevel != 0 {
-		filter = Any(ExactLevel(zapcore.WarnLevel), filter)
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min3(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
 	}
-	if enabled&errorLevel != 0 {
-		filter = Any(ExactLevel(zapcore.ErrorLevel), filter)
+	return previous[len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
 	}
-	if enabled&dpanicLevel != 0 {
-		filter = Any(ExactLevel(zapcore.DPanicLevel), filter)
+	if c < a {
+		a = c
 	}
-	if enabled&panicLevel != 0 {
-		filter = Any(ExactLevel(zapcore.PanicLevel), filter)
+	return a
+}
+
+// parseNumericLevel parses a numeric level, i.e., "-2" or "-2+".
+func parseNumericLevel(part string) (level zapcore.Level, plus bool, ok bool) {
+	if strings.HasSuffix(part, "+") {
+		part, plus = part[:len(part)-1], true
 	}
-	if enabled&fatalLevel != 0 {
-		filter = Any(ExactLevel(zapcore.FatalLevel), filter)
+	value, err := strconv.ParseInt(part, 10, 8)
+	if err != nil {
+		return 0, false, false
 	}
-	return filter, nil
+	return zapcore.Level(value), plus, true
 }
 
-const (
-	debugLevel uint = 1 << iota
-	infoLevel
-	warnLevel
-	errorLevel
-	dpanicLevel
-	panicLevel
-	fatalLevel
-)
+// EmptyLevelSetError is returned when the LEVELS of a rule select no level, as such a
+// rule would silently match nothing, or contain an empty element, i.e., "[info,]".
+type EmptyLevelSetError struct {
+	Levels string // as written in the rule
+}
+
+func (e *EmptyLevelSetError) Error() string {
+	return fmt.Sprintf("empty level set: %q", e.Levels)
+}
 
 // MustParseRules calls ParseRules and panics if initialization failed.
+//
+// The panic value is an error wrapping the error of ParseRules and quoting pattern, so
+// the message points at the offending configuration.
 func MustParseRules(pattern string) FilterFunc {
 	filter, err := ParseRules(pattern)
 	if err != nil {
-		panic(err)
+		panic(fmt.Errorf("zapfilter: invalid rules %q: %w", pattern, err))
 	}
 	return filter
 }
 
+// isCheckPhase returns true when the filter is called from Check rather than Write.
+func isCheckPhase(fields []zapcore.Field) bool {
+	return fields == nil
+}
+
 func alwaysFalseFilter(_ zapcore.Entry, _ []zapcore.Field) bool {
 	return false
 }
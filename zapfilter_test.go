@@ -1,8 +1,12 @@
 package zapfilter_test
 
 import (
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"math"
 	"math/rand"
+	"os"
 	"strings"
 	"testing"
 
@@ -11,6 +15,7 @@ import (
 	"go.uber.org/zap/zapcore"
 	"go.uber.org/zap/zaptest/observer"
 	"moul.io/zapfilter"
+	"moul.io/zapfilter/zapfiltertest"
 )
 
 func ExampleNewFilteringCore_wrap() {
@@ -44,11 +49,11 @@ func ExampleNewFilteringCore_newlogger() {
 }
 
 func ExampleFilterFunc_custom() {
-	rand.Seed(42)
+	random := rand.New(rand.NewSource(42))
 
 	core := zap.NewExample().Core()
 	filterFunc := func(entry zapcore.Entry, fields []zapcore.Field) bool {
-		return rand.Intn(2) == 1
+		return random.Intn(2) == 1
 	}
 	logger := zap.New(zapfilter.NewFilteringCore(core, filterFunc))
 	defer logger.Sync()
@@ -188,6 +193,30 @@ func TestFilterFunc(t *testing.T) {
 			"all-except-error",
 			zapfilter.Reverse(zapfilter.ExactLevel(zapcore.ErrorLevel)),
 			[]string{"a", "b", "c"},
+		}, {
+			"exclude-debug",
+			zapfilter.ExcludeLevels(zapcore.DebugLevel),
+			[]string{"b", "c", "d"},
+		}, {
+			"exclude-info",
+			zapfilter.ExcludeLevels(zapcore.InfoLevel),
+			[]string{"a", "c", "d"},
+		}, {
+			"exclude-warn",
+			zapfilter.ExcludeLevels(zapcore.WarnLevel),
+			[]string{"a", "b", "d"},
+		}, {
+			"exclude-error",
+			zapfilter.ExcludeLevels(zapcore.ErrorLevel),
+			[]string{"a", "b", "c"},
+		}, {
+			"exclude-debug-info",
+			zapfilter.ExcludeLevels(zapcore.DebugLevel, zapcore.InfoLevel),
+			[]string{"c", "d"},
+		}, {
+			"exclude-none",
+			zapfilter.ExcludeLevels(),
+			[]string{"a", "b", "c", "d"},
 		}, {
 			"any",
 			zapfilter.Any(
@@ -213,6 +242,7 @@ func TestFilterFunc(t *testing.T) {
 	}
 
 	for _, tc := range cases {
+		tc := tc
 		t.Run(tc.name, func(t *testing.T) {
 			t.Parallel()
 
@@ -254,6 +284,9 @@ func TestParseRules(t *testing.T) {
 	}{
 		{"empty", "", "", nil},
 		{"everything", "*", everything, nil},
+		{"everything-all-keyword", "all:*", everything, nil},
+		{"everything-any-keyword", "any:*", everything, nil},
+		{"everything-all-keyword-uppercase", "ALL:*", everything, nil},
 		{"debug+", "debug+:*", everything, nil},
 		{"all-debug", "debug:*", allDebug, nil},
 		{"all-info", "info:*", allInfo, nil},
@@ -277,10 +310,49 @@ func TestParseRules(t *testing.T) {
 		{"exclude-4", "*,-foo,-bar", "abcdmnopqrstuvwxyz012345", nil},
 		{"exclude-5", "foo*,bar*,-foo.foo,-bar.foo", "efghijklqrst", nil},
 		{"exclude-6", "foo*,-foo.foo,bar*,-bar.foo", "efghijklqrst", nil},
+		{"exclude-7", "-foo.*,foo.bar", "qrst", nil},
+		{"exclude-8", "foo*,-foo", "qrstuvwx", nil},
+		{"exclude-only", "-foo*,-bar*", "abcdmnop2345", nil},
+		{"bracket-set", "[info,warn]:*", "bcfgjknorsvwz034", nil},
+		{"bracket-set-single", "[error]:foo", "h", nil},
+		{"bracket-set-plus", "[debug,warn+]:foo", "egh", nil},
+		{"bracket-set-empty", "[]:*", "", &zapfilter.EmptyLevelSetError{Levels: "[]"}},
+		{"bracket-set-empty-elements", "[,]:*", "", &zapfilter.EmptyLevelSetError{Levels: "[,]"}},
+		{"bracket-set-trailing-comma", "[info,]:*", "", &zapfilter.EmptyLevelSetError{Levels: "[info,]"}},
+		{"list-trailing-comma", "info,:*", "", &zapfilter.EmptyLevelSetError{Levels: "info,"}},
+		{"list-leading-comma", ",info:*", "", &zapfilter.EmptyLevelSetError{Levels: ",info"}},
+		{"list-double-comma", "info,,warn:*", "", &zapfilter.EmptyLevelSetError{Levels: "info,,warn"}},
+		{"bracket-set-unterminated", "[info,warn:*", "", fmt.Errorf(`unterminated level set: "[info,warn"`)},
+		{"bracket-set-invalid", "[debug,invalid]:*", "", fmt.Errorf(`unsupported keyword: "debug,invalid"`)},
+		{"multi-clause", "info:foo*:-foo.foo", "fr", nil},
+		{"multi-clause-3", "warn+:foo:bar,baz:-baz", "ghkl", nil},
+		{"multi-clause-subtract", "* -*:foo:bar", "abcdmnopqrstuvwxyz012345", nil},
+		{"multi-clause-empty", "info:foo:", "", fmt.Errorf(`bad syntax`)},
+		{"multi-clause-empty-2", "info::foo", "", fmt.Errorf(`bad syntax`)},
+		{"operator-plus-minus", "info+-:*", "", fmt.Errorf(`misplaced '+' operator, it must end the level: "info+-"`)},
+		{"operator-leading-plus", "+info:*", "", fmt.Errorf(`misplaced '+' operator, it must end the level: "+info"`)},
+		{"operator-double-plus", "info++:*", "", fmt.Errorf(`misplaced '+' operator, it must end the level: "info++"`)},
+		{"operator-lone-plus", "+:*", "", fmt.Errorf(`unsupported keyword: "+"`)},
+		{"operator-dangling-range", "info..:*", "", fmt.Errorf(`level ranges are not supported: "info.."`)},
+		{"operator-leading-range", "..error:*", "", fmt.Errorf(`level ranges are not supported: "..error"`)},
+		{"operator-range", "[debug,info..error]:*", "", fmt.Errorf(`level ranges are not supported: "info..error"`)},
+		{"operator-minus-keyword", "[-info]:*", "", fmt.Errorf(`misplaced '-' operator, only numeric levels can be negative: "-info"`)},
+		{"operator-trailing-minus", "info-:*", "", fmt.Errorf(`misplaced '-' operator, only numeric levels can be negative: "info-"`)},
+		{"operator-double-minus", "---2:*", "", fmt.Errorf(`misplaced '-' operator, only numeric levels can be negative: "--2"`)},
+		{"typo", "waring:*", "", fmt.Errorf(`unsupported keyword: "waring"; did you mean "warn"?`)},
+		{"typo-plus", "eror+:*", "", fmt.Errorf(`unsupported keyword: "eror+"; did you mean "error+"?`)},
+		{"typo-case", "DEBGU:*", "", fmt.Errorf(`unsupported keyword: "DEBGU"; did you mean "debug"?`)},
+		{"typo-in-list", "info,fatl:*", "", fmt.Errorf(`unsupported keyword: "info,fatl"; did you mean "fatal"?`)},
+		{"typo-unrelated", "verbose:*", "", fmt.Errorf(`unsupported keyword: "verbose"`)},
+		{"typo-short", "x:*", "", fmt.Errorf(`unsupported keyword: "x"`)},
 		{"invalid-left", "invalid:*", "", fmt.Errorf(`unsupported keyword: "invalid"`)},
 		{"missing-left", ":*", "", fmt.Errorf(`bad syntax`)},
-		{"missing-right", ":*", "", fmt.Errorf(`bad syntax`)},
-		//{"missing-exclude-pattern", "*:-", "", fmt.Errorf(`bad syntax`)},
+		{"missing-right", "info:", "", fmt.Errorf(`bad syntax`)},
+		{"missing-right-comma", "info:,", "", fmt.Errorf(`bad syntax`)},
+		{"lone-comma", ",", "", fmt.Errorf(`bad syntax`)},
+		{"missing-exclude-pattern", "*:-", "", fmt.Errorf(`bad syntax`)},
+		{"missing-exclude-pattern-2", "*:foo,-", "", fmt.Errorf(`bad syntax`)},
+		{"missing-exclude-pattern-3", "-", "", fmt.Errorf(`bad syntax`)},
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -346,6 +418,38 @@ func TestParseRules(t *testing.T) {
 	}
 }
 
+func TestByNamespaces_precedence(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		patterns string
+		accepted []string
+		rejected []string
+	}{
+		{"foo.*,-foo.bar,foo.bar.baz", []string{"foo.baz", "foo.bar.baz", "foo.bar.qux"}, []string{"foo.bar", "foo", "bar"}},
+		{"-foo.*,foo.bar", []string{"foo.bar"}, []string{"foo.baz", "foo", "bar"}},
+		{"foo.*,-foo.b*,foo.b?r", []string{"foo.a", "foo.bar", "foo.bor"}, []string{"foo.baz", "foo.b"}},
+		{"*.bar,-foo.*", []string{"bar.bar", "baz.bar"}, []string{"foo.bar", "foo.baz"}},
+		{"foo,-foo", nil, []string{"foo", "bar"}},
+		{"-foo.*", []string{"", "foo", "bar", "bar.foo"}, []string{"foo.bar"}},
+		{"foo,,bar", []string{"foo", "bar"}, []string{"", "baz"}},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.patterns, func(t *testing.T) {
+			t.Parallel()
+
+			filter := zapfilter.ByNamespaces(tc.patterns)
+			for _, name := range tc.accepted {
+				zapfiltertest.AssertPasses(t, filter, zapfiltertest.Entry(zapcore.InfoLevel, name, ""))
+			}
+			for _, name := range tc.rejected {
+				zapfiltertest.AssertDrops(t, filter, zapfiltertest.Entry(zapcore.InfoLevel, name, ""))
+			}
+		})
+	}
+}
+
 func TestCheck(t *testing.T) {
 	cases := []struct {
 		rules     string
@@ -436,6 +540,38 @@ func ExampleCheckAnyLevel() {
 	// true
 }
 
+func TestCheckAnyLevelIncludingFatal(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		rules          string
+		namespace      string
+		anyLevel       bool
+		includingFatal bool
+	}{
+		{"", "", false, false},
+		{"fatal:*", "", false, true},
+		{"panic:*", "", false, true},
+		{"dpanic:*", "", true, true},
+		{"info:*", "", true, true},
+		{"fatal:foo", "foo", false, true},
+		{"fatal:foo", "bar", false, false},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.rules+"-"+tc.namespace, func(t *testing.T) {
+			t.Parallel()
+
+			next, logs := observer.New(zapcore.DebugLevel)
+			logger := zap.New(zapfilter.NewFilteringCore(next, zapfilter.MustParseRules(tc.rules))).Named(tc.namespace)
+
+			require.Equal(t, tc.anyLevel, zapfilter.CheckAnyLevel(logger))
+			require.Equal(t, tc.includingFatal, zapfilter.CheckAnyLevelIncludingFatal(logger))
+			require.Equal(t, 0, logs.Len())
+		})
+	}
+}
+
 func ExampleCheckLevel() {
 	c := zap.NewExample().Core()
 	logger := zap.New(zapfilter.NewFilteringCore(c, zapfilter.MustParseRules("debug:*.* info:demo*")))
@@ -467,6 +603,57 @@ func ExampleCheckLevel() {
 	// false
 }
 
+func ExampleSetRules() {
+	core := zap.NewExample().Core()
+	logger := zap.New(zapfilter.NewFilteringCore(core, zapfilter.MustParseRules("error+:*")))
+	defer logger.Sync()
+
+	verbose, err := zapfilter.SetRules(logger.With(zap.String("lorem", "ipsum")), "debug:demo.*")
+	if err != nil {
+		panic(err)
+	}
+	verbose.Named("demo.frontend").Debug("hello region!")
+	verbose.Named("other").Error("hello planet!")   // dropped by the new rules
+	logger.Named("demo.frontend").Debug("hello city!") // the original logger is untouched
+
+	_, err = zapfilter.SetRules(logger, "invalid:*")
+	fmt.Println(err)
+
+	// Output:
+	// {"level":"debug","logger":"demo.frontend","msg":"hello region!","lorem":"ipsum"}
+	// unsupported keyword: "invalid"
+}
+
+func TestSetRules(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+
+	// without filtering core, a filtering core is added
+	logger, err := zapfilter.SetRules(zap.New(next), "info:api")
+	require.NoError(t, err)
+	logger.Named("api").Info("a")
+	logger.Named("db").Info("b")
+
+	// the options of a filtering core are kept
+	filtered := zap.New(zapfilter.NewFilteringCore(next, zapfilter.MustParseRules("error:*"), zapfilter.WithDecisionField()))
+	logger, err = zapfilter.SetRules(filtered, "info:db")
+	require.NoError(t, err)
+	logger.Named("api").Info("c")
+	logger.Named("db").Info("d")
+	filtered.Named("db").Info("e")
+
+	gotLogs := []string{}
+	for _, log := range logs.All() {
+		gotLogs = append(gotLogs, log.Message)
+	}
+	require.Equal(t, []string{"a", "d"}, gotLogs)
+	passed, found := zapfilter.DecisionOf(logs.All()[1].Context)
+	require.True(t, found)
+	require.True(t, passed)
+	require.False(t, zapfilter.CheckLevel(logger, zapcore.DebugLevel))
+}
+
 func Example_with() {
 	core := zap.NewExample().Core()
 	logger := zap.New(zapfilter.NewFilteringCore(core, zapfilter.ByNamespaces("demo1.*,demo3.*")))
@@ -481,3 +668,554 @@ func Example_with() {
 	// {"level":"debug","logger":"demo1.frontend","msg":"hello region!","lorem":"ipsum"}
 	// {"level":"debug","logger":"demo3.frontend","msg":"hello solar system!","lorem":"ipsum"}
 }
+
+func TestAllAny_simplification(t *testing.T) {
+	t.Parallel()
+
+	info := zapfilter.MinimumLevel(zapcore.InfoLevel)
+	cases := []struct {
+		name     string
+		filter   zapfilter.FilterFunc
+		expected string
+	}{
+		{"all-allow", zapfilter.All(info, zapfilter.AllowAll()), "MinimumLevel(info)"},
+		{"all-deny", zapfilter.All(info, zapfilter.DenyAll()), "DenyAll()"},
+		{"all-wildcard", zapfilter.All(info, zapfilter.ByNamespaces("*")), "MinimumLevel(info)"},
+		{"all-empty", zapfilter.All(), "DenyAll()"},
+		{"all-nil", zapfilter.All(nil), "DenyAll()"},
+		{"all-nil-allow", zapfilter.All(nil, zapfilter.AllowAll()), "AllowAll()"},
+		{"any-deny", zapfilter.Any(info, zapfilter.DenyAll()), "MinimumLevel(info)"},
+		{"any-allow", zapfilter.Any(info, zapfilter.AllowAll()), "AllowAll()"},
+		{"any-empty-namespaces", zapfilter.Any(info, zapfilter.ByNamespaces("")), "MinimumLevel(info)"},
+		{"any-empty", zapfilter.Any(), "DenyAll()"},
+		{"any-nil", zapfilter.Any(nil), "DenyAll()"},
+		{"nested", zapfilter.Any(zapfilter.All(info, zapfilter.AllowAll()), zapfilter.DenyAll()), "MinimumLevel(info)"},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			require.Equal(t, tc.expected, zapfilter.Describe(tc.filter))
+		})
+	}
+
+	entries := []zapcore.Entry{
+		zapfiltertest.Entry(zapcore.DebugLevel, "a", "hello"),
+		zapfiltertest.Entry(zapcore.InfoLevel, "a", "hello"),
+		zapfiltertest.Entry(zapcore.ErrorLevel, "b", "hello"),
+	}
+	expected := []bool{false, true, true}
+	require.Equal(t, expected, zapfiltertest.Record(zapfilter.All(info, zapfilter.AllowAll()), entries))
+	require.Equal(t, expected, zapfiltertest.Record(zapfilter.Any(info, zapfilter.DenyAll()), entries))
+	require.Equal(t, []bool{false, false, false}, zapfiltertest.Record(zapfilter.All(info, zapfilter.DenyAll()), entries))
+	require.Equal(t, []bool{true, true, true}, zapfiltertest.Record(zapfilter.Any(info, zapfilter.AllowAll()), entries))
+	for _, filter := range []zapfilter.FilterFunc{zapfilter.All(), zapfilter.All(nil), zapfilter.Any(), zapfilter.Any(nil)} {
+		require.Equal(t, []bool{false, false, false}, zapfiltertest.Record(filter, entries))
+	}
+}
+
+func BenchmarkNopFilteringCore(b *testing.B) {
+	next := zapcore.NewCore(zapcore.NewJSONEncoder(zapcore.EncoderConfig{}), zapcore.AddSync(ioutil.Discard), zapcore.DebugLevel)
+	cores := []struct {
+		name string
+		core zapcore.Core
+	}{
+		{"raw", next},
+		{"nop", zapfilter.NopFilteringCore(next)},
+		{"allow-all", zapfilter.NewFilteringCore(next, zapfilter.AllowAll())},
+	}
+	for _, tc := range cores {
+		logger := zap.New(tc.core)
+		b.Run(tc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				logger.Info("hello", zap.Int("i", i))
+			}
+		})
+	}
+}
+
+func TestFilteringCoreClone(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	limiter := zapfilter.NewGlobalRateLimiter(0, 2)
+	core := zapfilter.NewFilteringCore(next, zapfilter.All(zapfilter.MinimumLevel(zapcore.InfoLevel), limiter.Filter))
+
+	clone := core.(zapfilter.Cloner).Clone()
+	require.NotSame(t, core, clone)
+
+	original := zap.New(core).With(zap.String("tree", "original"))
+	cloned := zap.New(clone).With(zap.String("tree", "clone"))
+	original.Debug("a")
+	cloned.Debug("b")
+	original.Info("c")
+	cloned.Info("d")
+	// the rate limiter is shared, the bucket is empty
+	original.Info("e")
+	cloned.Info("f")
+
+	require.Equal(t, 2, logs.Len())
+	require.Equal(t, map[string]interface{}{"tree": "original"}, logs.All()[0].ContextMap())
+	require.Equal(t, map[string]interface{}{"tree": "clone"}, logs.All()[1].ContextMap())
+	require.Equal(t, uint64(2), limiter.Dropped())
+}
+
+func TestByEnv(t *testing.T) {
+	t.Parallel()
+
+	const key = "ZAPFILTER_TEST_BY_ENV"
+	require.NoError(t, os.Setenv(key, "1"))
+	defer os.Unsetenv(key)
+
+	enabled := zapfilter.ByEnv(key, "1")
+	disabled := zapfilter.ByEnv(key, "2")
+	unset := zapfilter.ByEnv(key+"_UNSET", "1")
+	require.Equal(t, "AllowAll()", zapfilter.Describe(enabled))
+	require.Equal(t, "DenyAll()", zapfilter.Describe(disabled))
+	require.Equal(t, "DenyAll()", zapfilter.Describe(unset))
+	require.Equal(t, "AllowAll()", zapfilter.Describe(zapfilter.ByEnv(key+"_UNSET", "")))
+
+	// captured once
+	require.NoError(t, os.Setenv(key, "2"))
+	entry := zapcore.Entry{Level: zapcore.DebugLevel}
+	require.True(t, enabled(entry, nil))
+	require.False(t, disabled(entry, nil))
+
+	debug := zapfilter.ExactLevel(zapcore.DebugLevel)
+	require.Equal(t, "ExactLevel(debug)", zapfilter.Describe(zapfilter.All(enabled, debug)))
+	require.Equal(t, "DenyAll()", zapfilter.Describe(zapfilter.All(disabled, debug)))
+}
+
+func TestByLevels_abortingLevels(t *testing.T) {
+	t.Parallel()
+
+	// logAll logs an entry per level, recovering from panics, and returns the logged
+	// levels and whether each level panicked.
+	logAll := func(pattern string, opts ...zap.Option) (logged []string, panicked []string) {
+		next, logs := observer.New(zapcore.DebugLevel)
+		opts = append(opts, zap.OnFatal(zapcore.WriteThenPanic))
+		logger := zap.New(zapfilter.NewFilteringCore(next, zapfilter.MustParseRules(pattern+":*")), opts...)
+		for _, level := range []zapcore.Level{zapcore.DebugLevel, zapcore.InfoLevel, zapcore.WarnLevel, zapcore.ErrorLevel, zapcore.DPanicLevel, zapcore.PanicLevel, zapcore.FatalLevel} {
+			func() {
+				defer func() {
+					if recover() != nil {
+						panicked = append(panicked, level.String())
+					}
+				}()
+				if ce := logger.Check(level, "hello"); ce != nil {
+					ce.Write()
+				}
+			}()
+		}
+		for _, entry := range logs.All() {
+			logged = append(logged, entry.Level.String())
+		}
+		return logged, panicked
+	}
+
+	cases := []struct {
+		pattern  string
+		expected []string
+	}{
+		{"crash", []string{"panic", "fatal"}},
+		{"fatal+", []string{"fatal"}},
+		{"fatal", []string{"fatal"}},
+		{"panic", []string{"panic"}},
+		{"dpanic", []string{"dpanic"}},
+	}
+	for _, tc := range cases {
+		logged, panicked := logAll(tc.pattern)
+		require.Equal(t, tc.expected, logged, tc.pattern)
+		require.Equal(t, []string{"panic", "fatal"}, panicked, tc.pattern)
+	}
+
+	// in development, dpanic entries are matched the same way, and panic even if dropped
+	logged, panicked := logAll("dpanic", zap.Development())
+	require.Equal(t, []string{"dpanic"}, logged)
+	require.Equal(t, []string{"dpanic", "panic", "fatal"}, panicked)
+	logged, panicked = logAll("crash", zap.Development())
+	require.Equal(t, []string{"panic", "fatal"}, logged)
+	require.Equal(t, []string{"dpanic", "panic", "fatal"}, panicked)
+}
+
+func TestWithRecover(t *testing.T) {
+	t.Parallel()
+
+	buggy := func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		if entry.Message == "boom" {
+			panic("buggy filter")
+		}
+		if fields != nil && entry.Message == "boom on write" {
+			panic(fmt.Errorf("buggy filter on write"))
+		}
+		return true
+	}
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	var recovered []interface{}
+	logger := zap.New(zapfilter.NewFilteringCore(next, buggy, zapfilter.WithRecover(func(r interface{}) {
+		recovered = append(recovered, r)
+	})))
+	logger.Info("a")
+	logger.Info("boom")
+	logger.Info("boom on write")
+	logger.Info("b")
+
+	gotLogs := []string{}
+	for _, log := range logs.All() {
+		gotLogs = append(gotLogs, log.Message)
+	}
+	require.Equal(t, []string{"a", "b"}, gotLogs)
+	require.Equal(t, []interface{}{"buggy filter", fmt.Errorf("buggy filter on write")}, recovered)
+
+	// without the option, the panic propagates
+	unprotected := zap.New(zapfilter.NewFilteringCore(next, buggy))
+	require.Panics(t, func() { unprotected.Info("boom") })
+
+	// a nil callback only recovers
+	silent := zap.New(zapfilter.NewFilteringCore(next, buggy, zapfilter.WithRecover(nil)))
+	require.NotPanics(t, func() { silent.Info("boom") })
+}
+
+func TestDynamicMinimumLevel(t *testing.T) {
+	t.Parallel()
+
+	al := zap.NewAtomicLevelAt(zapcore.WarnLevel)
+	next, logs := observer.New(zapcore.DebugLevel)
+	logger := zap.New(zapfilter.NewFilteringCore(next, zapfilter.DynamicMinimumLevel(&al)))
+
+	logger.Info("a")
+	logger.Warn("b")
+	al.SetLevel(zapcore.DebugLevel)
+	logger.Debug("c")
+	logger.Info("d")
+	al.SetLevel(zapcore.ErrorLevel)
+	logger.Warn("e")
+	logger.Error("f")
+
+	gotLogs := []string{}
+	for _, log := range logs.All() {
+		gotLogs = append(gotLogs, log.Message)
+	}
+	require.Equal(t, []string{"b", "c", "d", "f"}, gotLogs)
+}
+
+func TestIsAlwaysTrueFalse(t *testing.T) {
+	t.Parallel()
+
+	custom := func(zapcore.Entry, []zapcore.Field) bool { return true }
+	info := zapfilter.MinimumLevel(zapcore.InfoLevel)
+	cases := []struct {
+		name        string
+		filter      zapfilter.FilterFunc
+		alwaysTrue  bool
+		alwaysFalse bool
+	}{
+		{"allow-all", zapfilter.AllowAll(), true, false},
+		{"deny-all", zapfilter.DenyAll(), false, true},
+		{"nil", nil, false, false},
+		{"custom", custom, false, false},
+		{"minimum-level", info, false, false},
+		{"all-with-deny", zapfilter.All(info, zapfilter.DenyAll()), false, true},
+		{"any-with-allow", zapfilter.Any(info, zapfilter.AllowAll()), true, false},
+		{"by-namespaces-wildcard", zapfilter.ByNamespaces("*"), true, false},
+		{"by-namespaces-empty", zapfilter.ByNamespaces(""), false, true},
+		{"by-namespaces-comma", zapfilter.ByNamespaces(","), false, true},
+		{"by-namespaces-no-cache-comma", zapfilter.ByNamespacesNoCache(","), false, true},
+		{"by-namespaces-shared-comma", zapfilter.ByNamespacesShared(","), false, true},
+		{"allow-empty", zapfilter.Allow(""), false, true},
+		{"by-namespaces-rule-empty", zapfilter.ByNamespacesRule([]string{""}, nil), false, true},
+		{"parse-rules-empty", zapfilter.MustParseRules(""), false, true},
+		{"parse-rules-exclude-only", zapfilter.MustParseRules("-debug:*"), false, true},
+		{"named", zapfilter.NamedFilter("all", zapfilter.AllowAll()), true, false},
+	}
+	for _, tc := range cases {
+		require.Equal(t, tc.alwaysTrue, zapfilter.IsAlwaysTrue(tc.filter), tc.name)
+		require.Equal(t, tc.alwaysFalse, zapfilter.IsAlwaysFalse(tc.filter), tc.name)
+	}
+}
+
+func TestFilteringCore_enabled(t *testing.T) {
+	t.Parallel()
+
+	next, logs := observer.New(zapcore.DebugLevel)
+	core := zapfilter.NewFilteringCore(next, zapfilter.MustParseRules("debug:api.* warn+:*"))
+	require.True(t, core.Enabled(zapcore.DebugLevel)) // enabled for api.*
+	require.False(t, core.Enabled(zapcore.InfoLevel)) // enabled for no namespace
+	require.True(t, core.Enabled(zapcore.WarnLevel))
+
+	logger := zap.New(core)
+	logger.Named("api.users").Debug("a")
+	logger.Named("db").Debug("b") // Check still filters namespaces
+	logger.Named("api.users").Info("c")
+	logger.Named("db").Warn("d")
+	require.Nil(t, logger.Check(zapcore.InfoLevel, "e"))
+
+	gotLogs := []string{}
+	for _, log := range logs.All() {
+		gotLogs = append(gotLogs, log.Message)
+	}
+	require.Equal(t, []string{"a", "d"}, gotLogs)
+
+	// the next core is still asked
+	quiet, _ := observer.New(zapcore.ErrorLevel)
+	require.False(t, zapfilter.NewFilteringCore(quiet, zapfilter.MustParseRules("debug:api.* warn+:*")).Enabled(zapcore.WarnLevel))
+
+	// custom filters are opaque
+	custom := func(zapcore.Entry, []zapcore.Field) bool { return false }
+	require.True(t, zapfilter.NewFilteringCore(next, custom).Enabled(zapcore.InfoLevel))
+}
+
+func TestEmptyLevelSetError(t *testing.T) {
+	t.Parallel()
+
+	for _, input := range []string{"[]:*", "[]:foo,bar", "info:* []:foo"} {
+		_, err := zapfilter.ParseRules(input)
+		var target *zapfilter.EmptyLevelSetError
+		require.True(t, errors.As(err, &target), input)
+		require.Equal(t, "[]", target.Levels)
+	}
+
+	_, err := zapfilter.ByLevels("[]")
+	require.Equal(t, &zapfilter.EmptyLevelSetError{Levels: "[]"}, err)
+	require.EqualError(t, err, `empty level set: "[]"`)
+}
+
+func TestMustParseRules(t *testing.T) {
+	t.Parallel()
+
+	require.NotNil(t, zapfilter.MustParseRules("info+:*"))
+
+	var recovered interface{}
+	func() {
+		defer func() { recovered = recover() }()
+		zapfilter.MustParseRules("info+:* []:api.*")
+	}()
+	err, ok := recovered.(error)
+	require.True(t, ok)
+	require.EqualError(t, err, `zapfilter: invalid rules "info+:* []:api.*": empty level set: "[]"`)
+	var target *zapfilter.EmptyLevelSetError
+	require.True(t, errors.As(err, &target))
+}
+
+func TestExcludeLevels(t *testing.T) {
+	t.Parallel()
+
+	entry := func(level zapcore.Level) zapcore.Entry {
+		return zapfiltertest.Entry(level, "foo", "hello")
+	}
+
+	filter := zapfilter.ExcludeLevels(zapcore.InfoLevel, zapcore.DebugLevel, zapcore.InfoLevel)
+	zapfiltertest.AssertPasses(t, filter, entry(zapcore.WarnLevel), entry(zapcore.FatalLevel), entry(zapcore.Level(-2)))
+	zapfiltertest.AssertDrops(t, filter, entry(zapcore.DebugLevel), entry(zapcore.InfoLevel))
+	require.Equal(t, "ExcludeLevels(debug, info)", zapfilter.Describe(filter))
+
+	reversed := zapfilter.Reverse(zapfilter.ExactLevels(zapcore.DebugLevel, zapcore.InfoLevel))
+	for level := zapcore.Level(-2); level <= zapcore.FatalLevel; level++ {
+		require.Equal(t, reversed(entry(level), nil), filter(entry(level), nil), level)
+	}
+
+	// the levels are known, unlike with Reverse
+	level, ok := zapfilter.EffectiveMinLevel(zapfilter.ExcludeLevels(zapcore.DebugLevel, zapcore.InfoLevel, zapcore.Level(-128)))
+	require.True(t, ok)
+	require.Equal(t, zapcore.Level(-127), level)
+	require.True(t, zapfilter.IsAlwaysTrue(zapfilter.ExcludeLevels()))
+}
+
+func TestOnLevels(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	expensive := func(entry zapcore.Entry, fields []zapcore.Field) bool {
+		calls = append(calls, entry.Message)
+		return strings.Contains(entry.Message, "panic")
+	}
+	entries := []zapcore.Entry{
+		zapfiltertest.Entry(zapcore.DebugLevel, "api", "debug panic"),
+		zapfiltertest.Entry(zapcore.InfoLevel, "api", "info"),
+		zapfiltertest.Entry(zapcore.ErrorLevel, "api", "error panic"),
+		zapfiltertest.Entry(zapcore.ErrorLevel, "api", "error"),
+		zapfiltertest.Entry(zapcore.FatalLevel, "api", "fatal panic"),
+	}
+
+	filter := zapfilter.OnLevels([]zapcore.Level{zapcore.ErrorLevel, zapcore.FatalLevel}, expensive)
+	for _, entry := range entries {
+		filter(entry, []zapcore.Field{})
+	}
+	require.Equal(t, []string{"error panic", "error", "fatal panic"}, calls)
+	require.Equal(t, []bool{false, false, true, false, true}, zapfiltertest.Record(filter, entries))
+
+	calls = nil
+	orDefault := zapfilter.OnLevelsOrDefault([]zapcore.Level{zapcore.ErrorLevel}, expensive, true)
+	require.Equal(t, []bool{true, true, true, false, true}, zapfiltertest.Record(orDefault, entries))
+	for _, call := range calls {
+		require.Contains(t, call, "error")
+	}
+
+	// the levels are known
+	level, ok := zapfilter.EffectiveMinLevel(zapfilter.OnLevels([]zapcore.Level{zapcore.InfoLevel, zapcore.ErrorLevel}, zapfilter.MinimumLevel(zapcore.WarnLevel)))
+	require.True(t, ok)
+	require.Equal(t, zapcore.ErrorLevel, level)
+	_, ok = zapfilter.EffectiveMinLevel(zapfilter.OnLevels(nil, expensive))
+	require.False(t, ok)
+	require.Equal(t,
+		`OnLevelsOrDefault([info, error], ByMessage("panic"), true)`,
+		zapfilter.Describe(zapfilter.OnLevelsOrDefault([]zapcore.Level{zapcore.ErrorLevel, zapcore.InfoLevel}, zapfilter.ByMessage("panic"), true)),
+	)
+}
+
+func TestExactLevels(t *testing.T) {
+	t.Parallel()
+
+	entry := func(level zapcore.Level) zapcore.Entry {
+		return zapfiltertest.Entry(level, "foo", "hello")
+	}
+
+	filter := zapfilter.ExactLevels(zapcore.ErrorLevel, zapcore.DebugLevel, zapcore.ErrorLevel)
+	zapfiltertest.AssertPasses(t, filter, entry(zapcore.DebugLevel), entry(zapcore.ErrorLevel))
+	zapfiltertest.AssertDrops(t, filter, entry(zapcore.InfoLevel), entry(zapcore.WarnLevel), entry(zapcore.FatalLevel))
+	require.Equal(t, "ExactLevels(debug, error)", zapfilter.Describe(filter))
+	level, ok := zapfilter.EffectiveMinLevel(filter)
+	require.True(t, ok)
+	require.Equal(t, zapcore.DebugLevel, level)
+
+	chained := zapfilter.Any(zapfilter.ExactLevel(zapcore.DebugLevel), zapfilter.ExactLevel(zapcore.ErrorLevel))
+	for level := zapcore.DebugLevel; level <= zapcore.FatalLevel; level++ {
+		require.Equal(t, chained(entry(level), nil), filter(entry(level), nil), level)
+	}
+
+	require.True(t, zapfilter.IsAlwaysFalse(zapfilter.ExactLevels()))
+	require.Equal(t, "ExactLevels()", zapfilter.Describe(zapfilter.ExactLevels()))
+}
+
+func TestCustomLevelsBelowDebug(t *testing.T) {
+	t.Parallel()
+
+	const traceLevel = zapcore.Level(-2)
+	cases := []struct {
+		name         string
+		filter       zapfilter.FilterFunc
+		expectedLogs []string
+	}{
+		{"minimum-trace", zapfilter.MinimumLevel(traceLevel), []string{"a", "b", "c", "d", "e"}},
+		{"minimum-debug", zapfilter.MinimumLevel(zapcore.DebugLevel), []string{"b", "c", "e"}},
+		{"maximum-trace", zapfilter.MaximumLevel(traceLevel), []string{"a", "d"}},
+		{"maximum-debug", zapfilter.MaximumLevel(zapcore.DebugLevel), []string{"a", "b", "d"}},
+		{"rules-star", zapfilter.MustParseRules("*:*"), []string{"a", "b", "c", "d", "e"}},
+		{"rules-namespace-only", zapfilter.MustParseRules("foo"), []string{"b", "c"}},
+		{"rules-builtins", zapfilter.MustParseRules("debug+:*"), []string{"b", "c", "e"}},
+		{"rules-trace", zapfilter.MustParseRules("-2:*"), []string{"a", "d"}},
+		{"rules-trace-plus", zapfilter.MustParseRules("-2+:foo"), []string{"b", "c"}},
+		{"rules-trace-set", zapfilter.MustParseRules("[-2,info]:*"), []string{"a", "c", "d", "e"}},
+		{"rules-trace-and-star", zapfilter.MustParseRules("-2,*:*"), []string{"a", "b", "c", "d", "e"}},
+		{"rules-subtract-trace", zapfilter.MustParseRules("-2+:* --2:bar"), []string{"a", "b", "c", "e"}},
+		{"rules-subtract-keyword", zapfilter.MustParseRules("-2+:* -info:*"), []string{"a", "b", "d"}},
+	}
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			next, logs := observer.New(traceLevel)
+			logger := zap.New(zapfilter.NewFilteringCore(next, tc.filter))
+
+			trace := func(logger *zap.Logger, msg string) {
+				if ce := logger.Check(traceLevel, msg); ce != nil {
+					ce.Write()
+				}
+			}
+			trace(logger, "a")
+			logger.Named("foo").Debug("b")
+			logger.Named("foo").Info("c")
+			trace(logger.Named("bar"), "d")
+			logger.Named("bar").Info("e")
+
+			gotLogs := []string{}
+			for _, log := range logs.All() {
+				gotLogs = append(gotLogs, log.Message)
+			}
+			require.Equal(t, tc.expectedLogs, gotLogs)
+		})
+	}
+
+	_, err := zapfilter.ParseRules("128:*")
+	require.EqualError(t, err, `unsupported keyword: "128"`)
+	require.Equal(t, "MaximumLevel(info)", zapfilter.Describe(zapfilter.MaximumLevel(zapcore.InfoLevel)))
+}
+
+func TestWillCrash(t *testing.T) {
+	t.Parallel()
+
+	levels := []zapcore.Level{zapcore.DebugLevel, zapcore.InfoLevel, zapcore.WarnLevel, zapcore.ErrorLevel, zapcore.DPanicLevel, zapcore.PanicLevel, zapcore.FatalLevel}
+	for _, development := range []bool{false, true} {
+		next, logs := observer.New(zapcore.DebugLevel)
+		opts := []zap.Option{zap.OnFatal(zapcore.WriteThenPanic)}
+		if development {
+			opts = append(opts, zap.Development())
+		}
+		logger := zap.New(zapfilter.NewFilteringCore(next, zapfilter.WillCrash(development)), opts...)
+
+		// every level that crashes is written, and only those
+		var panicked []string
+		for _, level := range levels {
+			func() {
+				defer func() {
+					if recover() != nil {
+						panicked = append(panicked, level.String())
+					}
+				}()
+				if ce := logger.Check(level, "hello"); ce != nil {
+					ce.Write()
+				}
+			}()
+		}
+		logged := []string{}
+		for _, entry := range logs.All() {
+			logged = append(logged, entry.Level.String())
+		}
+		require.Equal(t, panicked, logged, "development=%v", development)
+		if development {
+			require.Equal(t, []string{"dpanic", "panic", "fatal"}, logged)
+		} else {
+			require.Equal(t, []string{"panic", "fatal"}, logged)
+		}
+	}
+
+	require.Equal(t, "WillCrash(true)", zapfilter.Describe(zapfilter.WillCrash(true)))
+	level, ok := zapfilter.EffectiveMinLevel(zapfilter.WillCrash(false))
+	require.True(t, ok)
+	require.Equal(t, zapcore.PanicLevel, level)
+}
+
+func TestVerbosityLevel(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		count    int
+		expected string
+	}{
+		{math.MinInt32, "MinimumLevel(warn)"},
+		{-1, "MinimumLevel(warn)"},
+		{0, "MinimumLevel(warn)"},
+		{1, "MinimumLevel(info)"},
+		{2, "MinimumLevel(debug)"},
+		{3, "AllowAll()"},
+		{math.MaxInt32, "AllowAll()"},
+	}
+	for _, tc := range cases {
+		require.Equal(t, tc.expected, zapfilter.Describe(zapfilter.VerbosityLevel(tc.count)), tc.count)
+	}
+
+	trace := zapfiltertest.Entry(zapcore.Level(-2), "", "hello")
+	debug := zapfiltertest.Entry(zapcore.DebugLevel, "", "hello")
+	info := zapfiltertest.Entry(zapcore.InfoLevel, "", "hello")
+	warn := zapfiltertest.Entry(zapcore.WarnLevel, "", "hello")
+	zapfiltertest.AssertPasses(t, zapfilter.VerbosityLevel(0), warn)
+	zapfiltertest.AssertDrops(t, zapfilter.VerbosityLevel(0), info)
+	zapfiltertest.AssertPasses(t, zapfilter.VerbosityLevel(1), info, warn)
+	zapfiltertest.AssertDrops(t, zapfilter.VerbosityLevel(1), debug)
+	zapfiltertest.AssertPasses(t, zapfilter.VerbosityLevel(2), debug, info)
+	zapfiltertest.AssertDrops(t, zapfilter.VerbosityLevel(2), trace)
+	zapfiltertest.AssertPasses(t, zapfilter.VerbosityLevel(3), trace, debug)
+}
@@ -0,0 +1,92 @@
+// Package zapfiltertest provides helpers to test zapfilter filters without building
+// loggers and observers by hand.
+package zapfiltertest // import "moul.io/zapfilter/zapfiltertest"
+
+import (
+	"go.uber.org/zap/zapcore"
+	"moul.io/zapfilter"
+)
+
+// TestingT is the subset of testing.TB used by the assertions.
+type TestingT interface {
+	Helper()
+	Errorf(format string, args ...interface{})
+}
+
+// Entry is a shortcut to build a synthetic entry.
+func Entry(level zapcore.Level, namespace, message string) zapcore.Entry {
+	return zapcore.Entry{Level: level, LoggerName: namespace, Message: message}
+}
+
+// Record evaluates the filter against each entry, in order, the same way a filtering
+// core would (Check then Write), and reports which ones passed.
+func Record(filter zapfilter.FilterFunc, entries []zapcore.Entry) []bool {
+	var passed bool
+	core := zapfilter.NewFilteringCore(recorder{&passed}, filter)
+
+	results := make([]bool, len(entries))
+	for i, entry := range entries {
+		passed = false
+		if ce := core.Check(entry, nil); ce != nil {
+			ce.Write()
+		}
+		results[i] = passed
+	}
+	return results
+}
+
+// AssertPasses checks that every entry passes the filter.
+func AssertPasses(t TestingT, filter zapfilter.FilterFunc, entries ...zapcore.Entry) bool {
+	t.Helper()
+	return assert(t, filter, entries, true)
+}
+
+// AssertDrops checks that every entry is dropped by the filter.
+func AssertDrops(t TestingT, filter zapfilter.FilterFunc, entries ...zapcore.Entry) bool {
+	t.Helper()
+	return assert(t, filter, entries, false)
+}
+
+func assert(t TestingT, filter zapfilter.FilterFunc, entries []zapcore.Entry, expected bool) bool {
+	t.Helper()
+	success := true
+	for i, passed := range Record(filter, entries) {
+		if passed == expected {
+			continue
+		}
+		success = false
+		entry := entries[i]
+		if expected {
+			t.Errorf("entry #%d (level=%s logger=%q msg=%q) should pass but was dropped", i, entry.Level, entry.LoggerName, entry.Message)
+		} else {
+			t.Errorf("entry #%d (level=%s logger=%q msg=%q) should be dropped but passed", i, entry.Level, entry.LoggerName, entry.Message)
+		}
+	}
+	return success
+}
+
+// recorder is a core accepting everything and flagging written entries.
+type recorder struct {
+	passed *bool
+}
+
+func (r recorder) Enabled(zapcore.Level) bool {
+	return true
+}
+
+func (r recorder) With([]zapcore.Field) zapcore.Core {
+	return r
+}
+
+func (r recorder) Check(entry zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	return ce.AddCore(entry, r)
+}
+
+func (r recorder) Write(zapcore.Entry, []zapcore.Field) error {
+	*r.passed = true
+	return nil
+}
+
+func (r recorder) Sync() error {
+	return nil
+}
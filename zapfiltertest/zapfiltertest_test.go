@@ -0,0 +1,55 @@
+package zapfiltertest_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap/zapcore"
+	"moul.io/zapfilter"
+	"moul.io/zapfilter/zapfiltertest"
+)
+
+func TestRecord(t *testing.T) {
+	filter := zapfilter.MustParseRules("info:foo.* error:*")
+	got := zapfiltertest.Record(filter, []zapcore.Entry{
+		zapfiltertest.Entry(zapcore.DebugLevel, "foo.bar", "a"),
+		zapfiltertest.Entry(zapcore.InfoLevel, "foo.bar", "b"),
+		zapfiltertest.Entry(zapcore.InfoLevel, "bar", "c"),
+		zapfiltertest.Entry(zapcore.ErrorLevel, "", "d"),
+	})
+	require.Equal(t, []bool{false, true, false, true}, got)
+}
+
+func TestRecord_stateful(t *testing.T) {
+	entry := zapfiltertest.Entry(zapcore.ErrorLevel, "", "blip")
+	got := zapfiltertest.Record(zapfilter.GlobalRateLimit(0, 2), []zapcore.Entry{entry, entry, entry})
+	require.Equal(t, []bool{true, true, false}, got)
+}
+
+func TestAssertions(t *testing.T) {
+	filter := zapfilter.MustParseRules("info+:*")
+	debug := zapfiltertest.Entry(zapcore.DebugLevel, "foo", "a")
+	warn := zapfiltertest.Entry(zapcore.WarnLevel, "foo", "b")
+
+	require.True(t, zapfiltertest.AssertPasses(t, filter, warn))
+	require.True(t, zapfiltertest.AssertDrops(t, filter, debug))
+
+	mock := &mockT{}
+	require.False(t, zapfiltertest.AssertPasses(mock, filter, debug, warn))
+	require.Equal(t, []string{`entry #0 (level=debug logger="foo" msg="a") should pass but was dropped`}, mock.errors)
+
+	mock = &mockT{}
+	require.False(t, zapfiltertest.AssertDrops(mock, filter, debug, warn))
+	require.Equal(t, []string{`entry #1 (level=warn logger="foo" msg="b") should be dropped but passed`}, mock.errors)
+}
+
+type mockT struct {
+	errors []string
+}
+
+func (m *mockT) Helper() {}
+
+func (m *mockT) Errorf(format string, args ...interface{}) {
+	m.errors = append(m.errors, fmt.Sprintf(format, args...))
+}